@@ -1,69 +1,311 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"sync"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
+	sitecrawler "github.com/csrar/crawler"
 	boot "github.com/csrar/crawler/internal/bootstrap"
 	"github.com/csrar/crawler/internal/service"
 	"github.com/csrar/crawler/pkg/config"
+	"github.com/csrar/crawler/pkg/crawler"
 	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/robots"
+	"github.com/csrar/crawler/pkg/store"
 )
 
 func main() {
+	role := flag.String("role", "standalone", "role of the process: standalone, coordinator or worker")
+	resumeDir := flag.String("resume", "", "directory of the checkpoint to resume the crawl from")
+	flag.Parse()
+
 	// Initialize logger, config, and bootstrapper
 	log := logger.NewLogrusLogger()
 	config := config.NewConfig()
 	boot := boot.NewBootstrap(config)
+
+	// Cancel the crawl on Ctrl-C or docker stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Stop the coordinator once MAX_DURATION_MS has passed, the workers run until they are stopped and
+	// the standalone crawl applies it itself
+	if maxDuration := config.GetConfig().MaxDuration; maxDuration > 0 && *role == "coordinator" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxDuration)
+		defer cancel()
+	}
+
+	if *resumeDir != "" && *role != "standalone" {
+		log.Error(errors.New("only the standalone role can resume a crawl"))
+		return
+	}
+	switch *role {
+	case "standalone":
+		runStandalone(ctx, log, config, boot, *resumeDir)
+	case "coordinator":
+		runCoordinator(ctx, log, config, boot)
+	case "worker":
+		runWorker(ctx, log, config, boot)
+	default:
+		log.Error(fmt.Errorf("unknown role %q, available roles: standalone, coordinator, worker", *role))
+	}
+}
+
+// runStandalone crawls the site with the workers of this process.
+func runStandalone(ctx context.Context, log logger.Ilogger, config config.IConfig, boot boot.Ibootstrap, resumeDir string) {
+	cfg := config.GetConfig()
+
+	// Bootstrap store and handle errors, the crawl leaves it open for the summary
+	store, err := boot.BoostrapStore()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	// The library bootstraps everything else and stops the crawl once MAX_DURATION_MS has passed
+	standalone, err := sitecrawler.New(sitecrawler.WithConfig(cfg), sitecrawler.WithLogger(log), sitecrawler.WithStore(store),
+		sitecrawler.WithResume(resumeDir))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	crawl, err := standalone.Run(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	stats, err := crawl.Wait()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn("max crawl duration reached, pending links were not explored")
+	case err != nil:
+		log.Warn("crawl interrupted, pending links were not explored")
+	}
+	log.Info(fmt.Sprintf("finished crawling for [%s], total links explored: [%d], dropped: [%d], blocked by robots: [%d], elapsed seconds: [%.2f]",
+		cfg.WepPage, stats.Processed, stats.Dropped, stats.Blocked, stats.Elapsed.Seconds()))
+	if cfg.AssetMode {
+		log.Info(fmt.Sprintf("assets blocked by robots: [%d]", stats.AssetsBlocked))
+	}
+	logCrawlSummary(log, config, store, cfg.AssetMode)
+}
+
+// runCoordinator hands the links of the crawl to the workers through the broker and keeps the
+// results they send back in the store.
+func runCoordinator(ctx context.Context, log logger.Ilogger, config config.IConfig, boot boot.Ibootstrap) {
 	startTime := time.Now()
 
-	// Bootstrap root page
 	page, err := boot.BootsRootPage()
 	if err != nil {
 		log.Error(err)
 		return
 	}
-
-	// Bootstrap store and handle errors
 	store, err := boot.BoostrapStore()
 	if err != nil {
 		log.Error(err)
 		return
 	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Error(err)
+		}
+	}()
 
-	// Bootstrap channels
-	channels := boot.BootstrapChannels()
-	channels.Queue <- page.String()
-
-	boot.StartWorkersQueue(channels.Workers)
+	// The robots.txt rules and the HTTP client are only used to collect the sitemap links
+	client, err := boot.BootstrapHTTPClient()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	// An unreachable robots.txt disallows every link instead of stopping the crawl
+	rules, err := boot.BootstrapRobots(ctx, client, page)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+	normalizer := boot.BootstrapNormalizer()
 
+	// Bootstrap the broker the links and the results travel through
+	messages, err := boot.BootstrapBroker()
 	if err != nil {
-		log.Error(errors.New("invalid site url provided"))
+		log.Error(err)
 		return
 	}
+	defer func() {
+		if err := messages.Close(); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	// The politeness delays apply to the requests of every worker, the jobs are handed out as they allow
+	coordinator := service.NewCoordinator(config.GetConfig(), messages, log, store, normalizer, boot.BootstrapScheduler(rules))
+	go coordinator.ListenForResults(ctx)
+	coordinator.Seed(boot.BootstrapSeeds(ctx, log, client, page, rules, store, normalizer)...)
+
+	<-coordinator.Done()
+	pending := len(coordinator.Pending())
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Warn(fmt.Sprintf("max crawl duration reached, [%d] links handed to the workers were not explored", pending))
+	case ctx.Err() != nil:
+		log.Warn(fmt.Sprintf("crawl interrupted, [%d] links handed to the workers were not explored", pending))
+	}
+	elapsedTime := time.Since(startTime)
+
+	stats := coordinator.Stats()
+	log.Info(fmt.Sprintf("finished crawling for [%s], total links explored: [%d], dropped: [%d], blocked by robots: [%d], elapsed seconds: [%.2f]",
+		page.String(), stats.Processed, stats.Dropped, stats.Blocked, elapsedTime.Seconds()))
+	if config.GetConfig().AssetMode {
+		log.Info(fmt.Sprintf("assets blocked by robots: [%d]", stats.AssetsBlocked))
+	}
+	logCrawlSummary(log, config, store, config.GetConfig().AssetMode)
+}
 
-	// Initialize counters and synchronization objects
-	found := 0
-	processed := 0
-	var mx sync.Mutex
-	var wg sync.WaitGroup
+// runWorker crawls the links handed out by the coordinator until it is stopped.
+func runWorker(ctx context.Context, log logger.Ilogger, config config.IConfig, boot boot.Ibootstrap) {
+	page, err := boot.BootsRootPage()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	client, err := boot.BootstrapHTTPClient()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	fetcher := boot.BootstrapFetcher(client)
+	// An unreachable robots.txt disallows every link instead of stopping the crawl
+	rules, err := boot.BootstrapRobots(ctx, client, page)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+	normalizer := boot.BootstrapNormalizer()
+	extractor := boot.BootstrapExtractor()
 
-	// Add a WaitGroup for tracking the completion of crawling
-	wg.Add(1)
+	messages, err := boot.BootstrapBroker()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer func() {
+		if err := messages.Close(); err != nil {
+			log.Error(err)
+		}
+	}()
 
-	// Create a CrawlerHandler to manage crawling
-	handler := service.NewCrawlerHandler(&found, &processed, channels, log, store, &wg, &mx)
+	// Every page gets its own asset checker, recording the assets in the result of the page. The
+	// coordinator only schedules the pages, the assets of the site are requested as politely by
+	// the scheduler of the worker
+	scheduler := boot.BootstrapScheduler(rules)
+	assets := func(store store.ICrawlerStore, pageRules robots.IRobots) crawler.IAssetChecker {
+		return boot.BootstrapAssetChecker(log, store, normalizer, fetcher, page, pageRules, scheduler)
+	}
+	worker := service.NewWorker(config.GetConfig(), messages, log, rules, normalizer, extractor, fetcher, assets)
+	log.Info(fmt.Sprintf("waiting for the links of [%s] to crawl", page.String()))
+	if err := worker.Run(ctx); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Info(fmt.Sprintf("worker stopped, total links explored: [%d], blocked by robots: [%d], assets blocked by robots: [%d]",
+		worker.Stats().Processed, rules.Blocked(), rules.AssetsBlocked()))
+}
 
-	// Start Goroutines for listening to new links and validating crawl finish
-	go handler.ListenForNewLinks()
-	go handler.ValidateCrawlFinish()
+// logCrawlSummary reports the pages, failures, assets, visited filter and noindex pages kept in the store.
+func logCrawlSummary(log logger.Ilogger, config config.IConfig, store store.ICrawlerStore, assets bool) {
+	logPageSummary(log, store)
+	// the pages crawled again once they failed are not reported
+	if failed, err := service.FailedPages(store); err != nil {
+		log.Error(err)
+	} else if len(failed) > 0 {
+		for _, page := range failed {
+			log.Warn(fmt.Sprintf("failed page: %s (%s) found in: %s", page.Item.URL, page.Error, page.Item.Parent))
+		}
+		log.Info(fmt.Sprintf("failed pages: [%d]", len(failed)))
+	}
+	if assets {
+		logAssetSummary(log, store)
+	}
+	logFilterSummary(log, store)
+	if config.GetConfig().HonorDirectives {
+		if noindex, err := store.NoindexPages(); err != nil {
+			log.Error(err)
+		} else {
+			log.Info(fmt.Sprintf("noindex pages: [%d]", len(noindex)))
+		}
+	}
+}
 
-	wg.Wait()
-	// Calculate the elapsed time just before exiting
-	elapsedTime := time.Since(startTime)
+// logPageSummary reports the number of fetched pages by status code and the redirects to other hosts.
+func logPageSummary(log logger.Ilogger, store store.ICrawlerStore) {
+	pages, err := store.Pages()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	byStatus := map[int]int{}
+	offHost := 0
+	for _, page := range pages {
+		byStatus[page.StatusCode]++
+		if page.OffHostRedirect {
+			offHost++
+		}
+	}
+	statuses := make([]int, 0, len(byStatus))
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	counts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		// pages that could not be fetched are counted with status 0
+		counts = append(counts, fmt.Sprintf("%d: %d", status, byStatus[status]))
+	}
+	log.Info(fmt.Sprintf("pages fetched: [%d], by status: [%s], off host redirects: [%d]",
+		len(pages), strings.Join(counts, ", "), offHost))
+}
 
-	log.Info(fmt.Sprintf("finished crawling for [%s], total liks explored: [%d], elapsed seconds: [%.2f]", page.String(), processed, elapsedTime.Seconds()))
+// logFilterSummary reports the memory used by the visited links filter and its error rate, when the
+// store keeps them in one.
+func logFilterSummary(log logger.Ilogger, crawlStore store.ICrawlerStore) {
+	filter, ok := crawlStore.(store.IFilterStats)
+	if !ok {
+		return
+	}
+	stats := filter.FilterStats()
+	log.Info(fmt.Sprintf("visited filter links: [%d], filters: [%d], memory: [%.2f MiB], estimated false positive rate: [%.6f]",
+		stats.Keys, stats.Filters, float64(stats.MemoryBytes)/(1024*1024), stats.FalsePositiveRate))
+	if stats.Verified > 0 {
+		log.Info(fmt.Sprintf("visited filter links verified: [%d], false positives: [%d]", stats.Verified, stats.FalsePositives))
+	}
+}
 
+// logAssetSummary reports the number of checked, broken and oversized assets.
+func logAssetSummary(log logger.Ilogger, store store.ICrawlerStore) {
+	checked, err := store.Assets()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	broken, oversized := 0, 0
+	for _, asset := range checked {
+		if asset.Broken {
+			broken++
+		}
+		if asset.Oversized {
+			oversized++
+		}
+	}
+	log.Info(fmt.Sprintf("assets checked: [%d], broken: [%d], oversized: [%d]", len(checked), broken, oversized))
 }
@@ -0,0 +1,330 @@
+// Package crawler crawls the pages of a site reachable from a root page, for programs embedding
+// the crawl instead of running the command:
+//
+//	c, err := crawler.New(crawler.WithURL("https://example.com/"), crawler.WithMaxPages(100),
+//		crawler.WithResults(100), crawler.OnError(func(err error) { log.Println(err) }))
+//	if err != nil {
+//		return err
+//	}
+//	crawl, err := c.Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for page := range crawl.Results() {
+//		fmt.Println(page.URL, page.StatusCode, page.Title)
+//	}
+//	stats, err := crawl.Wait()
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	boot "github.com/csrar/crawler/internal/bootstrap"
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/internal/service"
+	"github.com/csrar/crawler/pkg/config"
+	pagecrawler "github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/store"
+)
+
+type (
+	// Config is the configuration of a crawl, its fields are the environment variables of the
+	// command described in the README.
+	Config = models.Config
+	// PageResult is the record of a page fetch.
+	PageResult = models.PageResult
+	// LinkResult is a link from a crawled page to another page of the site.
+	LinkResult = models.LinkResult
+	// FailedPage is the record of a page that could not be crawled.
+	FailedPage = models.FailedPage
+	// FetchError is passed to the OnError callbacks, wrapped, for every page that could not be
+	// crawled, its Page is the failure record kept in the store.
+	FetchError = pagecrawler.FetchError
+)
+
+// Stats are the counters of a finished crawl: the links Found and queued, the ones Processed and
+// the ones Dropped without being crawled, the links and the assets of the crawled host Blocked by
+// robots.txt, and the page results not sent to Results because the reader was behind.
+type Stats struct {
+	Found          int
+	Processed      int
+	Dropped        int
+	Blocked        int
+	AssetsBlocked  int
+	ResultsDropped int
+	Elapsed        time.Duration
+}
+
+// Crawler crawls a site with the configuration and the callbacks of its options. The callbacks are
+// called from the crawling goroutines, several at a time, and hold the crawl while they run.
+type Crawler struct {
+	cfg       Config
+	log       logger.Ilogger
+	store     store.ICrawlerStore
+	resumeDir string
+	// results is the number of page results Results keeps for the reader, none are sent when 0
+	results int
+	onPage  []func(PageResult)
+	onLink  []func(LinkResult)
+	onError []func(error)
+}
+
+// Crawl is a crawl started by Run.
+type Crawl struct {
+	results chan PageResult
+	// dropped counts the results the reader was too far behind to receive
+	dropped atomic.Int64
+	done    chan struct{}
+	stats   Stats
+	err     error
+}
+
+// DefaultConfig returns the default configuration of a crawl, to change with WithConfig.
+func DefaultConfig() Config {
+	return config.Defaults()
+}
+
+// New creates a crawler from the default configuration changed by the options, the site to crawl
+// is set with WithURL or WithConfig.
+func New(opts ...Option) (*Crawler, error) {
+	cfg := DefaultConfig()
+	cfg.WepPage = ""
+	c := &Crawler{cfg: cfg, log: logger.NewNopLogger()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.cfg.WepPage == "" {
+		return nil, errors.New("the site to crawl is not set")
+	}
+	page, err := url.Parse(c.cfg.WepPage)
+	if err != nil || !page.IsAbs() {
+		return nil, fmt.Errorf("invalid site URL provided: %q", c.cfg.WepPage)
+	}
+	if c.cfg.Workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers: %d", c.cfg.Workers)
+	}
+	return c, nil
+}
+
+// Run starts crawling the site. The crawl is over once every link found was crawled, or once the
+// context is done or MaxDuration passed, letting the running requests finish within
+// ShutdownTimeout.
+func (c *Crawler) Run(ctx context.Context) (*Crawl, error) {
+	start := time.Now()
+	cancel := func() {}
+	if c.cfg.MaxDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.MaxDuration)
+	}
+	log := &observedLogger{Ilogger: c.log, onError: c.onError}
+	boot := boot.NewBootstrap(config.NewStaticConfig(c.cfg))
+	// closers release what the crawl opened, in reverse order, once it is over or failed to start
+	closers := []func() error{}
+	cleanup := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i](); err != nil {
+				log.Error(err)
+			}
+		}
+		cancel()
+	}
+	fail := func(err error) (*Crawl, error) {
+		cleanup()
+		return nil, err
+	}
+
+	page, err := boot.BootsRootPage()
+	if err != nil {
+		return fail(err)
+	}
+	crawlStore := c.store
+	if crawlStore == nil {
+		if crawlStore, err = boot.BoostrapStore(); err != nil {
+			return fail(err)
+		}
+		closers = append(closers, crawlStore.Close)
+	}
+	crawl := &Crawl{results: make(chan PageResult, c.results), done: make(chan struct{})}
+	observed := &observedStore{ICrawlerStore: crawlStore, crawler: c, crawl: crawl}
+
+	client, err := boot.BootstrapHTTPClient()
+	if err != nil {
+		return fail(err)
+	}
+	fetcher := boot.BootstrapFetcher(client)
+	rules, err := boot.BootstrapRobots(ctx, client, page)
+	if err != nil {
+		// an unreachable robots.txt disallows every link instead of stopping the crawl
+		log.Warn(err.Error())
+	}
+	scheduler := boot.BootstrapScheduler(rules)
+	normalizer := boot.BootstrapNormalizer()
+	extractor := boot.BootstrapExtractor()
+	assets := boot.BootstrapAssetChecker(log, observed, normalizer, fetcher, page, rules, scheduler)
+	channels := boot.BootstrapChannels()
+	queue, err := boot.BootstrapFrontier()
+	if err != nil {
+		return fail(err)
+	}
+	closers = append(closers, queue.Close)
+	checkpoints, err := boot.BootstrapCheckpointer(c.resumeDir)
+	if err != nil {
+		return fail(err)
+	}
+	if _, ok := crawlStore.(store.IVisitedKeys); checkpoints != nil && !ok {
+		// found at start instead of when the first checkpoint is saved
+		return fail(errors.New("the store cannot list its visited links to checkpoint them, the bloom backend needs BLOOM_VERIFY_DIR"))
+	}
+
+	boot.StartWorkersQueue(channels.Workers)
+	handler := service.NewCrawlerHandler(c.cfg, channels, queue, log, observed, rules, scheduler, normalizer, extractor, assets, fetcher, checkpoints)
+	if c.resumeDir != "" {
+		// continue from the links and the visited set of the last checkpoint
+		state, err := checkpoints.Load()
+		if err == nil {
+			err = handler.Restore(state)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		log.Info(fmt.Sprintf("resuming crawl checkpointed at %s, links left to crawl: [%d], visited: [%d]",
+			state.Time.Format(time.RFC3339), len(state.Frontier), len(state.Visited)))
+	}
+	go handler.ListenForNewLinks(ctx)
+	go handler.ValidateCrawlFinish(ctx)
+	if c.resumeDir == "" {
+		handler.Seed(boot.BootstrapSeeds(ctx, log, client, page, rules, observed, normalizer)...)
+	}
+
+	go func() {
+		<-handler.Done()
+		if assets != nil {
+			// the queued assets are checked unless the crawl was interrupted, then they are given
+			// ShutdownTimeout like the running requests
+			shutdownCtx, cancelShutdown := context.Background(), func() {}
+			if ctx.Err() != nil {
+				shutdownCtx, cancelShutdown = context.WithTimeout(context.Background(), c.cfg.ShutdownTimeout)
+			}
+			if err := assets.Shutdown(shutdownCtx); err != nil {
+				log.Warn(fmt.Sprintf("assets left unchecked: %v", err))
+			}
+			cancelShutdown()
+		}
+		if err := handler.Checkpoint(); err != nil {
+			log.Error(err)
+		}
+		stats := handler.Stats()
+		crawl.stats = Stats{
+			Found:          stats.Found,
+			Processed:      stats.Processed,
+			Dropped:        stats.Dropped,
+			Blocked:        rules.Blocked(),
+			AssetsBlocked:  rules.AssetsBlocked(),
+			ResultsDropped: int(crawl.dropped.Load()),
+			Elapsed:        time.Since(start),
+		}
+		crawl.err = ctx.Err()
+		cleanup()
+		close(crawl.results)
+		close(crawl.done)
+	}()
+	return crawl, nil
+}
+
+// Results returns the results of the fetched pages as they are recorded when the crawler was created
+// WithResults, the channel is closed once the crawl is over. The crawl never waits for them to be
+// read, the results arriving while the channel is full are dropped and counted.
+func (c *Crawl) Results() <-chan PageResult {
+	return c.results
+}
+
+// Wait waits for the crawl to be over, leaving the results that were not read in Results, and
+// returns its stats. The error is the one of the context when the crawl was interrupted or ran past
+// MaxDuration, with links left to crawl.
+func (c *Crawl) Wait() (Stats, error) {
+	<-c.done
+	return c.stats, c.err
+}
+
+// observedLogger passes the errors to the OnError callbacks before logging them, the errors of the
+// pages that could not be crawled wrap a FetchError.
+type observedLogger struct {
+	logger.Ilogger
+	onError []func(error)
+}
+
+func (l *observedLogger) Error(err error) {
+	for _, onError := range l.onError {
+		onError(err)
+	}
+	l.Ilogger.Error(err)
+}
+
+// observedStore keeps the records of the crawl in the store and passes the pages and the links to
+// the callbacks and the results of the crawl.
+type observedStore struct {
+	store.ICrawlerStore
+	crawler *Crawler
+	crawl   *Crawl
+}
+
+// RecordPage keeps the page in the store and sends it to the results, dropping it when they are
+// not read fast enough.
+func (s *observedStore) RecordPage(page PageResult) error {
+	err := s.ICrawlerStore.RecordPage(page)
+	for _, onPage := range s.crawler.onPage {
+		onPage(page)
+	}
+	if s.crawler.results > 0 {
+		select {
+		case s.crawl.results <- page:
+		default:
+			s.crawl.dropped.Add(1)
+		}
+	}
+	return err
+}
+
+// RecordLinks keeps the links in the store, when it records them, and passes them to the OnLink
+// callbacks.
+func (s *observedStore) RecordLinks(links []LinkResult) error {
+	var err error
+	if recorder, ok := s.ICrawlerStore.(store.ILinkRecorder); ok {
+		err = recorder.RecordLinks(links)
+	}
+	for _, link := range links {
+		for _, onLink := range s.crawler.onLink {
+			onLink(link)
+		}
+	}
+	return err
+}
+
+// Links returns the links recorded by the store, none when it does not record them.
+func (s *observedStore) Links() ([]LinkResult, error) {
+	if recorder, ok := s.ICrawlerStore.(store.ILinkRecorder); ok {
+		return recorder.Links()
+	}
+	return []LinkResult{}, nil
+}
+
+// VisitedKeys returns the visited keys of the store to checkpoint them, when it can list them.
+func (s *observedStore) VisitedKeys() ([]string, error) {
+	if keys, ok := s.ICrawlerStore.(store.IVisitedKeys); ok {
+		return keys.VisitedKeys()
+	}
+	return nil, errors.New("the store cannot list its visited links to checkpoint them")
+}
+
+// RestoreVisited restores the visited keys of the store from a checkpoint, when it can list them.
+func (s *observedStore) RestoreVisited(keys []string) error {
+	if restorer, ok := s.ICrawlerStore.(store.IVisitedKeys); ok {
+		return restorer.RestoreVisited(keys)
+	}
+	return errors.New("the store cannot restore its visited links from a checkpoint")
+}
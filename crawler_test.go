@@ -0,0 +1,240 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/store"
+	"github.com/dsnet/golib/memfile"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSite serves a root page linking to two pages linking to each other, a page failing with
+// 500 and a page of another host.
+func newTestSite() *httptest.Server {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a><a href="/broken">broken</a><a href="https://external.example.com/">external</a>`,
+		"/a": `<title>A</title><a href="/b">b</a><a href="/">home</a>`,
+		"/b": `<title>B</title><a href="/a">a</a>`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		switch {
+		case r.URL.Path == "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case !ok:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+		}
+	}))
+}
+
+// testConfig is the configuration of a crawl of site without politeness delays nor retries.
+func testConfig(site string) Config {
+	cfg := DefaultConfig()
+	cfg.WepPage = site + "/"
+	cfg.Workers = 2
+	cfg.HostDelay = 0
+	cfg.RetryMaxAttempts = 1
+	return cfg
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		expectedError string
+	}{
+		{
+			name: "success",
+			opts: []Option{WithURL("https://mock-site.com/"), WithWorkers(3)},
+		},
+		{
+			name:          "site not set",
+			opts:          []Option{WithWorkers(3)},
+			expectedError: "the site to crawl is not set",
+		},
+		{
+			name:          "relative site URL",
+			opts:          []Option{WithURL("mock-site.com/page")},
+			expectedError: `invalid site URL provided: "mock-site.com/page"`,
+		},
+		{
+			name:          "invalid number of workers",
+			opts:          []Option{WithURL("https://mock-site.com/"), WithWorkers(0)},
+			expectedError: "invalid number of workers: 0",
+		},
+		{
+			name:          "config replaces the previous options",
+			opts:          []Option{WithURL("https://mock-site.com/"), WithConfig(Config{Workers: 1})},
+			expectedError: "the site to crawl is not set",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(tc.opts...)
+			if tc.expectedError != "" {
+				if assert.NotNil(t, err) {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+				assert.Nil(t, c)
+				return
+			}
+			assert.Nil(t, err)
+			assert.NotNil(t, c)
+		})
+	}
+}
+
+func TestCrawlerRun(t *testing.T) {
+	site := newTestSite()
+	defer site.Close()
+
+	var mx sync.Mutex
+	onPage, links, errs := []string{}, []LinkResult{}, []error{}
+	c, err := New(WithConfig(testConfig(site.URL)), WithResults(10),
+		OnPage(func(page PageResult) {
+			mx.Lock()
+			defer mx.Unlock()
+			onPage = append(onPage, page.URL)
+		}),
+		OnLink(func(link LinkResult) {
+			mx.Lock()
+			defer mx.Unlock()
+			links = append(links, link)
+		}),
+		OnError(func(err error) {
+			mx.Lock()
+			defer mx.Unlock()
+			errs = append(errs, err)
+		}))
+	assert.Nil(t, err)
+	crawl, err := c.Run(context.Background())
+	assert.Nil(t, err)
+
+	titles := map[string]string{}
+	for page := range crawl.Results() {
+		titles[page.URL] = page.Title
+	}
+	stats, err := crawl.Wait()
+	assert.Nil(t, err)
+
+	expected := map[string]string{site.URL + "/": "", site.URL + "/a": "A", site.URL + "/b": "B", site.URL + "/broken": ""}
+	assert.Equal(t, expected, titles)
+	sort.Strings(onPage)
+	assert.Equal(t, []string{site.URL + "/", site.URL + "/a", site.URL + "/b", site.URL + "/broken"}, onPage)
+	assert.Equal(t, 4, stats.Found)
+	assert.Equal(t, 4, stats.Processed)
+	assert.Equal(t, 0, stats.ResultsDropped)
+	assert.Greater(t, stats.Elapsed, time.Duration(0))
+	// the links between the pages of the site are passed, including the ones to visited pages
+	assert.Contains(t, links, LinkResult{From: site.URL + "/b", To: site.URL + "/a", Text: "a", Tag: "a", Attr: "href"})
+	assert.Len(t, links, 5)
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "500 Internal Server Error")
+		failure := &FetchError{}
+		if assert.True(t, errors.As(errs[0], &failure)) {
+			assert.Equal(t, http.StatusInternalServerError, failure.Page.StatusCode)
+			assert.Equal(t, site.URL+"/broken", failure.Page.Item.URL)
+		}
+	}
+}
+
+func TestCrawlerRunLimits(t *testing.T) {
+	site := newTestSite()
+	defer site.Close()
+
+	t.Run("max pages, results not read", func(t *testing.T) {
+		c, err := New(WithConfig(testConfig(site.URL)), WithWorkers(1), WithMaxPages(2))
+		assert.Nil(t, err)
+		crawl, err := c.Run(context.Background())
+		assert.Nil(t, err)
+		stats, err := crawl.Wait()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, stats.Processed)
+		assert.Equal(t, stats.Found-2, stats.Dropped)
+	})
+
+	t.Run("results not read", func(t *testing.T) {
+		c, err := New(WithConfig(testConfig(site.URL)), WithResults(1))
+		assert.Nil(t, err)
+		crawl, err := c.Run(context.Background())
+		assert.Nil(t, err)
+		// the crawl is over without the results being read, the ones past the buffer are dropped
+		stats, err := crawl.Wait()
+		assert.Nil(t, err)
+		assert.Equal(t, 4, stats.Processed)
+		assert.Equal(t, 3, stats.ResultsDropped)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c, err := New(WithConfig(testConfig(site.URL)), WithWorkers(1), OnPage(func(PageResult) { cancel() }))
+		assert.Nil(t, err)
+		crawl, err := c.Run(ctx)
+		assert.Nil(t, err)
+		stats, err := crawl.Wait()
+		assert.Equal(t, context.Canceled, err)
+		assert.Less(t, stats.Processed, stats.Found)
+	})
+}
+
+func TestCrawlerRunWithStore(t *testing.T) {
+	site := newTestSite()
+	defer site.Close()
+	crawlStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+	assert.Nil(t, crawlStore.StoreData("", models.SiteStore{Sites: map[string]bool{}}))
+
+	c, err := New(WithConfig(testConfig(site.URL)), WithStore(crawlStore))
+	assert.Nil(t, err)
+	crawl, err := c.Run(context.Background())
+	assert.Nil(t, err)
+	_, err = crawl.Wait()
+	assert.Nil(t, err)
+
+	// the store is left open with the records of the crawl
+	pages, err := crawlStore.Pages()
+	assert.Nil(t, err)
+	assert.Len(t, pages, 4)
+	failed, err := crawlStore.FailedPages()
+	assert.Nil(t, err)
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, http.StatusInternalServerError, failed[0].StatusCode)
+	}
+}
+
+func TestCrawlerRunCheckpointedStore(t *testing.T) {
+	site := newTestSite()
+	defer site.Close()
+	cfg := testConfig(site.URL)
+	cfg.StoreBackend = "bloom"
+	cfg.CheckpointDir = t.TempDir()
+
+	// the visited links of an unverified Bloom filter cannot be checkpointed
+	c, err := New(WithConfig(cfg))
+	assert.Nil(t, err)
+	crawl, err := c.Run(context.Background())
+	assert.Nil(t, crawl)
+	assert.EqualError(t, err, "the store cannot list its visited links to checkpoint them, the bloom backend needs BLOOM_VERIFY_DIR")
+
+	cfg.BloomVerifyDir = t.TempDir()
+	c, err = New(WithConfig(cfg))
+	assert.Nil(t, err)
+	crawl, err = c.Run(context.Background())
+	assert.Nil(t, err)
+	stats, err := crawl.Wait()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, stats.Processed)
+}
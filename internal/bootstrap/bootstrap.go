@@ -1,19 +1,43 @@
 package boot
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
-	"sync"
 
 	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/broker"
+	"github.com/csrar/crawler/pkg/checkpoint"
 	"github.com/csrar/crawler/pkg/config"
+	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/frontier"
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
 	"github.com/csrar/crawler/pkg/store"
-	"github.com/dsnet/golib/memfile"
+	"github.com/redis/go-redis/v9"
 )
 
 type Ibootstrap interface {
 	BoostrapStore() (store.ICrawlerStore, error)
 	BootsRootPage() (*url.URL, error)
+	BootstrapHTTPClient() (*http.Client, error)
+	BootstrapFetcher(client *http.Client) crawler.IFetcher
+	BootstrapRobots(ctx context.Context, client *http.Client, page *url.URL) (robots.IRobots, error)
+	BootstrapNormalizer() crawler.INormalizer
+	BootstrapExtractor() crawler.IExtractor
+	BootstrapAssetChecker(log logger.Ilogger, store store.ICrawlerStore, normalizer crawler.INormalizer,
+		fetcher crawler.IFetcher, page *url.URL, rules robots.IRobots, scheduler politeness.IScheduler) crawler.IAssetChecker
+	BootstrapSitemapLinks(ctx context.Context, client *http.Client, page *url.URL, rules robots.IRobots, store store.ICrawlerStore,
+		normalizer crawler.INormalizer) ([]string, error)
+	BootstrapSeeds(ctx context.Context, log logger.Ilogger, client *http.Client, page *url.URL, rules robots.IRobots,
+		store store.ICrawlerStore, normalizer crawler.INormalizer) []models.CrawlItem
+	BootstrapScheduler(rules robots.IRobots) politeness.IScheduler
+	BootstrapCheckpointer(resumeDir string) (checkpoint.ICheckpointer, error)
+	BootstrapFrontier() (frontier.IFrontier, error)
+	BootstrapBroker() (broker.IBroker, error)
 	BootstrapChannels() *models.CommunitationChans
 	StartWorkersQueue(workers chan int)
 }
@@ -29,16 +53,9 @@ func NewBootstrap(config config.IConfig) Ibootstrap {
 	}
 }
 
-// BoostrapStore initializes and configures the crawler store.
+// BoostrapStore initializes and configures the crawler store of the STORE_BACKEND backend.
 func (b boot) BoostrapStore() (store.ICrawlerStore, error) {
-	store := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}))
-	err := store.StoreData("", models.SiteStore{
-		Sites: map[string]bool{},
-	})
-	if err != nil {
-		return nil, err
-	}
-	return store, nil
+	return store.New(b.config.GetConfig())
 }
 
 // BootsRootPage parses and validates the root page URL from the configuration.
@@ -50,10 +67,161 @@ func (b boot) BootsRootPage() (*url.URL, error) {
 	return page, nil
 }
 
+// BootstrapHTTPClient creates the HTTP client every request is made with from the timeouts,
+// headers, proxy and TLS configuration.
+func (b boot) BootstrapHTTPClient() (*http.Client, error) {
+	return crawler.NewHTTPClient(b.config.GetConfig())
+}
+
+// BootstrapFetcher creates the fetcher crawlers download pages with, retrying transient failures.
+func (b boot) BootstrapFetcher(client *http.Client) crawler.IFetcher {
+	cfg := b.config.GetConfig()
+	return crawler.NewRetryFetcher(crawler.NewFetcher(client), crawler.RetryPolicy{
+		MaxAttempts: cfg.RetryMaxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	})
+}
+
+// BootstrapRobots downloads and parses the robots.txt rules for the root page host, the rules are
+// returned along with the error when robots.txt could not be fetched.
+func (b boot) BootstrapRobots(ctx context.Context, client *http.Client, page *url.URL) (robots.IRobots, error) {
+	return robots.Fetch(ctx, client, page, b.config.GetConfig().UserAgent)
+}
+
+// BootstrapNormalizer creates the URL normalizer that builds the store keys.
+func (b boot) BootstrapNormalizer() crawler.INormalizer {
+	return crawler.NewNormalizer(b.config.GetConfig().DropQueryParams)
+}
+
+// BootstrapExtractor creates the link extractor for the configured link sources.
+func (b boot) BootstrapExtractor() crawler.IExtractor {
+	return crawler.NewExtractor(b.config.GetConfig().LinkSources)
+}
+
+// BootstrapAssetChecker creates the asset checker when ASSET_MODE is enabled, nil otherwise. It checks
+// the assets on ASSET_WORKERS goroutines of its own and has to be shut down once the crawl is over,
+// the assets of the host of page are requested as the rules and the scheduler allow.
+func (b boot) BootstrapAssetChecker(log logger.Ilogger, store store.ICrawlerStore, normalizer crawler.INormalizer,
+	fetcher crawler.IFetcher, page *url.URL, rules robots.IRobots, scheduler politeness.IScheduler) crawler.IAssetChecker {
+	cfg := b.config.GetConfig()
+	if !cfg.AssetMode {
+		return nil
+	}
+	return crawler.NewAssetChecker(log, store, normalizer, fetcher, page, rules, scheduler, cfg.AssetMaxBytes, cfg.AssetWorkers,
+		cfg.AssetQueueSize)
+}
+
+// BootstrapSitemapLinks collects the allowed and not yet visited links listed in the robots.txt sitemaps.
+func (b boot) BootstrapSitemapLinks(ctx context.Context, client *http.Client, page *url.URL, rules robots.IRobots, store store.ICrawlerStore,
+	normalizer crawler.INormalizer) ([]string, error) {
+	links := []string{}
+	var errs []error
+	for _, sitemap := range rules.Sitemaps() {
+		// the links of the child sitemaps that could be fetched are kept
+		sitemapLinks, err := robots.FetchSitemap(ctx, client, sitemap)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, link := range sitemapLinks {
+			linkURL, err := url.Parse(link)
+			if err != nil {
+				continue
+			}
+			// the link is queued as listed, its canonical form is only checked and deduplicated
+			canonical := normalizer.Normalize(linkURL)
+			if canonical.Host != normalizer.Normalize(page).Host || canonical.Path == "/" {
+				continue
+			}
+			linkURL.Fragment = ""
+			linkURL.RawFragment = ""
+			visited, err := store.WasAlreadyVisited(normalizer.Key(linkURL))
+			if err != nil {
+				return nil, err
+			}
+			if visited || !rules.IsAllowed(linkURL) {
+				continue
+			}
+			links = append(links, linkURL.String())
+		}
+	}
+	return links, errors.Join(errs...)
+}
+
+// BootstrapSeeds returns the links the crawl starts from, the root page and the links listed in the
+// robots.txt sitemaps. The sitemaps that could not be read are logged as warnings.
+func (b boot) BootstrapSeeds(ctx context.Context, log logger.Ilogger, client *http.Client, page *url.URL, rules robots.IRobots,
+	store store.ICrawlerStore, normalizer crawler.INormalizer) []models.CrawlItem {
+	sitemapLinks, err := b.BootstrapSitemapLinks(ctx, client, page, rules, store, normalizer)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+	seeds := []models.CrawlItem{{URL: page.String()}}
+	for _, link := range sitemapLinks {
+		seeds = append(seeds, models.CrawlItem{URL: link, Depth: 1, Parent: page.String()})
+	}
+	return seeds
+}
+
+// BootstrapScheduler creates the per host politeness scheduler, robots.txt Crawl-delay
+// takes precedence over the configured delay and limits requests to one at a time.
+func (b boot) BootstrapScheduler(rules robots.IRobots) politeness.IScheduler {
+	delay := b.config.GetConfig().HostDelay
+	concurrency := b.config.GetConfig().HostConcurrency
+	if rules.CrawlDelay() > 0 {
+		delay = rules.CrawlDelay()
+		concurrency = 1
+	}
+	return politeness.NewScheduler(delay, concurrency)
+}
+
+// BootstrapCheckpointer creates the checkpointer of the crawl, writing to the directory it is resumed
+// from or to CHECKPOINT_DIR, nil when there is neither.
+func (b boot) BootstrapCheckpointer(resumeDir string) (checkpoint.ICheckpointer, error) {
+	dir := resumeDir
+	if dir == "" {
+		dir = b.config.GetConfig().CheckpointDir
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	return checkpoint.NewCheckpointer(dir)
+}
+
+// BootstrapFrontier creates the frontier of the links left to crawl of the FRONTIER_BACKEND backend,
+// the disk one spills to FRONTIER_DIR past FRONTIER_MEMORY_ITEMS links and the redis one is shared
+// by the processes using the same REDIS_URL and REDIS_KEY_PREFIX, holding a REDIS_LEASE_MS lease.
+func (b boot) BootstrapFrontier() (frontier.IFrontier, error) {
+	cfg := b.config.GetConfig()
+	switch cfg.FrontierBackend {
+	case "disk":
+		return frontier.NewFrontier(cfg.FrontierDir, cfg.FrontierMemoryItems)
+	case "redis":
+		options, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URL: %w", err)
+		}
+		client := redis.NewClient(options)
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("error connecting to redis: %w", err)
+		}
+		return frontier.NewRedisFrontier(client, cfg.RedisKeyPrefix, cfg.RedisPollInterval, cfg.RedisLeaseTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown frontier backend %q, available backends: disk, redis", cfg.FrontierBackend)
+	}
+}
+
+// BootstrapBroker connects to the NATS server of QUEUE_URL the coordinator and the workers of a
+// distributed crawl talk through, under QUEUE_SUBJECT_PREFIX.
+func (b boot) BootstrapBroker() (broker.IBroker, error) {
+	cfg := b.config.GetConfig()
+	return broker.NewNATSBroker(cfg.QueueURL, cfg.QueueSubjectPrefix, cfg.QueueAckTimeout)
+}
+
 // BootstrapChannels creates communication channels for the crawler.
 func (b boot) BootstrapChannels() *models.CommunitationChans {
 	return &models.CommunitationChans{
-		Queue:    make(chan string, b.config.GetConfig().QueueSize),
 		Workers:  make(chan int, b.config.GetConfig().Workers),
 		Finished: make(chan int),
 	}
@@ -1,17 +1,224 @@
 package models
 
+import "time"
+
 type Config struct {
-	Workers   int
-	WepPage   string
-	QueueSize int
+	Workers         int
+	WepPage         string
+	UserAgent       string
+	HostDelay       time.Duration
+	HostConcurrency int
+	ShutdownTimeout time.Duration
+	MaxDepth        int
+	MaxPages        int
+	MaxDuration     time.Duration
+	DropQueryParams []string
+	LinkSources     []string
+	AssetMode       bool
+	AssetMaxBytes   int64
+	// assets are checked by AssetWorkers goroutines of their own, up to AssetQueueSize waiting
+	AssetWorkers    int
+	AssetQueueSize  int
+	HonorDirectives bool
+	// content types of the responses parsed as pages
+	ContentTypes []string
+	HeadProbe    bool
+	MaxBodyBytes int64
+	// StoreBackend is the name of the store implementation, each one uses its own options below
+	StoreBackend string
+	// StoreDir is where the file store is kept
+	StoreDir          string
+	StoreCompactEvery int
+	// StoreSnapshotInterval is how often the memory store writes its visited set to its file
+	StoreSnapshotInterval time.Duration
+	// SQLitePath is the database file of the sqlite store
+	SQLitePath string
+	// the bloom store keeps the visited links in a Bloom filter, verified against the exact set
+	// kept in BloomVerifyDir if any
+	BloomFalsePositiveRate float64
+	BloomCapacity          int
+	BloomVerifyDir         string
+	// FrontierBackend is the name of the frontier implementation, the disk frontier keeps up to
+	// FrontierMemoryItems links left to crawl in memory, the rest in FrontierDir or a temporary
+	// directory when it is empty
+	FrontierBackend     string
+	FrontierDir         string
+	FrontierMemoryItems int
+	// the redis store and frontier keep their keys under RedisKeyPrefix, the frontier checks for
+	// the links pushed by other processes every RedisPollInterval and queues again the links of the
+	// processes that did not renew their lease for RedisLeaseTimeout
+	RedisURL          string
+	RedisKeyPrefix    string
+	RedisPollInterval time.Duration
+	RedisLeaseTimeout time.Duration
+	// the coordinator and the workers of a distributed crawl talk through the NATS server of
+	// QueueURL under QueueSubjectPrefix, the links not crawled within QueueAckTimeout by the
+	// worker they were handed to are handed to another one
+	QueueURL           string
+	QueueSubjectPrefix string
+	QueueAckTimeout    time.Duration
+	// the crawl is checkpointed to CheckpointDir every CheckpointInterval when it is set
+	CheckpointDir      string
+	CheckpointInterval time.Duration
+	// HTTP client options
+	ConnectTimeout        time.Duration
+	ReadTimeout           time.Duration
+	RequestTimeout        time.Duration
+	ExtraHeaders          map[string]string
+	ProxyURL              string
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+	// retry policy of failed requests
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
 }
 
 type SiteStore struct {
 	Sites map[string]bool `json:"sites"`
 }
 
+// CrawlItem is a link waiting to be crawled, Depth is the number of links followed from the root page
+// and Tag and Attr tell where the link was found in the parent page.
+type CrawlItem struct {
+	URL    string
+	Depth  int
+	Parent string
+	Tag    string
+	Attr   string
+}
+
+// Link is a raw link found in a document along with the tag and attribute it came from.
+type Link struct {
+	Href string
+	Tag  string
+	Attr string
+}
+
 type CommunitationChans struct {
-	Queue    chan string
-	Workers  chan int
+	Workers chan int
+	// Finished receives the number of links queued by a crawler once it is done with its page
 	Finished chan int
 }
+
+// AssetResult is the outcome of checking an asset (image, script, stylesheet...) referenced by Parent.
+type AssetResult struct {
+	URL         string
+	Parent      string
+	Tag         string
+	Attr        string
+	StatusCode  int
+	ContentType string
+	// Size is the Content-Length of the asset, -1 when the server did not report it
+	Size      int64
+	Error     string
+	Broken    bool
+	Oversized bool
+}
+
+// PageResult is the record of a page fetch.
+type PageResult struct {
+	URL    string
+	Depth  int
+	Parent string
+	// FinalURL is the URL the page was served from after following the Redirects
+	FinalURL        string
+	Redirects       []Redirect
+	OffHostRedirect bool
+	StatusCode      int
+	ContentType     string
+	// Charset is the character set the page was decoded from and Title the text of its <title>,
+	// set for parsed pages
+	Charset string
+	Title   string
+	// ContentLength is the size of the body, -1 when unknown
+	ContentLength int64
+	FetchedAt     time.Time
+	ResponseTime  time.Duration
+	// Truncated is set when only the first MaxBodyBytes of the body were parsed
+	Truncated bool
+	Error     string
+}
+
+// LinkResult is a link from a crawled page to another page of the site, Text is the anchor text of
+// the <a> tags.
+type LinkResult struct {
+	From string
+	To   string
+	Text string
+	Tag  string
+	Attr string
+}
+
+// CrawlJob is a link handed by the coordinator of a distributed crawl to a worker.
+type CrawlJob struct {
+	ID   string    `json:"id"`
+	Item CrawlItem `json:"item"`
+}
+
+// CrawlResult is what a worker found crawling the link of the job ID: the links to crawl next and
+// the records of the page.
+type CrawlResult struct {
+	ID       string        `json:"id"`
+	Links    []CrawlItem   `json:"links"`
+	Pages    []PageResult  `json:"pages"`
+	Failures []FailedPage  `json:"failures"`
+	Noindex  []string      `json:"noindex"`
+	Assets   []AssetResult `json:"assets"`
+	// PageLinks are the links of the page to the other pages of the site, with their anchor text
+	PageLinks []LinkResult `json:"page_links"`
+	// Blocked and AssetsBlocked are the links and assets of the page rejected by robots.txt
+	Blocked       int `json:"blocked"`
+	AssetsBlocked int `json:"assets_blocked"`
+}
+
+// Redirect is a hop of a redirect chain, the URL answered with StatusCode pointing to Location.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// FilterStats describes the probabilistic visited set of a store.
+type FilterStats struct {
+	Keys    int
+	Filters int
+	// MemoryBytes is the size of the filters
+	MemoryBytes int64
+	// FalsePositiveRate is the estimated probability of an unvisited link being reported as visited
+	FalsePositiveRate float64
+	// Verified links were reported as visited by the filter and checked against the exact set,
+	// FalsePositives of them were not visited
+	Verified       int
+	FalsePositives int
+}
+
+// FailedPage is a page that could not be crawled after retrying, Item can be queued again.
+type FailedPage struct {
+	Item       CrawlItem
+	StatusCode int
+	Error      string
+}
+
+type CrawlStats struct {
+	Found     int
+	Processed int
+	Dropped   int
+	// Blocked and AssetsBlocked are the links and assets rejected by robots.txt, only counted by
+	// the coordinator of a distributed crawl from the results of the workers
+	Blocked       int
+	AssetsBlocked int
+}
+
+// Checkpoint is the state of a crawl it can be resumed from: the links left to crawl, the visited
+// keys, the link counters and the pages that failed, crawled again when resuming.
+type Checkpoint struct {
+	Site     string       `json:"site"`
+	Time     time.Time    `json:"time"`
+	Frontier []CrawlItem  `json:"frontier"`
+	Visited  []string     `json:"visited"`
+	Stats    CrawlStats   `json:"stats"`
+	Failed   []FailedPage `json:"failed"`
+}
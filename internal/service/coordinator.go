@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/broker"
+	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/store"
+)
+
+// coordinator hands the links of a distributed crawl to the workers through the broker and keeps
+// the results they send back in the store, queueing the links found that were not visited yet.
+// Every link handed out is pending until its result arrives, the crawl is done when none is left.
+// A result arriving twice, when a job was crawled again after its worker missed the ack timeout,
+// is only counted once. The politeness scheduler is shared by every worker: a job is only handed
+// out once a request to its host is allowed, and holds the host slot until its result arrives.
+type coordinator struct {
+	cfg        models.Config
+	broker     broker.IBroker
+	log        logger.Ilogger
+	store      store.ICrawlerStore
+	normalizer crawler.INormalizer
+	scheduler  politeness.IScheduler
+	// run tells the jobs of this crawl apart from the ones left in the broker by another one
+	run string
+
+	mx         sync.Mutex
+	jobs       int
+	pending    map[string]models.CrawlItem
+	dispatched int
+	seeded     bool
+	stats      models.CrawlStats
+	done       chan struct{}
+	// queued are the pending jobs waiting for their host slot, wake is signaled when one is queued
+	queued []models.CrawlJob
+	wake   chan struct{}
+}
+
+type ICoordinator interface {
+	Seed(items ...models.CrawlItem)
+	ListenForResults(ctx context.Context)
+	Done() <-chan struct{}
+	Stats() models.CrawlStats
+	Pending() []models.CrawlItem
+}
+
+// NewCoordinator creates a coordinator recording the crawl in store, handing out the jobs as the
+// scheduler allows requests to their host.
+func NewCoordinator(cfg models.Config, messages broker.IBroker, log logger.Ilogger, store store.ICrawlerStore,
+	normalizer crawler.INormalizer, scheduler politeness.IScheduler) ICoordinator {
+	return &coordinator{
+		cfg:        cfg,
+		broker:     messages,
+		log:        log,
+		store:      store,
+		normalizer: normalizer,
+		scheduler:  scheduler,
+		run:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		pending:    map[string]models.CrawlItem{},
+		done:       make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Seed hands out the links the crawl starts from.
+func (c *coordinator) Seed(items ...models.CrawlItem) {
+	c.mx.Lock()
+	c.stats.Found += len(items)
+	c.mx.Unlock()
+	for _, item := range items {
+		c.publish(item)
+	}
+	c.mx.Lock()
+	c.seeded = true
+	c.mx.Unlock()
+	c.finishIfIdle()
+}
+
+// Done returns a channel that is closed once the result of every link handed out arrived, or the
+// results stopped being listened for.
+func (c *coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stats returns the link counters of the crawl.
+func (c *coordinator) Stats() models.CrawlStats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.stats
+}
+
+// Pending returns the links handed out whose result did not arrive.
+func (c *coordinator) Pending() []models.CrawlItem {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	items := make([]models.CrawlItem, 0, len(c.pending))
+	for _, item := range c.pending {
+		items = append(items, item)
+	}
+	return items
+}
+
+// ListenForResults hands out the jobs and records the results sent by the workers until the crawl
+// is done or the context is done. The jobs and results left in the broker by a previous crawl are
+// removed first, so the workers do not fetch links this crawl did not hand out.
+func (c *coordinator) ListenForResults(ctx context.Context) {
+	for _, topic := range []string{broker.JobsTopic, broker.ResultsTopic} {
+		if err := c.broker.Purge(topic); err != nil {
+			c.log.Error(err)
+			c.finish()
+			return
+		}
+	}
+	results, err := c.broker.Consume(ctx, broker.ResultsTopic)
+	if err != nil {
+		c.log.Error(err)
+		c.finish()
+		return
+	}
+	go c.handOut(ctx)
+	for {
+		select {
+		case message, ok := <-results:
+			if !ok {
+				// shutting down, the pending links are left in the broker
+				c.finish()
+				return
+			}
+			c.handle(message)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// handle records the result and hands out the links found, it is acked once they are published
+// so a result is not lost when the coordinator stops halfway.
+func (c *coordinator) handle(message broker.IMessage) {
+	result := models.CrawlResult{}
+	if err := json.Unmarshal(message.Data(), &result); err != nil {
+		c.log.Error(fmt.Errorf("error decoding crawl result, it is dropped: %w", err))
+		c.ack(message)
+		return
+	}
+	c.mx.Lock()
+	item, ok := c.pending[result.ID]
+	c.mx.Unlock()
+	if !ok {
+		// the job was crawled again or belongs to another crawl
+		c.ack(message)
+		return
+	}
+	c.record(result)
+	found := 0
+	for _, item := range result.Links {
+		linkURL, err := url.Parse(item.URL)
+		if err != nil {
+			continue
+		}
+		visited, err := c.store.WasAlreadyVisited(c.normalizer.Key(linkURL))
+		if err != nil {
+			c.log.Error(err)
+			continue
+		}
+		if visited {
+			continue
+		}
+		found++
+		c.publish(item)
+	}
+	c.mx.Lock()
+	delete(c.pending, result.ID)
+	c.stats.Found += found
+	c.stats.Processed++
+	c.stats.Blocked += result.Blocked
+	c.stats.AssetsBlocked += result.AssetsBlocked
+	c.mx.Unlock()
+	c.scheduler.Release(linkHost(item.URL))
+	c.ack(message)
+	c.finishIfIdle()
+}
+
+// record keeps the records of the crawled page in the store.
+func (c *coordinator) record(result models.CrawlResult) {
+	for _, page := range result.Pages {
+		if err := c.store.RecordPage(page); err != nil {
+			c.log.Error(err)
+		}
+	}
+	for _, page := range result.Failures {
+		if err := c.store.RecordFailure(page); err != nil {
+			c.log.Error(err)
+		}
+	}
+	for _, page := range result.Noindex {
+		if err := c.store.RecordNoindex(page); err != nil {
+			c.log.Error(err)
+		}
+	}
+	for _, asset := range result.Assets {
+		if err := c.store.RecordAsset(asset); err != nil {
+			c.log.Error(err)
+		}
+	}
+	if links, ok := c.store.(store.ILinkRecorder); ok && len(result.PageLinks) > 0 {
+		if err := links.RecordLinks(result.PageLinks); err != nil {
+			c.log.Error(err)
+		}
+	}
+}
+
+// publish queues the link to be handed to the workers within the MaxPages budget, counting it as
+// pending, it is dropped when it does not fit.
+func (c *coordinator) publish(item models.CrawlItem) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.cfg.MaxPages > 0 && c.dispatched >= c.cfg.MaxPages {
+		c.stats.Dropped++
+		return
+	}
+	c.dispatched++
+	c.jobs++
+	id := c.run + "-" + strconv.Itoa(c.jobs)
+	c.pending[id] = item
+	c.queued = append(c.queued, models.CrawlJob{ID: id, Item: item})
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// handOut publishes the queued jobs in order once the scheduler allows a request to their host,
+// until the crawl or the context is done. A job that cannot be published is dropped.
+func (c *coordinator) handOut(ctx context.Context) {
+	for {
+		job, ok := c.nextJob()
+		if !ok {
+			select {
+			case <-c.wake:
+				continue
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			}
+		}
+		host := linkHost(job.Item.URL)
+		if err := c.scheduler.Acquire(ctx, host); err != nil {
+			// shutting down, the job is left pending
+			return
+		}
+		payload, err := json.Marshal(job)
+		if err == nil {
+			err = c.broker.Publish(broker.JobsTopic, payload)
+		}
+		if err != nil {
+			c.log.Error(fmt.Errorf("error handing out %s, it is dropped: %w", job.Item.URL, err))
+			c.scheduler.Release(host)
+			c.mx.Lock()
+			delete(c.pending, job.ID)
+			c.stats.Dropped++
+			c.mx.Unlock()
+			c.finishIfIdle()
+		}
+	}
+}
+
+// nextJob removes the oldest queued job, reporting false when there is none.
+func (c *coordinator) nextJob() (models.CrawlJob, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if len(c.queued) == 0 {
+		return models.CrawlJob{}, false
+	}
+	job := c.queued[0]
+	c.queued = c.queued[1:]
+	return job, true
+}
+
+// linkHost returns the host the link is requested from, the link itself when it cannot be parsed.
+func linkHost(link string) string {
+	if linkURL, err := url.Parse(link); err == nil {
+		return linkURL.Host
+	}
+	return link
+}
+
+// ack acks the result, it is handed back after the ack timeout when it fails and ignored then.
+func (c *coordinator) ack(message broker.IMessage) {
+	if err := message.Ack(); err != nil {
+		c.log.Error(fmt.Errorf("error settling crawl result: %w", err))
+	}
+}
+
+// finishIfIdle ends the crawl once the seeds were handed out and no link is pending.
+func (c *coordinator) finishIfIdle() {
+	c.mx.Lock()
+	idle := c.seeded && len(c.pending) == 0
+	c.mx.Unlock()
+	if idle {
+		c.finish()
+	}
+}
+
+// finish closes done once.
+func (c *coordinator) finish() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
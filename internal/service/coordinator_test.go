@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/broker"
+	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
+	"github.com/csrar/crawler/pkg/store"
+	"github.com/dsnet/golib/memfile"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCoordinator creates a coordinator recording the crawl in a memory store, allowing
+// HostConcurrency requests to a host at a time, 100 when it is not set.
+func newTestCoordinator(t *testing.T, cfg models.Config, messages broker.IBroker) (ICoordinator, store.ICrawlerStore) {
+	crawlerStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+	assert.Nil(t, crawlerStore.StoreData("", models.SiteStore{Sites: map[string]bool{}}))
+	concurrency := cfg.HostConcurrency
+	if concurrency == 0 {
+		concurrency = 100
+	}
+	scheduler := politeness.NewScheduler(cfg.HostDelay, concurrency)
+	return NewCoordinator(cfg, messages, newLogMock(t), crawlerStore, crawler.NewNormalizer(nil), scheduler), crawlerStore
+}
+
+// purgeBroker closes purged once the jobs topic was purged.
+type purgeBroker struct {
+	broker.IBroker
+	purged chan struct{}
+}
+
+func (b *purgeBroker) Purge(topic string) error {
+	err := b.IBroker.Purge(topic)
+	if topic == broker.JobsTopic {
+		close(b.purged)
+	}
+	return err
+}
+
+// newTestWorker creates a worker crawling with workers goroutines.
+func newTestWorker(t *testing.T, cfg models.Config, workers int, messages broker.IBroker) IWorker {
+	cfg.Workers = workers
+	return NewWorker(cfg, messages, newLogMock(t), robots.NewAllowAll(), crawler.NewNormalizer(nil), crawler.NewExtractor([]string{"a"}), crawler.NewFetcher(http.DefaultClient),
+		func(store.ICrawlerStore, robots.IRobots) crawler.IAssetChecker { return nil })
+}
+
+func TestCrawlDistributed(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		pages    int
+		maxPages int
+		// a worker takes crashed jobs and stops without settling them, the other ones crawl
+		// them once the ack timeout passed
+		crashed int
+	}{
+		{
+			name:    "single worker",
+			workers: 1,
+			pages:   50,
+		},
+		{
+			name:    "several workers",
+			workers: 3,
+			pages:   200,
+		},
+		{
+			name:    "crashed worker",
+			workers: 2,
+			pages:   100,
+			crashed: 5,
+		},
+		{
+			name:     "max pages",
+			workers:  3,
+			pages:    200,
+			maxPages: 20,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer, requests := newCountingServer(tc.pages, time.Millisecond)
+			defer testServer.Close()
+			cfg := models.Config{WepPage: testServer.URL + "/", ShutdownTimeout: 5 * time.Second, MaxPages: tc.maxPages}
+			messages := broker.NewMemoryBroker(time.Second)
+			defer messages.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tc.crashed > 0 {
+				crashed, crash := context.WithCancel(ctx)
+				jobs, err := messages.Consume(crashed, broker.JobsTopic)
+				assert.Nil(t, err)
+				go func() {
+					for i := 0; i < tc.crashed; i++ {
+						<-jobs
+					}
+					crash()
+				}()
+			}
+			workers := make([]IWorker, tc.workers)
+			var wg sync.WaitGroup
+			for i := range workers {
+				workers[i] = newTestWorker(t, cfg, 5, messages)
+				wg.Add(1)
+				go func(worker IWorker) {
+					defer wg.Done()
+					assert.Nil(t, worker.Run(ctx))
+				}(workers[i])
+			}
+
+			coordinator, crawlerStore := newTestCoordinator(t, cfg, messages)
+			go coordinator.ListenForResults(ctx)
+			coordinator.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+			select {
+			case <-coordinator.Done():
+			case <-time.After(30 * time.Second):
+				t.Fatal("crawl did not finish")
+			}
+			cancel()
+			wg.Wait()
+
+			expected := tc.pages + 1
+			if tc.maxPages > 0 {
+				expected = tc.maxPages
+			}
+			stats := coordinator.Stats()
+			assert.Equal(t, expected, stats.Processed)
+			assert.Empty(t, coordinator.Pending())
+			pages, err := crawlerStore.Pages()
+			assert.Nil(t, err)
+			assert.Len(t, pages, expected)
+			if tc.maxPages > 0 {
+				assert.Equal(t, stats.Found-tc.maxPages, stats.Dropped)
+				return
+			}
+			// a page may be crawled again when it was taken by the crashed worker, its result counts once
+			processed := 0
+			for _, worker := range workers {
+				processed += worker.Stats().Processed
+			}
+			assert.Equal(t, expected, processed)
+			requests.Range(func(path, count any) bool {
+				assert.Equal(t, int32(1), count.(*atomic.Int32).Load(), path)
+				return true
+			})
+		})
+	}
+}
+
+func TestCoordinatorResults(t *testing.T) {
+	messages := broker.NewMemoryBroker(time.Minute)
+	defer messages.Close()
+	// a job left by a previous crawl is removed before the first job is handed out
+	stale, err := json.Marshal(models.CrawlJob{ID: "previous-1", Item: models.CrawlItem{URL: "https://mock-site.com/stale"}})
+	assert.Nil(t, err)
+	assert.Nil(t, messages.Publish(broker.JobsTopic, stale))
+	purging := &purgeBroker{IBroker: messages, purged: make(chan struct{})}
+	coordinator, crawlerStore := newTestCoordinator(t, models.Config{}, purging)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go coordinator.ListenForResults(ctx)
+	<-purging.purged
+	jobs, err := messages.Consume(ctx, broker.JobsTopic)
+	assert.Nil(t, err)
+
+	nextJob := func() models.CrawlJob {
+		select {
+		case message := <-jobs:
+			assert.Nil(t, message.Ack())
+			job := models.CrawlJob{}
+			assert.Nil(t, json.Unmarshal(message.Data(), &job))
+			return job
+		case <-time.After(5 * time.Second):
+			t.Fatal("no job was handed out")
+		}
+		return models.CrawlJob{}
+	}
+	sendResult := func(result models.CrawlResult) {
+		payload, err := json.Marshal(result)
+		assert.Nil(t, err)
+		assert.Nil(t, messages.Publish(broker.ResultsTopic, payload))
+	}
+
+	coordinator.Seed(models.CrawlItem{URL: "https://mock-site.com/"})
+	root := nextJob()
+	assert.Equal(t, "https://mock-site.com/", root.Item.URL)
+	assert.Len(t, coordinator.Pending(), 1)
+
+	// a result sent twice is recorded once and the links are handed out once
+	link := models.CrawlItem{URL: "https://mock-site.com/a", Depth: 1, Parent: "https://mock-site.com/"}
+	result := models.CrawlResult{
+		ID:            root.ID,
+		Links:         []models.CrawlItem{link, link},
+		Pages:         []models.PageResult{{URL: "https://mock-site.com/", StatusCode: http.StatusOK}},
+		Noindex:       []string{"https://mock-site.com/"},
+		PageLinks:     []models.LinkResult{{From: "https://mock-site.com/", To: "https://mock-site.com/a"}},
+		Blocked:       2,
+		AssetsBlocked: 1,
+	}
+	sendResult(result)
+	sendResult(result)
+	sendResult(models.CrawlResult{ID: "another-crawl-1", Links: []models.CrawlItem{{URL: "https://mock-site.com/b"}}})
+	next := nextJob()
+	assert.Equal(t, link, next.Item)
+
+	sendResult(models.CrawlResult{
+		ID:       next.ID,
+		Failures: []models.FailedPage{{Item: link, StatusCode: http.StatusBadGateway, Error: "502 Bad Gateway"}},
+		Blocked:  1,
+	})
+	select {
+	case <-coordinator.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("crawl did not finish")
+	}
+	assert.Equal(t, models.CrawlStats{Found: 2, Processed: 2, Blocked: 3, AssetsBlocked: 1}, coordinator.Stats())
+	pages, err := crawlerStore.Pages()
+	assert.Nil(t, err)
+	assert.Equal(t, result.Pages, pages)
+	noindex, err := crawlerStore.NoindexPages()
+	assert.Nil(t, err)
+	assert.Equal(t, result.Noindex, noindex)
+	failed, err := crawlerStore.FailedPages()
+	assert.Nil(t, err)
+	assert.Len(t, failed, 1)
+	select {
+	case message := <-jobs:
+		t.Fatalf("a job was handed out twice: %s", message.Data())
+	default:
+	}
+}
+
+func TestCrawlDistributedPoliteness(t *testing.T) {
+	pages := 30
+	site := newSiteServer(pages, 10*time.Millisecond)
+	defer site.Close()
+	var inFlight, maxInFlight atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for max := maxInFlight.Load(); current > max && !maxInFlight.CompareAndSwap(max, current); max = maxInFlight.Load() {
+		}
+		site.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer testServer.Close()
+	cfg := models.Config{WepPage: testServer.URL + "/", ShutdownTimeout: 5 * time.Second, HostConcurrency: 2}
+	messages := broker.NewMemoryBroker(time.Minute)
+	defer messages.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the host concurrency holds across the workers, they do not each get their own
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, newTestWorker(t, cfg, 5, messages).Run(ctx))
+		}()
+	}
+	coordinator, _ := newTestCoordinator(t, cfg, messages)
+	go coordinator.ListenForResults(ctx)
+	coordinator.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+	select {
+	case <-coordinator.Done():
+	case <-time.After(30 * time.Second):
+		t.Fatal("crawl did not finish")
+	}
+	cancel()
+	wg.Wait()
+	assert.Equal(t, pages+1, coordinator.Stats().Processed)
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestWorkerInProgress(t *testing.T) {
+	testServer, requests := newCountingServer(0, 300*time.Millisecond)
+	defer testServer.Close()
+	// the page takes longer than the ack timeout, it is reported in progress instead of crawled again
+	cfg := models.Config{WepPage: testServer.URL + "/", ShutdownTimeout: 5 * time.Second, QueueAckTimeout: 100 * time.Millisecond}
+	messages := broker.NewMemoryBroker(cfg.QueueAckTimeout)
+	defer messages.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	workers := []IWorker{newTestWorker(t, cfg, 1, messages), newTestWorker(t, cfg, 1, messages)}
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker IWorker) {
+			defer wg.Done()
+			assert.Nil(t, worker.Run(ctx))
+		}(worker)
+	}
+	coordinator, _ := newTestCoordinator(t, cfg, messages)
+	go coordinator.ListenForResults(ctx)
+	coordinator.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+	select {
+	case <-coordinator.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("crawl did not finish")
+	}
+	cancel()
+	wg.Wait()
+	count, ok := requests.Load("/")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), count.(*atomic.Int32).Load())
+	// the root page links to a missing page
+	assert.Equal(t, 2, workers[0].Stats().Processed+workers[1].Stats().Processed)
+}
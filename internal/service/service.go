@@ -1,75 +1,709 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/checkpoint"
 	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/frontier"
 	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
 	"github.com/csrar/crawler/pkg/store"
 )
 
 // crawlerHandler handles the crawling process, tracks found and processed links, and manages synchronization.
 type crawlerHandler struct {
-	found     *int
-	processed *int
-	channels  *models.CommunitationChans
-	log       logger.Ilogger
-	store     store.ICrawlerStore
-	wg        *sync.WaitGroup
-	mx        *sync.Mutex
+	cfg         models.Config
+	channels    *models.CommunitationChans
+	queue       frontier.IFrontier
+	log         logger.Ilogger
+	store       store.ICrawlerStore
+	robots      robots.IRobots
+	scheduler   politeness.IScheduler
+	normalizer  crawler.INormalizer
+	extractor   crawler.IExtractor
+	assets      crawler.IAssetChecker
+	fetcher     crawler.IFetcher
+	checkpoints checkpoint.ICheckpointer
+	fetchCtx    context.Context
+	cancelFetch context.CancelFunc
+	// timedOut is set once the in-flight fetches were cancelled by the shutdown timeout
+	timedOut atomic.Bool
+	// pause is held by the crawls while they run and by a checkpoint while it is taken, so the
+	// crawls are not halfway through queueing their links
+	pause sync.RWMutex
+	// crawls waits for the dispatched crawls, they keep the links cut by the shutdown timeout after
+	// reporting them
+	crawls sync.WaitGroup
+
+	// pending counts the links queued or being crawled and crawling the crawls that did not report
+	// their links yet, the crawl is done when both drop to zero. With a shared frontier the links
+	// queued may be popped by other processes, pending only counts the ones popped by this one and
+	// the crawl is done once the frontier is drained after the seeds were queued
+	shared     frontier.IShared
+	seeded     bool
+	mx         sync.Mutex
+	pending    int
+	crawling   int
+	dispatched int
+	// reserved counts the links queued within the MaxPages budget
+	reserved int
+	stats    models.CrawlStats
+	done     chan struct{}
+	// idle is signaled when a crawl settles
+	idle *sync.Cond
+	// waiting keeps the links dispatched to a worker that did not start crawling yet
+	waiting   map[int]models.CrawlItem
+	waitingID int
+	// interrupted keeps the links the shutdown did not let crawl, recrawled of them were cut by
+	// the shutdown timeout after being counted as processed
+	interrupted []models.CrawlItem
+	recrawled   int
 }
 
 type ICrawlerHandler interface {
-	ListenForNewLinks()
-	ValidateCrawlFinish()
+	Seed(items ...models.CrawlItem)
+	ListenForNewLinks(ctx context.Context)
+	ValidateCrawlFinish(ctx context.Context)
+	Done() <-chan struct{}
+	Stats() models.CrawlStats
+	Restore(state models.Checkpoint) error
+	Checkpoint() error
 }
 
-// NewCrawlerHandler creates a new crawlerHandler instance.
-func NewCrawlerHandler(found *int, processed *int, channels *models.CommunitationChans, log logger.Ilogger,
-	store store.ICrawlerStore, wg *sync.WaitGroup, mx *sync.Mutex) ICrawlerHandler {
-	return &crawlerHandler{
-		found:     found,
-		processed: processed,
-		channels:  channels,
-		log:       log,
-		store:     store,
-		wg:        wg,
-		mx:        mx,
+// NewCrawlerHandler creates a new crawlerHandler instance, the crawl is checkpointed every
+// CheckpointInterval unless checkpoints is nil.
+func NewCrawlerHandler(cfg models.Config, channels *models.CommunitationChans, queue frontier.IFrontier, log logger.Ilogger,
+	store store.ICrawlerStore, robots robots.IRobots, scheduler politeness.IScheduler, normalizer crawler.INormalizer, extractor crawler.IExtractor,
+	assets crawler.IAssetChecker, fetcher crawler.IFetcher, checkpoints checkpoint.ICheckpointer) ICrawlerHandler {
+	// in-flight fetches outlive the crawl context so they can finish during a graceful shutdown
+	fetchCtx, cancelFetch := context.WithCancel(context.Background())
+	shared, _ := queue.(frontier.IShared)
+	handler := &crawlerHandler{
+		shared:      shared,
+		cfg:         cfg,
+		channels:    channels,
+		queue:       queue,
+		log:         log,
+		store:       store,
+		robots:      robots,
+		scheduler:   scheduler,
+		normalizer:  normalizer,
+		extractor:   extractor,
+		assets:      assets,
+		fetcher:     fetcher,
+		checkpoints: checkpoints,
+		fetchCtx:    fetchCtx,
+		cancelFetch: cancelFetch,
+		done:        make(chan struct{}),
+		waiting:     map[int]models.CrawlItem{},
 	}
+	handler.idle = sync.NewCond(&handler.mx)
+	return handler
 }
 
-// ListenForNewLinks listens for new links in the queue and initiates crawling.
-func (c *crawlerHandler) ListenForNewLinks() {
-	for {
-		select {
-		case link := <-c.channels.Queue:
+// Seed queues the links the crawl starts from, the ones beyond the MaxPages budget are not queued.
+// A shared frontier is only seeded by the first process, the others crawl the links it queues.
+func (c *crawlerHandler) Seed(items ...models.CrawlItem) {
+	seeds := []models.CrawlItem{}
+	for _, item := range items {
+		if c.reserve() {
+			seeds = append(seeds, item)
+		}
+	}
+	if c.shared != nil {
+		c.seedShared(seeds)
+		c.markSeeded()
+		return
+	}
+	c.mx.Lock()
+	c.pending += len(seeds)
+	c.stats.Found += len(seeds)
+	c.mx.Unlock()
+	for _, item := range seeds {
+		c.push(item)
+	}
+	c.markSeeded()
+}
+
+// seedShared pushes the seeds to the shared frontier unless another process seeded it already.
+func (c *crawlerHandler) seedShared(seeds []models.CrawlItem) {
+	seeded, err := c.shared.Seed(seeds...)
+	if err != nil {
+		c.log.Error(err)
+	} else if !seeded {
+		c.log.Info("the shared frontier was seeded by another process, crawling the links it queued")
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	switch {
+	case err != nil:
+		// the seeds were not pushed, they are only counted as dropped
+		c.stats.Dropped += len(seeds)
+	case seeded:
+		c.stats.Found += len(seeds)
+	default:
+		// the seeds were found by the process that pushed them, they do not take from the budget
+		c.reserved -= len(seeds)
+	}
+}
+
+// reserve makes room for one more link in the MaxPages budget, reporting whether it fits.
+func (c *crawlerHandler) reserve() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.cfg.MaxPages > 0 && c.reserved >= c.cfg.MaxPages {
+		return false
+	}
+	c.reserved++
+	return true
+}
+
+// spent reports whether no more links fit in the MaxPages budget.
+func (c *crawlerHandler) spent() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.cfg.MaxPages > 0 && c.reserved >= c.cfg.MaxPages
+}
+
+// budgetQueue is the frontier the crawlers queue their links to, they stop queueing and extracting
+// links once the MaxPages budget is spent.
+type budgetQueue struct {
+	frontier.IFrontier
+	handler *crawlerHandler
+}
+
+func (q *budgetQueue) Reserve() bool {
+	return q.handler.reserve()
+}
+
+func (q *budgetQueue) Spent() bool {
+	return q.handler.spent()
+}
+
+// push adds the link to the frontier, dropping it when it cannot be queued.
+func (c *crawlerHandler) push(item models.CrawlItem) {
+	if err := c.queue.Push(item); err != nil {
+		c.log.Error(err)
+		if c.shared != nil {
+			// the link was not popped, it is only counted as dropped
 			c.mx.Lock()
-			*c.found++
+			c.stats.Dropped++
 			c.mx.Unlock()
-			workerID := <-c.channels.Workers
-			crawl, err := crawler.NewCrawler(workerID, link, c.channels, c.log, c.store)
+			return
+		}
+		c.settle(0, false)
+	}
+}
+
+// markSeeded lets the crawl end once the frontier is drained, when it is shared.
+func (c *crawlerHandler) markSeeded() {
+	c.mx.Lock()
+	c.seeded = true
+	c.mx.Unlock()
+}
+
+// Done returns a channel that is closed once every queued link has been crawled or dropped.
+func (c *crawlerHandler) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stats returns the link counters of the crawl.
+func (c *crawlerHandler) Stats() models.CrawlStats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.stats
+}
+
+// ListenForNewLinks listens for new links in the queue and initiates crawling until the context is done.
+func (c *crawlerHandler) ListenForNewLinks(ctx context.Context) {
+	var tick <-chan time.Time
+	if c.checkpoints != nil && c.cfg.CheckpointInterval > 0 {
+		ticker := time.NewTicker(c.cfg.CheckpointInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	// next is the link waiting for a free worker, paused is closed once the running crawls
+	// finished for a checkpoint
+	var next *models.CrawlItem
+	var paused chan struct{}
+	for {
+		var ready <-chan struct{}
+		var workers <-chan int
+		if paused == nil {
+			if next == nil {
+				var empty bool
+				next, empty = c.nextLink()
+				if empty && c.drained() {
+					close(c.done)
+					return
+				}
+			}
+			if next == nil {
+				ready = c.queue.Ready()
+			} else {
+				workers = c.channels.Workers
+			}
+		}
+		select {
+		case <-c.done:
+			c.resumeCrawls(paused)
+			return
+		case <-ctx.Done():
+			c.resumeCrawls(paused)
+			if next != nil {
+				c.interrupt(*next)
+			}
+			c.dropQueuedLinks()
+			return
+		case <-ready:
+		case workerID := <-workers:
+			c.dispatch(ctx, *next, workerID)
+			next = nil
+		case <-tick:
+			if paused == nil {
+				paused = c.pauseCrawls()
+			}
+		case <-paused:
+			c.checkpoint(next)
+			c.pause.Unlock()
+			paused = nil
+		}
+	}
+}
+
+// drained reports whether a shared frontier has no links left and no link popped by any of the
+// processes left to settle, once the seeds were queued and the crawls of this process reported.
+func (c *crawlerHandler) drained() bool {
+	if c.shared == nil {
+		return false
+	}
+	c.mx.Lock()
+	settled := c.seeded && c.pending == 0 && c.crawling == 0
+	c.mx.Unlock()
+	if !settled {
+		return false
+	}
+	drained, err := c.shared.Drained()
+	if err != nil {
+		c.log.Error(err)
+		return false
+	}
+	return drained
+}
+
+// nextLink pops the next link of the frontier within the MaxPages budget, nil when there is none,
+// reporting whether the frontier was found empty rather than unreadable. The links queued by this
+// process fit in the budget already, the ones popped from a shared frontier may not.
+func (c *crawlerHandler) nextLink() (*models.CrawlItem, bool) {
+	for {
+		item, ok, err := c.pop()
+		if err != nil {
+			c.log.Error(err)
+			return nil, false
+		}
+		if !ok {
+			return nil, true
+		}
+		if !c.admit() {
+			// the page budget is spent, the remaining links are dropped until the crawl ends
+			c.release(item)
+			c.settle(0, false)
+			continue
+		}
+		return &item, false
+	}
+}
+
+// pop removes the oldest link of the frontier, the links lost by the frontier are dropped. The
+// links popped from a shared frontier are counted as pending.
+func (c *crawlerHandler) pop() (models.CrawlItem, bool, error) {
+	for {
+		item, ok, err := c.queue.Pop()
+		lost := &frontier.SegmentError{}
+		if !errors.As(err, &lost) {
+			if ok && c.shared != nil {
+				c.mx.Lock()
+				c.pending++
+				c.mx.Unlock()
+			}
+			return item, ok, err
+		}
+		c.log.Error(err)
+		for i := 0; i < lost.Items; i++ {
+			c.settle(0, false)
+		}
+	}
+}
+
+// dispatch starts crawling the link with the worker once the politeness scheduler allows it.
+func (c *crawlerHandler) dispatch(ctx context.Context, item models.CrawlItem, workerID int) {
+	channels := c.channels
+	if c.shared != nil {
+		// a link of a shared frontier is settled once its crawl pushed the links found, before the
+		// crawl is reported
+		channels = &models.CommunitationChans{Workers: c.channels.Workers, Finished: make(chan int, 1)}
+	}
+	crawl, err := crawler.NewCrawler(workerID, item, channels, &budgetQueue{IFrontier: c.queue, handler: c}, c.log, c.fetcher, c.store, c.robots, c.normalizer, c.extractor, c.assets, c.cfg)
+	if err != nil {
+		c.log.Error(err)
+		c.channels.Workers <- workerID
+		c.release(item)
+		c.settle(0, false)
+		return
+	}
+	c.mx.Lock()
+	c.waitingID++
+	id := c.waitingID
+	c.waiting[id] = item
+	c.mx.Unlock()
+	c.crawls.Add(1)
+	go c.politeCrawl(ctx, id, item, workerID, crawl, channels.Finished)
+}
+
+// admit reports whether one more page fits in the MaxPages budget and counts it.
+func (c *crawlerHandler) admit() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.cfg.MaxPages > 0 && c.dispatched >= c.cfg.MaxPages {
+		return false
+	}
+	c.dispatched++
+	return true
+}
+
+// dropQueuedLinks discards the links of the frontier and the ones still arriving until the crawl is
+// done. The links of a shared frontier are left to the other processes, only the crawls of this
+// one are waited for.
+func (c *crawlerHandler) dropQueuedLinks() {
+	if c.shared != nil {
+		c.mx.Lock()
+		for c.pending > 0 || c.crawling > 0 {
+			c.idle.Wait()
+		}
+		c.mx.Unlock()
+		close(c.done)
+		return
+	}
+	for {
+		for {
+			item, ok, err := c.pop()
 			if err != nil {
 				c.log.Error(err)
-				return
 			}
-			go crawl.SpinUpCrawler()
+			if !ok {
+				break
+			}
+			c.interrupt(item)
+		}
+		select {
+		case <-c.queue.Ready():
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// politeCrawl waits for the politeness scheduler to allow a request to the link host before crawling it.
+// The crawl reports to finished, which is forwarded once the link of a shared frontier is settled.
+func (c *crawlerHandler) politeCrawl(ctx context.Context, id int, item models.CrawlItem, workerID int, crawl crawler.ICrawler,
+	finished chan int) {
+	defer c.crawls.Done()
+	host := item.URL
+	if linkURL, err := url.Parse(item.URL); err == nil {
+		host = linkURL.Host
+	}
+	if err := c.scheduler.Acquire(ctx, host); err != nil {
+		// shutting down before the request started, the link is dropped without fetching
+		c.channels.Workers <- workerID
+		c.mx.Lock()
+		delete(c.waiting, id)
+		c.mx.Unlock()
+		c.interrupt(item)
+		return
+	}
+	defer c.scheduler.Release(host)
+	c.pause.RLock()
+	defer c.pause.RUnlock()
+	c.mx.Lock()
+	delete(c.waiting, id)
+	c.crawling++
+	c.mx.Unlock()
+	crawl.SpinUpCrawler(c.fetchCtx)
+	if c.shared != nil {
+		found := <-finished
+		c.release(item)
+		c.channels.Finished <- found
+	}
+	if c.timedOut.Load() && c.checkpoints != nil {
+		// the crawl may have been cut before queueing its links, it is crawled again when resuming
+		c.mx.Lock()
+		c.interrupted = append(c.interrupted, item)
+		c.recrawled++
+		c.mx.Unlock()
+	}
+}
+
+// interrupt drops a link the shutdown did not let crawl, keeping it for the last checkpoint or
+// handing it back to the other processes when the frontier is shared.
+func (c *crawlerHandler) interrupt(item models.CrawlItem) {
+	if c.shared != nil {
+		// a link that could not be pushed back stays popped, it is queued again once the frontier is
+		// closed or the lease of the process expires
+		if err := c.queue.Push(item); err != nil {
+			c.log.Error(err)
+		} else {
+			c.release(item)
 		}
+	} else if c.checkpoints != nil {
+		c.mx.Lock()
+		c.interrupted = append(c.interrupted, item)
+		c.mx.Unlock()
 	}
+	c.settle(0, false)
 }
 
-// ValidateCrawlFinish validates if all found links have been processed and signals WaitGroup.
-func (c *crawlerHandler) ValidateCrawlFinish() {
+// ValidateCrawlFinish settles the links reported by the crawlers until the crawl is done.
+// Once the context is done it waits for in-flight crawls, cancelling them after the shutdown timeout.
+func (c *crawlerHandler) ValidateCrawlFinish(ctx context.Context) {
+	defer c.cancelFetch()
+	shutdown := ctx.Done()
 	for {
 		select {
-		case <-c.channels.Finished:
-			*c.processed++
+		case found := <-c.channels.Finished:
+			c.settle(found, true)
+		case <-shutdown:
+			shutdown = nil
+			timer := time.AfterFunc(c.cfg.ShutdownTimeout, func() {
+				c.timedOut.Store(true)
+				c.cancelFetch()
+			})
+			defer timer.Stop()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// settle removes a link from the pending work, adding the links it queued, and closes done
+// when no work is left. A crawler queues its links before reporting them, so they can be
+// settled first and pending can briefly drop to zero or below; the crawl is not done while
+// a crawler has not reported.
+func (c *crawlerHandler) settle(found int, processed bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.shared == nil {
+		c.pending += found
+	}
+	c.pending--
+	c.stats.Found += found
+	if processed {
+		c.crawling--
+		c.stats.Processed++
+		c.idle.Broadcast()
+	} else {
+		c.stats.Dropped++
+	}
+	if c.pending == 0 && c.crawling == 0 {
+		if c.shared != nil {
+			// the listener ends the crawl once the frontier is drained, a shutdown once this is reached
+			c.idle.Broadcast()
+			return
+		}
+		close(c.done)
+	}
+}
+
+// release settles a link popped from a shared frontier, the other processes can tell the links
+// found on it were queued already.
+func (c *crawlerHandler) release(item models.CrawlItem) {
+	if c.shared == nil {
+		return
+	}
+	if err := c.shared.Done(item); err != nil {
+		c.log.Error(err)
+	}
+}
+
+// Restore marks the visited keys of the checkpoint as visited and queues its links, continuing the
+// crawl where it was checkpointed. The pages that failed are queued again ahead of the links left,
+// they are no longer counted as processed.
+func (c *crawlerHandler) Restore(state models.Checkpoint) error {
+	if state.Site != c.cfg.WepPage {
+		return fmt.Errorf("the checkpoint is a crawl of %s, not of %s", state.Site, c.cfg.WepPage)
+	}
+	// the visited set is replaced, the keys a persistent store marked after the checkpoint belong to
+	// links that are not in it and would never be crawled
+	keys, ok := c.store.(store.IVisitedKeys)
+	if !ok {
+		return errors.New("the store cannot restore its visited links from a checkpoint")
+	}
+	if err := keys.RestoreVisited(state.Visited); err != nil {
+		return err
+	}
+	queued := make([]models.CrawlItem, 0, len(state.Failed)+len(state.Frontier))
+	for _, page := range state.Failed {
+		queued = append(queued, page.Item)
+	}
+	queued = append(queued, state.Frontier...)
+	c.mx.Lock()
+	c.stats = state.Stats
+	c.stats.Processed -= len(state.Failed)
+	// the pages already crawled count toward the MaxPages budget
+	c.dispatched = c.stats.Processed
+	c.reserved = c.stats.Processed
+	c.mx.Unlock()
+	links := []models.CrawlItem{}
+	for _, item := range queued {
+		if c.reserve() {
+			links = append(links, item)
+		}
+	}
+	c.mx.Lock()
+	// the checkpointed links beyond the budget were found already, they are dropped
+	c.stats.Dropped += len(queued) - len(links)
+	if c.shared == nil {
+		c.pending += len(links)
+		if c.pending == 0 && c.crawling == 0 {
+			close(c.done)
 		}
+	}
+	c.mx.Unlock()
+	for _, item := range links {
+		c.push(item)
+	}
+	c.markSeeded()
+	return nil
+}
+
+// Checkpoint saves the links the crawl did not get to, once it is done.
+func (c *crawlerHandler) Checkpoint() error {
+	if c.checkpoints == nil {
+		return nil
+	}
+	// the crawl is done once the crawls reported, the ones cut by the shutdown timeout are kept
+	// right after
+	c.crawls.Wait()
+	c.mx.Lock()
+	links := append([]models.CrawlItem{}, c.interrupted...)
+	c.mx.Unlock()
+	return c.save(links)
+}
+
+// pauseCrawls stops the crawls from starting and returns a channel closed once the running ones
+// finished and settled.
+func (c *crawlerHandler) pauseCrawls() chan struct{} {
+	paused := make(chan struct{})
+	go func() {
+		c.pause.Lock()
 		c.mx.Lock()
-		if *c.found == *c.processed {
-			c.wg.Done()
+		for c.crawling > 0 {
+			c.idle.Wait()
 		}
 		c.mx.Unlock()
+		close(paused)
+	}()
+	return paused
+}
+
+// resumeCrawls lets the crawls start again once they are paused, when the checkpoint they were
+// paused for is abandoned.
+func (c *crawlerHandler) resumeCrawls(paused chan struct{}) {
+	if paused == nil {
+		return
+	}
+	go func() {
+		<-paused
+		c.pause.Unlock()
+	}()
+}
+
+// checkpoint saves the links of the frontier, the next one and the ones waiting for their host
+// while the crawls are paused.
+func (c *crawlerHandler) checkpoint(next *models.CrawlItem) {
+	queued, err := c.queue.Items()
+	if err != nil {
+		c.log.Error(err)
+		return
+	}
+	c.mx.Lock()
+	links := make([]models.CrawlItem, 0, len(c.waiting)+len(queued)+1)
+	for _, item := range c.waiting {
+		links = append(links, item)
+	}
+	c.mx.Unlock()
+	if next != nil {
+		links = append(links, *next)
+	}
+	links = append(links, queued...)
+	if err := c.save(links); err != nil {
+		c.log.Error(err)
+		return
+	}
+	c.log.Info(fmt.Sprintf("checkpoint saved, links left to crawl: [%d]", len(links)))
+}
+
+// save writes a checkpoint with the links left to crawl, the visited keys of the store and the counters, the
+// interrupted links are not counted as dropped since they are crawled when resuming.
+func (c *crawlerHandler) save(links []models.CrawlItem) error {
+	state := models.Checkpoint{Site: c.cfg.WepPage, Frontier: links}
+	c.mx.Lock()
+	state.Stats = c.stats
+	state.Stats.Processed -= c.recrawled
+	state.Stats.Dropped -= len(c.interrupted) - c.recrawled
+	c.mx.Unlock()
+	keys, ok := c.store.(store.IVisitedKeys)
+	if !ok {
+		return errors.New("the store cannot list its visited links to checkpoint them")
+	}
+	visited, err := keys.VisitedKeys()
+	if err != nil {
+		return err
+	}
+	state.Visited = visited
+	if state.Failed, err = FailedPages(c.store); err != nil {
+		return err
+	}
+	return c.checkpoints.Save(state)
+}
 
+// FailedPages returns the pages of the store that failed once each, leaving out the ones that
+// were crawled after failing.
+func FailedPages(crawlStore store.ICrawlerStore) ([]models.FailedPage, error) {
+	failed, err := crawlStore.FailedPages()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := crawlStore.Pages()
+	if err != nil {
+		return nil, err
+	}
+	crawled := map[string]bool{}
+	for _, page := range pages {
+		if page.Error == "" && page.StatusCode != 0 && page.StatusCode != http.StatusTooManyRequests &&
+			page.StatusCode < http.StatusInternalServerError {
+			crawled[page.URL] = true
+		}
+	}
+	// a page failing again keeps its last failure
+	last := map[string]int{}
+	for i, page := range failed {
+		last[page.Item.URL] = i
+	}
+	pending := []models.FailedPage{}
+	for i, page := range failed {
+		if last[page.Item.URL] == i && !crawled[page.Item.URL] {
+			pending = append(pending, page)
+		}
 	}
+	return pending, nil
 }
@@ -0,0 +1,629 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/checkpoint"
+	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/frontier"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
+	"github.com/csrar/crawler/pkg/store"
+	"github.com/dsnet/golib/memfile"
+	"github.com/golang/mock/gomock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSiteServer serves a site of pages linking to each other, to themselves and to other hosts.
+// Every page can be reached from the root page through the /page/{i+1} links.
+func newSiteServer(pages int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><body><a href="/page/0">first</a></body></html>`)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/page/"))
+		if err != nil || id >= pages {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body := strings.Builder{}
+		body.WriteString("<html><body>")
+		for _, next := range []int{id + 1, (id*7 + 1) % pages, (id*13 + 2) % pages, id} {
+			if next < pages {
+				fmt.Fprintf(&body, `<a href="/page/%d">page %d</a>`, next, next)
+			}
+		}
+		body.WriteString(`<a href="/">home</a><a href="https://external.example.com/page/1">external</a>`)
+		body.WriteString("</body></html>")
+		fmt.Fprint(w, body.String())
+	}))
+}
+
+func newTestHandler(t *testing.T, workers int, cfg models.Config, checkpoints checkpoint.ICheckpointer) ICrawlerHandler {
+	crawlerStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+	err := crawlerStore.StoreData("", models.SiteStore{Sites: map[string]bool{}})
+	assert.Nil(t, err)
+	if cfg.FrontierMemoryItems == 0 {
+		cfg.FrontierMemoryItems = 10000
+	}
+	queue, err := frontier.NewFrontier(t.TempDir(), cfg.FrontierMemoryItems)
+	assert.Nil(t, err)
+	return newHandler(t, workers, cfg, checkpoints, queue, crawlerStore)
+}
+
+// newLogMock returns a logger accepting every message.
+func newLogMock(t *testing.T) *mock_logger.MockIlogger {
+	ctrl := gomock.NewController(t)
+	logMock := mock_logger.NewMockIlogger(ctrl)
+	logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+	logMock.EXPECT().Warn(gomock.Any()).AnyTimes()
+	logMock.EXPECT().Error(gomock.Any()).AnyTimes()
+	return logMock
+}
+
+// newHandler creates a handler crawling with the frontier and the store.
+func newHandler(t *testing.T, workers int, cfg models.Config, checkpoints checkpoint.ICheckpointer,
+	queue frontier.IFrontier, crawlerStore store.ICrawlerStore) ICrawlerHandler {
+	channels := &models.CommunitationChans{
+		Workers:  make(chan int, workers),
+		Finished: make(chan int),
+	}
+	for i := 0; i < workers; i++ {
+		channels.Workers <- i + 1
+	}
+	return NewCrawlerHandler(cfg, channels, queue, newLogMock(t), crawlerStore, robots.NewAllowAll(),
+		politeness.NewScheduler(0, workers), crawler.NewNormalizer(nil),
+		crawler.NewExtractor([]string{"a"}), nil, crawler.NewFetcher(http.DefaultClient), checkpoints)
+}
+
+func TestCrawlFinishStress(t *testing.T) {
+	tests := []struct {
+		name            string
+		pages           int
+		workers         int
+		delay           time.Duration
+		checkpointEvery time.Duration
+		// memoryItems of the frontier, the rest is spilled to disk
+		memoryItems int
+	}{
+		{
+			name:    "single worker",
+			pages:   50,
+			workers: 1,
+		},
+		{
+			name:    "many workers and fast pages",
+			pages:   200,
+			workers: 20,
+		},
+		{
+			name:    "many workers and slow pages",
+			pages:   100,
+			workers: 10,
+			delay:   2 * time.Millisecond,
+		},
+		{
+			name:            "many workers and frequent checkpoints",
+			pages:           200,
+			workers:         20,
+			checkpointEvery: time.Millisecond,
+		},
+		{
+			// the frontier holds far fewer links than the site has, it spills them instead of
+			// blocking the crawlers
+			name:        "many workers and a frontier spilled to disk",
+			pages:       500,
+			workers:     20,
+			memoryItems: 2,
+		},
+		{
+			name:            "frequent checkpoints of a frontier spilled to disk",
+			pages:           200,
+			workers:         20,
+			checkpointEvery: time.Millisecond,
+			memoryItems:     2,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// run the same crawl several times to shake out ordering dependent bugs
+			for run := 0; run < 3; run++ {
+				testServer := newSiteServer(tc.pages, tc.delay)
+				var checkpoints checkpoint.ICheckpointer
+				if tc.checkpointEvery > 0 {
+					checkpoints, _ = checkpoint.NewCheckpointer(t.TempDir())
+				}
+				handler := newTestHandler(t, tc.workers, models.Config{
+					WepPage:             testServer.URL + "/",
+					ShutdownTimeout:     time.Second,
+					CheckpointInterval:  tc.checkpointEvery,
+					FrontierMemoryItems: tc.memoryItems,
+				}, checkpoints)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				go handler.ListenForNewLinks(ctx)
+				go handler.ValidateCrawlFinish(ctx)
+				handler.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+
+				select {
+				case <-handler.Done():
+				case <-time.After(30 * time.Second):
+					t.Fatal("crawl did not finish")
+				}
+				cancel()
+				testServer.Close()
+
+				// the root page plus every page of the site is crawled exactly once
+				stats := handler.Stats()
+				assert.Equal(t, tc.pages+1, stats.Processed)
+				assert.Equal(t, tc.pages+1, stats.Found)
+				assert.Equal(t, 0, stats.Dropped)
+			}
+		})
+	}
+}
+
+func TestCrawlShutdown(t *testing.T) {
+	tests := []struct {
+		name            string
+		delay           time.Duration
+		shutdownTimeout time.Duration
+	}{
+		{
+			name:            "in-flight requests finish before the timeout",
+			delay:           20 * time.Millisecond,
+			shutdownTimeout: 5 * time.Second,
+		},
+		{
+			name:            "in-flight requests are cancelled after the timeout",
+			delay:           time.Second,
+			shutdownTimeout: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := newSiteServer(1000, tc.delay)
+			defer testServer.Close()
+			handler := newTestHandler(t, 5, models.Config{ShutdownTimeout: tc.shutdownTimeout}, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go handler.ListenForNewLinks(ctx)
+			go handler.ValidateCrawlFinish(ctx)
+			handler.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+
+			select {
+			case <-handler.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("crawl did not shut down")
+			}
+
+			stats := handler.Stats()
+			assert.Equal(t, stats.Found, stats.Processed+stats.Dropped)
+		})
+	}
+}
+
+func TestCrawlLimits(t *testing.T) {
+	tests := []struct {
+		name              string
+		cfg               models.Config
+		expectedProcessed int
+		// expectedFound is checked when set, the links beyond the page budget are not queued
+		expectedFound int
+	}{
+		{
+			name:              "max pages stops the crawl",
+			cfg:               models.Config{MaxPages: 10},
+			expectedProcessed: 10,
+			expectedFound:     10,
+		},
+		{
+			// the root page links to page 0, which links to pages 1 and 2 at the max depth
+			name:              "max depth stops the crawl",
+			cfg:               models.Config{MaxDepth: 2},
+			expectedProcessed: 4,
+		},
+		{
+			name:              "no limits crawls every page",
+			cfg:               models.Config{},
+			expectedProcessed: 101,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := newSiteServer(100, 0)
+			defer testServer.Close()
+			handler := newTestHandler(t, 1, tc.cfg, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go handler.ListenForNewLinks(ctx)
+			go handler.ValidateCrawlFinish(ctx)
+			handler.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+
+			select {
+			case <-handler.Done():
+			case <-time.After(30 * time.Second):
+				t.Fatal("crawl did not finish")
+			}
+
+			stats := handler.Stats()
+			assert.Equal(t, tc.expectedProcessed, stats.Processed)
+			assert.Equal(t, stats.Found, stats.Processed+stats.Dropped)
+			if tc.expectedFound > 0 {
+				assert.Equal(t, tc.expectedFound, stats.Found)
+				assert.Zero(t, stats.Dropped)
+			}
+		})
+	}
+}
+
+// newCountingServer serves the site of newSiteServer counting the requests of every path.
+func newCountingServer(pages int, delay time.Duration) (*httptest.Server, *sync.Map) {
+	site := newSiteServer(pages, delay)
+	requests := &sync.Map{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := requests.LoadOrStore(r.URL.Path, new(atomic.Int32))
+		count.(*atomic.Int32).Add(1)
+		site.Config.Handler.ServeHTTP(w, r)
+	})), requests
+}
+
+// crawlUntilDone runs the crawl of the handler, from the root page unless it was restored.
+func crawlUntilDone(t *testing.T, ctx context.Context, handler ICrawlerHandler, seeds ...models.CrawlItem) {
+	go handler.ListenForNewLinks(ctx)
+	go handler.ValidateCrawlFinish(ctx)
+	handler.Seed(seeds...)
+	select {
+	case <-handler.Done():
+	case <-time.After(30 * time.Second):
+		t.Fatal("crawl did not finish")
+	}
+}
+
+func TestCrawlResume(t *testing.T) {
+	tests := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		// interrupted crawls are resumed from their last checkpoint, the other ones from a
+		// checkpoint taken while crawling as if the process had died
+		interrupted bool
+	}{
+		{
+			name:            "interrupted while crawling",
+			shutdownTimeout: 5 * time.Second,
+			interrupted:     true,
+		},
+		{
+			name:            "interrupted and in-flight requests cancelled",
+			shutdownTimeout: time.Millisecond,
+			interrupted:     true,
+		},
+		{
+			name:            "died after a periodic checkpoint",
+			shutdownTimeout: time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pages := 200
+			testServer, requests := newCountingServer(pages, 5*time.Millisecond)
+			defer testServer.Close()
+			checkpoints, err := checkpoint.NewCheckpointer(t.TempDir())
+			assert.Nil(t, err)
+			cfg := models.Config{
+				WepPage:            testServer.URL + "/",
+				ShutdownTimeout:    tc.shutdownTimeout,
+				CheckpointInterval: 10 * time.Millisecond,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			first := newTestHandler(t, 5, cfg, checkpoints)
+			go first.ListenForNewLinks(ctx)
+			go first.ValidateCrawlFinish(ctx)
+			first.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+
+			var state models.Checkpoint
+			if tc.interrupted {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+				<-first.Done()
+				assert.Nil(t, first.Checkpoint())
+				state, err = checkpoints.Load()
+				assert.Nil(t, err)
+			} else {
+				assert.Eventually(t, func() bool {
+					state, err = checkpoints.Load()
+					return err == nil && len(state.Frontier) > 0 && state.Stats.Processed > 10
+				}, 10*time.Second, 5*time.Millisecond)
+				cancel()
+				<-first.Done()
+			}
+			assert.NotEmpty(t, state.Frontier)
+			assert.Equal(t, state.Stats.Found, state.Stats.Processed+state.Stats.Dropped+len(state.Frontier))
+
+			resumed := newTestHandler(t, 5, cfg, checkpoints)
+			assert.Nil(t, resumed.Restore(state))
+			crawlUntilDone(t, context.Background(), resumed)
+
+			// the resumed crawl explores every page left, the counters add up to a single crawl
+			stats := resumed.Stats()
+			assert.Equal(t, pages+1, stats.Processed)
+			assert.Equal(t, pages+1, stats.Found)
+			assert.Equal(t, 0, stats.Dropped)
+			fetched := 0
+			requests.Range(func(_, _ any) bool {
+				fetched++
+				return true
+			})
+			assert.Equal(t, pages+1, fetched)
+		})
+	}
+}
+
+func TestCrawlResumePersistentStore(t *testing.T) {
+	pages := 200
+	testServer, requests := newCountingServer(pages, 5*time.Millisecond)
+	defer testServer.Close()
+	checkpoints, err := checkpoint.NewCheckpointer(t.TempDir())
+	assert.Nil(t, err)
+	storeDir := t.TempDir()
+	cfg := models.Config{
+		WepPage:            testServer.URL + "/",
+		ShutdownTimeout:    time.Second,
+		CheckpointInterval: 200 * time.Millisecond,
+	}
+
+	firstStore, err := store.NewDiskStore(storeDir, 0)
+	assert.Nil(t, err)
+	firstQueue, err := frontier.NewFrontier(t.TempDir(), 10000)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := newHandler(t, 5, cfg, checkpoints, firstQueue, firstStore)
+	go first.ListenForNewLinks(ctx)
+	go first.ValidateCrawlFinish(ctx)
+	first.Seed(models.CrawlItem{URL: testServer.URL + "/"})
+
+	// the process dies after links were found past the first checkpoint, before the next one
+	var state models.Checkpoint
+	assert.Eventually(t, func() bool {
+		state, err = checkpoints.Load()
+		return err == nil && len(state.Frontier) > 0
+	}, 10*time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		visited, err := firstStore.(store.IVisitedKeys).VisitedKeys()
+		return err == nil && len(visited) > len(state.Visited)+5
+	}, 10*time.Second, time.Millisecond)
+	cancel()
+	<-first.Done()
+	state, err = checkpoints.Load()
+	assert.Nil(t, err)
+
+	// the store is reopened without being closed, the links marked after the checkpoint are cleared
+	// and the checkpointed ones restored
+	resumedStore, err := store.NewDiskStore(storeDir, 0)
+	assert.Nil(t, err)
+	visited, err := resumedStore.(store.IVisitedKeys).VisitedKeys()
+	assert.Nil(t, err)
+	assert.Empty(t, visited)
+	resumedQueue, err := frontier.NewFrontier(t.TempDir(), 10000)
+	assert.Nil(t, err)
+	resumed := newHandler(t, 5, cfg, checkpoints, resumedQueue, resumedStore)
+	assert.Nil(t, resumed.Restore(state))
+	visited, err = resumedStore.(store.IVisitedKeys).VisitedKeys()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, state.Visited, visited)
+	crawlUntilDone(t, context.Background(), resumed)
+
+	// every page is explored, the ones crawled after the checkpoint are crawled again
+	stats := resumed.Stats()
+	assert.Equal(t, pages+1, stats.Processed)
+	assert.Equal(t, pages+1, stats.Found)
+	fetched := 0
+	requests.Range(func(_, _ any) bool {
+		fetched++
+		return true
+	})
+	assert.Equal(t, pages+1, fetched)
+	assert.Nil(t, resumedStore.Close())
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("other site", func(t *testing.T) {
+		handler := newTestHandler(t, 1, models.Config{WepPage: "https://mock-site.com/"}, nil)
+		err := handler.Restore(models.Checkpoint{Site: "https://other-site.com/"})
+		assert.EqualError(t, err, "the checkpoint is a crawl of https://other-site.com/, not of https://mock-site.com/")
+	})
+
+	t.Run("finished crawl", func(t *testing.T) {
+		handler := newTestHandler(t, 1, models.Config{WepPage: "https://mock-site.com/"}, nil)
+		stats := models.CrawlStats{Found: 10, Processed: 9, Dropped: 1}
+		assert.Nil(t, handler.Restore(models.Checkpoint{Site: "https://mock-site.com/", Stats: stats}))
+		select {
+		case <-handler.Done():
+		case <-time.After(time.Second):
+			t.Fatal("restored crawl without links is not done")
+		}
+		assert.Equal(t, stats, handler.Stats())
+	})
+}
+
+func TestResumeFailedPages(t *testing.T) {
+	var flaky atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/flaky">flaky</a><a href="/about">about</a></body></html>`)
+		case "/flaky":
+			// the page fails until the crawl is resumed
+			if flaky.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `<html><body><a href="/">home</a></body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body></body></html>`)
+		}
+	}))
+	defer testServer.Close()
+	checkpoints, err := checkpoint.NewCheckpointer(t.TempDir())
+	assert.Nil(t, err)
+	cfg := models.Config{WepPage: testServer.URL + "/"}
+
+	first := newTestHandler(t, 2, cfg, checkpoints)
+	crawlUntilDone(t, context.Background(), first, models.CrawlItem{URL: testServer.URL + "/"})
+	assert.Nil(t, first.Checkpoint())
+	state, err := checkpoints.Load()
+	assert.Nil(t, err)
+	assert.Len(t, state.Failed, 1)
+	assert.Equal(t, testServer.URL+"/flaky", state.Failed[0].Item.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, state.Failed[0].StatusCode)
+
+	resumed := newTestHandler(t, 2, cfg, checkpoints)
+	assert.Nil(t, resumed.Restore(state))
+	crawlUntilDone(t, context.Background(), resumed)
+
+	// the failed page is crawled again without being counted twice
+	assert.Equal(t, int32(2), flaky.Load())
+	assert.Equal(t, models.CrawlStats{Found: 3, Processed: 3}, resumed.Stats())
+	assert.Nil(t, resumed.Checkpoint())
+	state, err = checkpoints.Load()
+	assert.Nil(t, err)
+	assert.Empty(t, state.Failed)
+}
+
+func TestFailedPages(t *testing.T) {
+	crawlerStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+	failures := []models.FailedPage{
+		{Item: models.CrawlItem{URL: "https://mock-site.com/down"}, Error: "connection refused"},
+		{Item: models.CrawlItem{URL: "https://mock-site.com/flaky"}, StatusCode: 503, Error: "503 Service Unavailable"},
+		{Item: models.CrawlItem{URL: "https://mock-site.com/down"}, StatusCode: 502, Error: "502 Bad Gateway"},
+	}
+	for _, page := range failures {
+		assert.Nil(t, crawlerStore.RecordFailure(page))
+	}
+	assert.Nil(t, crawlerStore.RecordPage(models.PageResult{URL: "https://mock-site.com/down", StatusCode: 502}))
+	// the flaky page was crawled once it failed
+	assert.Nil(t, crawlerStore.RecordPage(models.PageResult{URL: "https://mock-site.com/flaky", StatusCode: 200}))
+
+	failed, err := FailedPages(crawlerStore)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.FailedPage{failures[2]}, failed)
+}
+
+func TestCrawlShared(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes int
+		workers   int
+		pages     int
+		// the first process is interrupted once it crawled some pages, its links are left in
+		// the shared frontier for the other ones
+		interruptFirst bool
+	}{
+		{
+			name:      "single process",
+			processes: 1,
+			workers:   5,
+			pages:     50,
+		},
+		{
+			name:      "several processes",
+			processes: 3,
+			workers:   5,
+			pages:     200,
+		},
+		{
+			name:           "several processes and one interrupted",
+			processes:      3,
+			workers:        5,
+			pages:          200,
+			interruptFirst: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer, requests := newCountingServer(tc.pages, time.Millisecond)
+			defer testServer.Close()
+			server := miniredis.RunT(t)
+			cfg := models.Config{
+				WepPage:         testServer.URL + "/",
+				ShutdownTimeout: 5 * time.Second,
+				StoreBackend:    "redis",
+				RedisURL:        "redis://" + server.Addr(),
+				RedisKeyPrefix:  "crawler",
+			}
+
+			handlers := make([]ICrawlerHandler, tc.processes)
+			for i := range handlers {
+				crawlerStore, err := store.New(cfg)
+				assert.Nil(t, err)
+				defer crawlerStore.Close()
+				queue := frontier.NewRedisFrontier(redis.NewClient(&redis.Options{Addr: server.Addr()}), cfg.RedisKeyPrefix, 10*time.Millisecond, 0)
+				defer queue.Close()
+				handlers[i] = newHandler(t, tc.workers, cfg, nil, queue, crawlerStore)
+			}
+
+			var wg sync.WaitGroup
+			for i, handler := range handlers {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				if i == 0 && tc.interruptFirst {
+					go func(handler ICrawlerHandler) {
+						for handler.Stats().Processed < 10 {
+							time.Sleep(time.Millisecond)
+						}
+						cancel()
+					}(handler)
+				}
+				wg.Add(1)
+				go func(handler ICrawlerHandler) {
+					defer wg.Done()
+					// the root page is only queued by the first process, the pages it links to are split
+					crawlUntilDone(t, ctx, handler, models.CrawlItem{URL: testServer.URL + "/"})
+				}(handler)
+			}
+			wg.Wait()
+
+			// every page is crawled exactly once
+			processed := 0
+			for _, handler := range handlers {
+				stats := handler.Stats()
+				processed += stats.Processed
+			}
+			assert.Equal(t, tc.pages+1, processed)
+			requests.Range(func(path, count any) bool {
+				assert.Equal(t, int32(1), count.(*atomic.Int32).Load(), path)
+				return true
+			})
+			assert.False(t, server.Exists("crawler:frontier"))
+		})
+	}
+}
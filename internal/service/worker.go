@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/broker"
+	"github.com/csrar/crawler/pkg/crawler"
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/robots"
+	"github.com/csrar/crawler/pkg/store"
+)
+
+// worker crawls the links handed by the coordinator of a distributed crawl and sends back what it
+// found. A job is acked once its result is published, so the jobs of a worker that stops halfway
+// are handed to another one and a job may be crawled more than once. The coordinator hands out a
+// job once its host allows a request, the worker crawls it right away.
+type worker struct {
+	cfg        models.Config
+	broker     broker.IBroker
+	log        logger.Ilogger
+	robots     robots.IRobots
+	normalizer crawler.INormalizer
+	extractor  crawler.IExtractor
+	fetcher    crawler.IFetcher
+	// assets creates the asset checker of a page recording in its store and checking its rules, it
+	// returns nil unless the assets are checked
+	assets func(store store.ICrawlerStore, rules robots.IRobots) crawler.IAssetChecker
+
+	mx    sync.Mutex
+	stats models.CrawlStats
+}
+
+type IWorker interface {
+	Run(ctx context.Context) error
+	Stats() models.CrawlStats
+}
+
+// NewWorker creates a worker crawling up to cfg.Workers jobs at a time.
+func NewWorker(cfg models.Config, messages broker.IBroker, log logger.Ilogger, robots robots.IRobots,
+	normalizer crawler.INormalizer, extractor crawler.IExtractor, fetcher crawler.IFetcher,
+	assets func(store store.ICrawlerStore, rules robots.IRobots) crawler.IAssetChecker) IWorker {
+	return &worker{
+		cfg:        cfg,
+		broker:     messages,
+		log:        log,
+		robots:     robots,
+		normalizer: normalizer,
+		extractor:  extractor,
+		fetcher:    fetcher,
+		assets:     assets,
+	}
+}
+
+// Stats returns the number of jobs crawled as processed.
+func (w *worker) Stats() models.CrawlStats {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.stats
+}
+
+// Run crawls the jobs until the context is done, then waits for the running crawls, cancelling
+// them after the shutdown timeout.
+func (w *worker) Run(ctx context.Context) error {
+	jobs, err := w.broker.Consume(ctx, broker.JobsTopic)
+	if err != nil {
+		return err
+	}
+	// in-flight fetches outlive the context so they can finish during a graceful shutdown
+	fetchCtx, cancelFetch := context.WithCancel(context.Background())
+	defer cancelFetch()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-fetchCtx.Done():
+			return
+		}
+		timer := time.NewTimer(w.cfg.ShutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancelFetch()
+		case <-fetchCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for workerID := 1; workerID <= w.cfg.Workers; workerID++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for message := range jobs {
+				w.handle(ctx, fetchCtx, workerID, message)
+			}
+		}(workerID)
+	}
+	wg.Wait()
+	return nil
+}
+
+// handle crawls the link of the job and publishes the result, reporting the job in progress so it
+// is not handed to another worker while it is crawled. The job is handed back when the shutdown
+// does not let it crawl.
+func (w *worker) handle(ctx, fetchCtx context.Context, workerID int, message broker.IMessage) {
+	job := models.CrawlJob{}
+	if err := json.Unmarshal(message.Data(), &job); err != nil {
+		w.log.Error(fmt.Errorf("error decoding crawl job, it is dropped: %w", err))
+		w.settle(message.Ack)
+		return
+	}
+	if ctx.Err() != nil {
+		// shutting down before the request started, another worker crawls the link
+		w.settle(message.Nak)
+		return
+	}
+	stop := w.keepInProgress(message)
+	page, assets := w.crawl(fetchCtx, workerID, job)
+	if assets != nil {
+		// the assets are part of the result
+		if err := assets.Shutdown(fetchCtx); err != nil {
+			w.log.Warn(fmt.Sprintf("assets of %s left unchecked: %v", job.Item.URL, err))
+		}
+	}
+	stop()
+	if fetchCtx.Err() != nil {
+		// the crawl was cut by the shutdown timeout, another worker crawls the link again
+		w.settle(message.Nak)
+		return
+	}
+	payload, err := json.Marshal(page.result)
+	if err == nil {
+		err = w.broker.Publish(broker.ResultsTopic, payload)
+	}
+	if err != nil {
+		w.log.Error(fmt.Errorf("error sending the result of %s, it is crawled again: %w", job.Item.URL, err))
+		w.settle(message.Nak)
+		return
+	}
+	w.settle(message.Ack)
+	w.mx.Lock()
+	w.stats.Processed++
+	w.mx.Unlock()
+}
+
+// crawl crawls the link of the job, collecting the links and records of the page. The assets of the
+// page are still being checked by the returned checker, nil unless they are checked.
+func (w *worker) crawl(ctx context.Context, workerID int, job models.CrawlJob) (*pageCollector, crawler.IAssetChecker) {
+	page := newPageCollector()
+	rules := &pageRobots{IRobots: w.robots, page: page}
+	assets := w.assets(page, rules)
+	// the crawler hands its worker back and reports its links once done
+	channels := &models.CommunitationChans{Workers: make(chan int, 1), Finished: make(chan int, 1)}
+	crawl, err := crawler.NewCrawler(workerID, job.Item, channels, page, w.log, w.fetcher, page, rules, w.normalizer,
+		w.extractor, assets, w.cfg)
+	if err != nil {
+		w.log.Error(err)
+		page.result.Failures = append(page.result.Failures, models.FailedPage{Item: job.Item, Error: err.Error()})
+	} else {
+		crawl.SpinUpCrawler(ctx)
+	}
+	page.result.ID = job.ID
+	return page, assets
+}
+
+// keepInProgress reports the message in progress every half ack timeout until the returned function
+// is called, so a job taking longer than the ack timeout is not crawled by another worker meanwhile.
+func (w *worker) keepInProgress(message broker.IMessage) func() {
+	if w.cfg.QueueAckTimeout <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.cfg.QueueAckTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := message.InProgress(); err != nil {
+					w.log.Error(fmt.Errorf("error extending the ack deadline of crawl job: %w", err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// settle acks or naks a message, the message is handed to another worker after the ack timeout
+// when it fails.
+func (w *worker) settle(settle func() error) {
+	if err := settle(); err != nil {
+		w.log.Error(fmt.Errorf("error settling crawl job: %w", err))
+	}
+}
+
+// pageRobots are the robots.txt rules of the crawl of a single page, counting the links and assets
+// they reject in the result sent to the coordinator.
+type pageRobots struct {
+	robots.IRobots
+	page *pageCollector
+}
+
+func (r *pageRobots) IsAllowed(link *url.URL) bool {
+	if r.IRobots.IsAllowed(link) {
+		return true
+	}
+	r.page.mx.Lock()
+	defer r.page.mx.Unlock()
+	r.page.result.Blocked++
+	return false
+}
+
+func (r *pageRobots) IsAssetAllowed(asset *url.URL) bool {
+	if r.IRobots.IsAssetAllowed(asset) {
+		return true
+	}
+	r.page.mx.Lock()
+	defer r.page.mx.Unlock()
+	r.page.result.AssetsBlocked++
+	return false
+}
+
+// pageCollector is the store and the frontier of the crawl of a single page, it collects the
+// result sent to the coordinator. A link is reported as visited when it was already seen on the
+// page, the coordinator tells whether it was visited by the crawl.
+type pageCollector struct {
+	mx      sync.Mutex
+	visited map[string]bool
+	result  models.CrawlResult
+}
+
+func newPageCollector() *pageCollector {
+	return &pageCollector{visited: map[string]bool{}}
+}
+
+func (p *pageCollector) WasAlreadyVisited(site string) (bool, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.visited[site] {
+		return true, nil
+	}
+	p.visited[site] = true
+	return false, nil
+}
+
+func (p *pageCollector) StoreData(site string, data models.SiteStore) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.visited[site] = true
+	for key := range data.Sites {
+		p.visited[key] = true
+	}
+	return nil
+}
+
+func (p *pageCollector) RecordAsset(asset models.AssetResult) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.Assets = append(p.result.Assets, asset)
+	return nil
+}
+
+func (p *pageCollector) Assets() ([]models.AssetResult, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]models.AssetResult{}, p.result.Assets...), nil
+}
+
+func (p *pageCollector) RecordNoindex(page string) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.Noindex = append(p.result.Noindex, page)
+	return nil
+}
+
+func (p *pageCollector) NoindexPages() ([]string, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]string{}, p.result.Noindex...), nil
+}
+
+func (p *pageCollector) RecordFailure(page models.FailedPage) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.Failures = append(p.result.Failures, page)
+	return nil
+}
+
+func (p *pageCollector) FailedPages() ([]models.FailedPage, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]models.FailedPage{}, p.result.Failures...), nil
+}
+
+func (p *pageCollector) RecordPage(page models.PageResult) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.Pages = append(p.result.Pages, page)
+	return nil
+}
+
+func (p *pageCollector) Pages() ([]models.PageResult, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]models.PageResult{}, p.result.Pages...), nil
+}
+
+func (p *pageCollector) RecordLinks(links []models.LinkResult) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.PageLinks = append(p.result.PageLinks, links...)
+	return nil
+}
+
+func (p *pageCollector) Links() ([]models.LinkResult, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]models.LinkResult{}, p.result.PageLinks...), nil
+}
+
+// Push collects a link found on the page.
+func (p *pageCollector) Push(item models.CrawlItem) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.result.Links = append(p.result.Links, item)
+	return nil
+}
+
+// Pop never returns a link, the links are crawled once the coordinator hands them out.
+func (p *pageCollector) Pop() (models.CrawlItem, bool, error) {
+	return models.CrawlItem{}, false, nil
+}
+
+func (p *pageCollector) Ready() <-chan struct{} {
+	return nil
+}
+
+func (p *pageCollector) Len() int {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return len(p.result.Links)
+}
+
+func (p *pageCollector) Items() ([]models.CrawlItem, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]models.CrawlItem{}, p.result.Links...), nil
+}
+
+func (p *pageCollector) Close() error {
+	return nil
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"time"
+
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/store"
+)
+
+// Option changes the configuration or adds a callback to a Crawler.
+type Option func(c *Crawler)
+
+// WithConfig replaces the configuration, including the changes of the options before it.
+func WithConfig(cfg Config) Option {
+	return func(c *Crawler) {
+		c.cfg = cfg
+	}
+}
+
+// WithURL sets the root page of the site to crawl, only the links to its host are crawled.
+func WithURL(site string) Option {
+	return func(c *Crawler) {
+		c.cfg.WepPage = site
+	}
+}
+
+// WithWorkers sets the number of pages crawled at a time.
+func WithWorkers(workers int) Option {
+	return func(c *Crawler) {
+		c.cfg.Workers = workers
+	}
+}
+
+// WithUserAgent sets the User-Agent of the requests, also used to pick the robots.txt rules.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Crawler) {
+		c.cfg.UserAgent = userAgent
+	}
+}
+
+// WithMaxDepth stops following the links of the pages maxDepth links away from the root page, 0
+// follows them all.
+func WithMaxDepth(maxDepth int) Option {
+	return func(c *Crawler) {
+		c.cfg.MaxDepth = maxDepth
+	}
+}
+
+// WithMaxPages stops the crawl once maxPages pages were crawled, 0 crawls them all.
+func WithMaxPages(maxPages int) Option {
+	return func(c *Crawler) {
+		c.cfg.MaxPages = maxPages
+	}
+}
+
+// WithMaxDuration stops the crawl once maxDuration passed, 0 does not limit it.
+func WithMaxDuration(maxDuration time.Duration) Option {
+	return func(c *Crawler) {
+		c.cfg.MaxDuration = maxDuration
+	}
+}
+
+// WithLogger logs the progress of the crawl to log, nothing is logged by default.
+func WithLogger(log logger.Ilogger) Option {
+	return func(c *Crawler) {
+		c.log = log
+	}
+}
+
+// WithStore records the crawl in crawlStore, which is left open once the crawl is over. By default
+// the crawl is recorded in a store of the StoreBackend of the configuration, closed once it is over.
+func WithStore(crawlStore store.ICrawlerStore) Option {
+	return func(c *Crawler) {
+		c.store = crawlStore
+	}
+}
+
+// WithResume resumes the crawl from the checkpoint of dir instead of starting from the root page,
+// the crawl keeps being checkpointed to dir.
+func WithResume(dir string) Option {
+	return func(c *Crawler) {
+		c.resumeDir = dir
+	}
+}
+
+// WithResults sends the result of every page fetched to Results, keeping up to buffer of them until
+// they are read. The results arriving while buffer of them are waiting are dropped, so a slow
+// reader never holds the crawl, and counted in the ResultsDropped stat.
+func WithResults(buffer int) Option {
+	return func(c *Crawler) {
+		c.results = buffer
+	}
+}
+
+// OnPage calls onPage with the result of every page fetched.
+func OnPage(onPage func(page PageResult)) Option {
+	return func(c *Crawler) {
+		c.onPage = append(c.onPage, onPage)
+	}
+}
+
+// OnLink calls onLink with every link found from a crawled page to another page of the site,
+// including the links to pages already visited.
+func OnLink(onLink func(link LinkResult)) Option {
+	return func(c *Crawler) {
+		c.onLink = append(c.onLink, onLink)
+	}
+}
+
+// OnError calls onError with every error of the crawl. The errors of the pages that could not be
+// crawled wrap a FetchError with their failure record, errors.As tells them apart.
+func OnError(onError func(err error)) Option {
+	return func(c *Crawler) {
+		c.onError = append(c.onError, onError)
+	}
+}
@@ -0,0 +1,270 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// the topics the coordinator and the workers of a distributed crawl talk through
+const (
+	JobsTopic    = "jobs"
+	ResultsTopic = "results"
+)
+
+var (
+	// ErrClosed is returned when the broker is used after it was closed.
+	ErrClosed = errors.New("the broker is closed")
+	// ErrRedelivered is returned when a message is settled after the ack timeout, it was handed to
+	// another consumer.
+	ErrRedelivered = errors.New("the message was redelivered after the ack timeout")
+)
+
+// IBroker carries messages between processes. Every message published to a topic is delivered to
+// one of its consumers, and delivered again to one of them when it is not acked within the ack
+// timeout of the broker, so the messages held by a consumer that stopped are not lost.
+//
+//go:generate mockgen -source=broker.go -destination=mocks/broker_mock.go
+type IBroker interface {
+	Publish(topic string, data []byte) error
+	// Consume returns the messages of the topic until the context is done, the messages received
+	// can still be acked once it is done.
+	Consume(ctx context.Context, topic string) (<-chan IMessage, error)
+	// Purge removes the messages of the topic, such as the ones left by a previous crawl.
+	Purge(topic string) error
+	Close() error
+}
+
+// IMessage is a message delivered to a consumer, it is settled with Ack once handled or with Nak
+// to have it redelivered right away. InProgress restarts the ack timeout of a message still being
+// handled.
+type IMessage interface {
+	Data() []byte
+	Ack() error
+	Nak() error
+	InProgress() error
+}
+
+// memoryBroker keeps the messages of its topics in memory, it is shared by the goroutines of a
+// single process.
+type memoryBroker struct {
+	mu         sync.Mutex
+	ackTimeout time.Duration
+	topics     map[string]*memoryTopic
+	closed     bool
+}
+
+// memoryTopic keeps the messages waiting for a consumer and the ones delivered until they are
+// settled or their ack deadline passes. wake is closed and replaced when a message is queued.
+type memoryTopic struct {
+	queued   []*memoryEntry
+	inFlight map[*memoryEntry]time.Time
+	wake     chan struct{}
+}
+
+// memoryEntry is a published message, delivery counts the times it was delivered.
+type memoryEntry struct {
+	data     []byte
+	delivery int
+}
+
+type memoryMessage struct {
+	broker   *memoryBroker
+	topic    *memoryTopic
+	entry    *memoryEntry
+	delivery int
+}
+
+// NewMemoryBroker creates a broker keeping its messages in memory, redelivering the ones not acked
+// within ackTimeout.
+func NewMemoryBroker(ackTimeout time.Duration) IBroker {
+	return &memoryBroker{ackTimeout: ackTimeout, topics: map[string]*memoryTopic{}}
+}
+
+// Publish queues a copy of data in the topic.
+func (b *memoryBroker) Publish(topic string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	t := b.topic(topic)
+	t.queued = append(t.queued, &memoryEntry{data: append([]byte{}, data...)})
+	t.signal()
+	return nil
+}
+
+// Consume delivers the messages of the topic, the expired deliveries are queued again when a
+// consumer looks for a message.
+func (b *memoryBroker) Consume(ctx context.Context, topic string) (<-chan IMessage, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	t := b.topic(topic)
+	b.mu.Unlock()
+	messages := make(chan IMessage)
+	go func() {
+		defer close(messages)
+		for {
+			message, wake, expiry, ok := b.next(t)
+			if !ok {
+				return
+			}
+			if message == nil {
+				if !wait(ctx, wake, expiry) {
+					return
+				}
+				continue
+			}
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				_ = message.Nak()
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// next delivers the oldest queued message of the topic. When there is none it returns the channel
+// closed once one is queued and the time left until the first delivery expires, 0 when there is
+// no delivery; ok is false once the broker is closed.
+func (b *memoryBroker) next(t *memoryTopic) (message *memoryMessage, wake <-chan struct{}, expiry time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, 0, false
+	}
+	now := time.Now()
+	for entry, deadline := range t.inFlight {
+		if !deadline.After(now) {
+			// the consumer did not settle it in time, it is handed to the next one
+			delete(t.inFlight, entry)
+			t.queued = append([]*memoryEntry{entry}, t.queued...)
+		}
+	}
+	if len(t.queued) == 0 {
+		for _, deadline := range t.inFlight {
+			if left := deadline.Sub(now); expiry == 0 || left < expiry {
+				expiry = left
+			}
+		}
+		return nil, t.wake, expiry, true
+	}
+	entry := t.queued[0]
+	t.queued = t.queued[1:]
+	entry.delivery++
+	t.inFlight[entry] = now.Add(b.ackTimeout)
+	return &memoryMessage{broker: b, topic: t, entry: entry, delivery: entry.delivery}, nil, 0, true
+}
+
+// wait blocks until a message is queued, the first delivery expires or the context is done,
+// reporting whether the context is still alive.
+func wait(ctx context.Context, wake <-chan struct{}, expiry time.Duration) bool {
+	var timeout <-chan time.Time
+	if expiry > 0 {
+		timer := time.NewTimer(expiry)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-wake:
+	case <-timeout:
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+// Purge discards the queued messages of the topic and the ones in flight, settling the messages in
+// flight fails then.
+func (b *memoryBroker) Purge(topic string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	t := b.topic(topic)
+	t.queued = nil
+	t.inFlight = map[*memoryEntry]time.Time{}
+	return nil
+}
+
+// Close stops the consumers, the messages left are discarded.
+func (b *memoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, t := range b.topics {
+		t.signal()
+	}
+	return nil
+}
+
+// topic returns the topic of name, creating it the first time it is used.
+func (b *memoryBroker) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{inFlight: map[*memoryEntry]time.Time{}, wake: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// signal wakes the consumers waiting for a message.
+func (t *memoryTopic) signal() {
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+func (m *memoryMessage) Data() []byte {
+	return m.entry.data
+}
+
+// Ack removes the message from the topic.
+func (m *memoryMessage) Ack() error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+	if !m.current() {
+		return ErrRedelivered
+	}
+	delete(m.topic.inFlight, m.entry)
+	return nil
+}
+
+// Nak queues the message again ahead of the others.
+func (m *memoryMessage) Nak() error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+	if !m.current() {
+		return ErrRedelivered
+	}
+	delete(m.topic.inFlight, m.entry)
+	m.topic.queued = append([]*memoryEntry{m.entry}, m.topic.queued...)
+	m.topic.signal()
+	return nil
+}
+
+// InProgress pushes the ack deadline of the message back by the ack timeout.
+func (m *memoryMessage) InProgress() error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+	if !m.current() {
+		return ErrRedelivered
+	}
+	m.topic.inFlight[m.entry] = time.Now().Add(m.broker.ackTimeout)
+	return nil
+}
+
+// current reports whether the message is still the delivery in flight of its entry.
+func (m *memoryMessage) current() bool {
+	_, inFlight := m.topic.inFlight[m.entry]
+	return inFlight && m.entry.delivery == m.delivery
+}
@@ -0,0 +1,256 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// runNATSServer starts an embedded NATS server with JetStream enabled and returns its URL.
+func runNATSServer(t *testing.T) string {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("the nats server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv.ClientURL()
+}
+
+// brokers creates brokers of every implementation, the ones created by a factory share their messages.
+var brokers = map[string]func(t *testing.T) func(ackTimeout time.Duration) IBroker{
+	"memory": func(t *testing.T) func(ackTimeout time.Duration) IBroker {
+		var shared IBroker
+		return func(ackTimeout time.Duration) IBroker {
+			if shared == nil {
+				shared = NewMemoryBroker(ackTimeout)
+				t.Cleanup(func() { shared.Close() })
+			}
+			return shared
+		}
+	},
+	"nats": func(t *testing.T) func(ackTimeout time.Duration) IBroker {
+		url := runNATSServer(t)
+		return func(ackTimeout time.Duration) IBroker {
+			broker, err := NewNATSBroker(url, "crawler", ackTimeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { broker.Close() })
+			return broker
+		}
+	},
+}
+
+// receive returns the data of the next message of messages.
+func receive(t *testing.T, messages <-chan IMessage) IMessage {
+	t.Helper()
+	select {
+	case message, ok := <-messages:
+		if !ok {
+			t.Fatal("the messages channel was closed")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was received")
+	}
+	return nil
+}
+
+func TestBrokerDelivery(t *testing.T) {
+	for name, factory := range brokers {
+		t.Run(name, func(t *testing.T) {
+			newBroker := factory(t)
+			publisher := newBroker(time.Minute)
+			total := 50
+			for i := 0; i < total; i++ {
+				assert.Nil(t, publisher.Publish(JobsTopic, []byte(fmt.Sprint(i))))
+			}
+			assert.Nil(t, publisher.Publish(ResultsTopic, []byte("result")))
+
+			// every message is delivered to one of the consumers of the topic
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var mu sync.Mutex
+			received := map[string]int{}
+			var wg sync.WaitGroup
+			for c := 0; c < 3; c++ {
+				messages, err := newBroker(time.Minute).Consume(ctx, JobsTopic)
+				assert.Nil(t, err)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for message := range messages {
+						assert.Nil(t, message.Ack())
+						mu.Lock()
+						received[string(message.Data())]++
+						done := len(received) == total
+						mu.Unlock()
+						if done {
+							cancel()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Len(t, received, total)
+			for i := 0; i < total; i++ {
+				assert.Equal(t, 1, received[fmt.Sprint(i)])
+			}
+
+			// the acked messages are not delivered again and the topics are kept apart
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			results, err := newBroker(time.Minute).Consume(ctx, ResultsTopic)
+			assert.Nil(t, err)
+			message := receive(t, results)
+			assert.Equal(t, "result", string(message.Data()))
+			assert.Nil(t, message.Ack())
+			jobs, err := newBroker(time.Minute).Consume(ctx, JobsTopic)
+			assert.Nil(t, err)
+			select {
+			case message := <-jobs:
+				t.Fatalf("an acked message was delivered again: %s", message.Data())
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestBrokerRedelivery(t *testing.T) {
+	for name, factory := range brokers {
+		t.Run(name, func(t *testing.T) {
+			newBroker := factory(t)
+			broker := newBroker(200 * time.Millisecond)
+			assert.Nil(t, broker.Publish(JobsTopic, []byte("crashed")))
+			assert.Nil(t, broker.Publish(JobsTopic, []byte("nak")))
+
+			// a consumer receives the message and stops without settling it, as if it crashed
+			crashed, crash := context.WithCancel(context.Background())
+			messages, err := broker.Consume(crashed, JobsTopic)
+			assert.Nil(t, err)
+			lost := receive(t, messages)
+			assert.Equal(t, "crashed", string(lost.Data()))
+			crash()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			messages, err = newBroker(200*time.Millisecond).Consume(ctx, JobsTopic)
+			assert.Nil(t, err)
+			// a message handed back is delivered again right away
+			message := receive(t, messages)
+			assert.Equal(t, "nak", string(message.Data()))
+			assert.Nil(t, message.Nak())
+			message = receive(t, messages)
+			assert.Equal(t, "nak", string(message.Data()))
+			assert.Nil(t, message.Ack())
+
+			// the message of the crashed consumer is delivered once its ack timeout passed
+			message = receive(t, messages)
+			assert.Equal(t, "crashed", string(message.Data()))
+			assert.Nil(t, message.Ack())
+		})
+	}
+}
+
+func TestBrokerInProgress(t *testing.T) {
+	for name, factory := range brokers {
+		t.Run(name, func(t *testing.T) {
+			broker := factory(t)(300 * time.Millisecond)
+			assert.Nil(t, broker.Publish(JobsTopic, []byte("slow")))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			messages, err := broker.Consume(ctx, JobsTopic)
+			assert.Nil(t, err)
+
+			// a message reported in progress is not delivered again past its first ack timeout
+			message := receive(t, messages)
+			for i := 0; i < 5; i++ {
+				time.Sleep(100 * time.Millisecond)
+				assert.Nil(t, message.InProgress())
+			}
+			select {
+			case message := <-messages:
+				t.Fatalf("a message in progress was delivered again: %s", message.Data())
+			default:
+			}
+			assert.Nil(t, message.Ack())
+		})
+	}
+}
+
+func TestBrokerPurge(t *testing.T) {
+	for name, factory := range brokers {
+		t.Run(name, func(t *testing.T) {
+			broker := factory(t)(time.Minute)
+			assert.Nil(t, broker.Publish(JobsTopic, []byte("previous crawl")))
+			assert.Nil(t, broker.Publish(ResultsTopic, []byte("result")))
+
+			// only the messages of the purged topic are removed
+			assert.Nil(t, broker.Purge(JobsTopic))
+			assert.Nil(t, broker.Publish(JobsTopic, []byte("crawl")))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			jobs, err := broker.Consume(ctx, JobsTopic)
+			assert.Nil(t, err)
+			message := receive(t, jobs)
+			assert.Equal(t, "crawl", string(message.Data()))
+			assert.Nil(t, message.Ack())
+			results, err := broker.Consume(ctx, ResultsTopic)
+			assert.Nil(t, err)
+			message = receive(t, results)
+			assert.Equal(t, "result", string(message.Data()))
+			assert.Nil(t, message.Ack())
+		})
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker(50 * time.Millisecond)
+	assert.Nil(t, broker.Publish(JobsTopic, []byte("slow")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := broker.Consume(ctx, JobsTopic)
+	assert.Nil(t, err)
+
+	// a message settled after the ack timeout was handed to another consumer
+	slow := receive(t, messages)
+	redelivered := receive(t, messages)
+	assert.Equal(t, "slow", string(redelivered.Data()))
+	assert.Equal(t, ErrRedelivered, slow.Ack())
+	assert.Equal(t, ErrRedelivered, slow.Nak())
+	assert.Nil(t, redelivered.Ack())
+
+	// closing the broker stops its consumers
+	assert.Nil(t, broker.Close())
+	select {
+	case _, ok := <-messages:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("the messages channel was not closed")
+	}
+	assert.Equal(t, ErrClosed, broker.Publish(JobsTopic, nil))
+	_, err = broker.Consume(ctx, JobsTopic)
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestNATSBrokerErrors(t *testing.T) {
+	url := runNATSServer(t)
+	_, err := NewNATSBroker(url, "invalid stream", time.Second)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error creating the INVALID STREAM nats stream")
+	}
+	_, err = NewNATSBroker("nats://127.0.0.1:1", "crawler", time.Second)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error connecting to nats")
+	}
+}
@@ -0,0 +1,172 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: broker.go
+
+// Package mock_broker is a generated GoMock package.
+package mock_broker
+
+import (
+	context "context"
+	reflect "reflect"
+
+	broker "github.com/csrar/crawler/pkg/broker"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIBroker is a mock of IBroker interface.
+type MockIBroker struct {
+	ctrl     *gomock.Controller
+	recorder *MockIBrokerMockRecorder
+}
+
+// MockIBrokerMockRecorder is the mock recorder for MockIBroker.
+type MockIBrokerMockRecorder struct {
+	mock *MockIBroker
+}
+
+// NewMockIBroker creates a new mock instance.
+func NewMockIBroker(ctrl *gomock.Controller) *MockIBroker {
+	mock := &MockIBroker{ctrl: ctrl}
+	mock.recorder = &MockIBrokerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIBroker) EXPECT() *MockIBrokerMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockIBroker) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockIBrokerMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockIBroker)(nil).Close))
+}
+
+// Consume mocks base method.
+func (m *MockIBroker) Consume(ctx context.Context, topic string) (<-chan broker.IMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, topic)
+	ret0, _ := ret[0].(<-chan broker.IMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockIBrokerMockRecorder) Consume(ctx, topic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockIBroker)(nil).Consume), ctx, topic)
+}
+
+// Publish mocks base method.
+func (m *MockIBroker) Publish(topic string, data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", topic, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockIBrokerMockRecorder) Publish(topic, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockIBroker)(nil).Publish), topic, data)
+}
+
+// Purge mocks base method.
+func (m *MockIBroker) Purge(topic string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", topic)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockIBrokerMockRecorder) Purge(topic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockIBroker)(nil).Purge), topic)
+}
+
+// MockIMessage is a mock of IMessage interface.
+type MockIMessage struct {
+	ctrl     *gomock.Controller
+	recorder *MockIMessageMockRecorder
+}
+
+// MockIMessageMockRecorder is the mock recorder for MockIMessage.
+type MockIMessageMockRecorder struct {
+	mock *MockIMessage
+}
+
+// NewMockIMessage creates a new mock instance.
+func NewMockIMessage(ctrl *gomock.Controller) *MockIMessage {
+	mock := &MockIMessage{ctrl: ctrl}
+	mock.recorder = &MockIMessageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIMessage) EXPECT() *MockIMessageMockRecorder {
+	return m.recorder
+}
+
+// Ack mocks base method.
+func (m *MockIMessage) Ack() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ack")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ack indicates an expected call of Ack.
+func (mr *MockIMessageMockRecorder) Ack() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ack", reflect.TypeOf((*MockIMessage)(nil).Ack))
+}
+
+// Data mocks base method.
+func (m *MockIMessage) Data() []byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Data")
+	ret0, _ := ret[0].([]byte)
+	return ret0
+}
+
+// Data indicates an expected call of Data.
+func (mr *MockIMessageMockRecorder) Data() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Data", reflect.TypeOf((*MockIMessage)(nil).Data))
+}
+
+// InProgress mocks base method.
+func (m *MockIMessage) InProgress() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InProgress")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InProgress indicates an expected call of InProgress.
+func (mr *MockIMessageMockRecorder) InProgress() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InProgress", reflect.TypeOf((*MockIMessage)(nil).InProgress))
+}
+
+// Nak mocks base method.
+func (m *MockIMessage) Nak() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Nak")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Nak indicates an expected call of Nak.
+func (mr *MockIMessageMockRecorder) Nak() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Nak", reflect.TypeOf((*MockIMessage)(nil).Nak))
+}
@@ -0,0 +1,149 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fetchWait is how long a consumer waits for a message before checking whether it was stopped.
+const fetchWait = time.Second
+
+// natsBroker keeps the messages in a JetStream work queue stream of the NATS server, so they
+// outlive the processes publishing and consuming them. The consumers of a topic share a durable
+// consumer, each message is delivered to one of them and removed once acked.
+type natsBroker struct {
+	conn       *nats.Conn
+	js         nats.JetStreamContext
+	prefix     string
+	stream     string
+	ackTimeout time.Duration
+}
+
+type natsMessage struct {
+	msg *nats.Msg
+}
+
+// NewNATSBroker connects to the NATS server of url and creates the stream keeping the topics under
+// the prefix subject, redelivering the messages not acked within ackTimeout.
+func NewNATSBroker(url, prefix string, ackTimeout time.Duration) (IBroker, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to nats: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening the nats jetstream context: %w", err)
+	}
+	b := &natsBroker{conn: conn, js: js, prefix: prefix, stream: strings.ToUpper(prefix), ackTimeout: ackTimeout}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:      b.stream,
+		Subjects:  []string{prefix + ".>"},
+		Retention: nats.WorkQueuePolicy,
+		Storage:   nats.FileStorage,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error creating the %s nats stream: %w", b.stream, err)
+	}
+	return b, nil
+}
+
+// Publish stores data in the stream under the subject of the topic.
+func (b *natsBroker) Publish(topic string, data []byte) error {
+	if _, err := b.js.Publish(b.subject(topic), data); err != nil {
+		return fmt.Errorf("error publishing to %s: %w", b.subject(topic), err)
+	}
+	return nil
+}
+
+// Consume fetches the messages of the topic from the durable consumer of the topic, creating it
+// the first time.
+func (b *natsBroker) Consume(ctx context.Context, topic string) (<-chan IMessage, error) {
+	durable := b.stream + "_" + strings.ToUpper(topic)
+	_, err := b.js.AddConsumer(b.stream, &nats.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: b.subject(topic),
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       b.ackTimeout,
+	})
+	if err != nil && !errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
+		return nil, fmt.Errorf("error creating the %s nats consumer: %w", durable, err)
+	}
+	// the subscription is bound to the consumer, so unsubscribing leaves it to the other processes
+	sub, err := b.js.PullSubscribe(b.subject(topic), durable, nats.Bind(b.stream, durable))
+	if err != nil {
+		return nil, fmt.Errorf("error subscribing to %s: %w", b.subject(topic), err)
+	}
+	messages := make(chan IMessage)
+	go func() {
+		defer close(messages)
+		defer func() {
+			_ = sub.Unsubscribe()
+		}()
+		for ctx.Err() == nil {
+			fetchCtx, cancel := context.WithTimeout(ctx, fetchWait)
+			msgs, err := sub.Fetch(1, nats.Context(fetchCtx))
+			cancel()
+			if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrBadSubscription) {
+				return
+			}
+			for _, msg := range msgs {
+				select {
+				case messages <- &natsMessage{msg: msg}:
+				case <-ctx.Done():
+					_ = msg.Nak()
+					return
+				}
+			}
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) && ctx.Err() == nil {
+				// the client reconnects on its own, the fetch is retried after a while
+				wait(ctx, nil, fetchWait)
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// Purge removes the messages of the topic from the stream.
+func (b *natsBroker) Purge(topic string) error {
+	if err := b.js.PurgeStream(b.stream, &nats.StreamPurgeRequest{Subject: b.subject(topic)}); err != nil {
+		return fmt.Errorf("error purging %s: %w", b.subject(topic), err)
+	}
+	return nil
+}
+
+// Close closes the connection, the messages are left in the stream.
+func (b *natsBroker) Close() error {
+	b.conn.Close()
+	return nil
+}
+
+// subject returns the NATS subject of the topic.
+func (b *natsBroker) subject(topic string) string {
+	return b.prefix + "." + topic
+}
+
+func (m *natsMessage) Data() []byte {
+	return m.msg.Data
+}
+
+// Ack removes the message from the stream.
+func (m *natsMessage) Ack() error {
+	return m.msg.Ack()
+}
+
+// Nak has the server redeliver the message right away.
+func (m *natsMessage) Nak() error {
+	return m.msg.Nak()
+}
+
+// InProgress has the server restart the ack wait of the message.
+func (m *natsMessage) InProgress() error {
+	return m.msg.InProgress()
+}
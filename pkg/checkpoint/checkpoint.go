@@ -0,0 +1,87 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+const checkpointFile = "checkpoint.json"
+
+//go:generate mockgen -source=checkpoint.go -destination=mocks/checkpoint_mock.go
+type ICheckpointer interface {
+	Save(state models.Checkpoint) error
+	Load() (models.Checkpoint, error)
+}
+
+// checkpointer keeps the last checkpoint of a crawl in a directory.
+type checkpointer struct {
+	dir string
+}
+
+// NewCheckpointer creates a checkpointer writing to dir, creating it when it does not exist.
+func NewCheckpointer(dir string) (ICheckpointer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating checkpoint directory: %w", err)
+	}
+	return &checkpointer{dir: dir}, nil
+}
+
+// Save replaces the last checkpoint with the state, a crash while saving leaves the previous one.
+func (c *checkpointer) Save(state models.Checkpoint) error {
+	state.Time = time.Now()
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error marshaling checkpoint: %w", err)
+	}
+	path := filepath.Join(c.dir, checkpointFile)
+	tmp, err := os.CreateTemp(c.dir, checkpointFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing checkpoint: %w", err)
+	}
+	dir, err := os.Open(c.dir)
+	if err != nil {
+		return fmt.Errorf("error opening checkpoint directory: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("error syncing checkpoint directory: %w", err)
+	}
+	return nil
+}
+
+// Load reads the last checkpoint saved in the directory.
+func (c *checkpointer) Load() (models.Checkpoint, error) {
+	state := models.Checkpoint{}
+	payload, err := os.ReadFile(filepath.Join(c.dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, fmt.Errorf("no checkpoint found in %s", c.dir)
+	}
+	if err != nil {
+		return state, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return state, fmt.Errorf("error decoding checkpoint: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,73 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	checkpoints, err := NewCheckpointer(dir)
+	assert.Nil(t, err)
+
+	first := models.Checkpoint{
+		Site:     "https://mock-site.com/",
+		Frontier: []models.CrawlItem{{URL: "https://mock-site.com/a", Depth: 1, Parent: "https://mock-site.com/", Tag: "a", Attr: "href"}},
+		Visited:  []string{"//mock-site.com/", "//mock-site.com/a"},
+		Stats:    models.CrawlStats{Found: 2, Processed: 1},
+	}
+	assert.Nil(t, checkpoints.Save(first))
+	second := first
+	second.Frontier = []models.CrawlItem{}
+	second.Stats = models.CrawlStats{Found: 2, Processed: 2}
+	assert.Nil(t, checkpoints.Save(second))
+
+	state, err := checkpoints.Load()
+	assert.Nil(t, err)
+	assert.False(t, state.Time.IsZero())
+	state.Time = second.Time
+	assert.Equal(t, second, state)
+
+	// the checkpoint is replaced, no temporary file is left behind
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "no checkpoint",
+			expected: "no checkpoint found in",
+		},
+		{
+			name:     "corrupted checkpoint",
+			content:  `{"site":`,
+			expected: "error decoding checkpoint",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.content != "" {
+				assert.Nil(t, os.WriteFile(filepath.Join(dir, checkpointFile), []byte(tc.content), 0644))
+			}
+			checkpoints, err := NewCheckpointer(dir)
+			assert.Nil(t, err)
+
+			_, err = checkpoints.Load()
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expected)
+			}
+		})
+	}
+}
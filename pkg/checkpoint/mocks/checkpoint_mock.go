@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: checkpoint.go
+
+// Package mock_checkpoint is a generated GoMock package.
+package mock_checkpoint
+
+import (
+	reflect "reflect"
+
+	models "github.com/csrar/crawler/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockICheckpointer is a mock of ICheckpointer interface.
+type MockICheckpointer struct {
+	ctrl     *gomock.Controller
+	recorder *MockICheckpointerMockRecorder
+}
+
+// MockICheckpointerMockRecorder is the mock recorder for MockICheckpointer.
+type MockICheckpointerMockRecorder struct {
+	mock *MockICheckpointer
+}
+
+// NewMockICheckpointer creates a new mock instance.
+func NewMockICheckpointer(ctrl *gomock.Controller) *MockICheckpointer {
+	mock := &MockICheckpointer{ctrl: ctrl}
+	mock.recorder = &MockICheckpointerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockICheckpointer) EXPECT() *MockICheckpointerMockRecorder {
+	return m.recorder
+}
+
+// Load mocks base method.
+func (m *MockICheckpointer) Load() (models.Checkpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Load")
+	ret0, _ := ret[0].(models.Checkpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Load indicates an expected call of Load.
+func (mr *MockICheckpointerMockRecorder) Load() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockICheckpointer)(nil).Load))
+}
+
+// Save mocks base method.
+func (m *MockICheckpointer) Save(state models.Checkpoint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockICheckpointerMockRecorder) Save(state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockICheckpointer)(nil).Save), state)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/csrar/crawler/internal/models"
 )
@@ -17,31 +19,98 @@ type IConfig interface {
 	GetConfig() models.Config
 }
 
+// NewConfig reads the configuration from the environment variables, the ones not set take their
+// default value.
 func NewConfig() IConfig {
-	cfg := models.Config{}
-	cfg.WepPage = getStringVal(keyWebPage, defaultWebPage)
-	cfg.Workers = getIntValue(keyWorkers, defaultWorkers)
-	cfg.QueueSize = detaultQueueSize
+	return &config{
+		cfg: load(os.Getenv),
+	}
+}
+
+// NewStaticConfig returns cfg as the configuration.
+func NewStaticConfig(cfg models.Config) IConfig {
 	return &config{
 		cfg: cfg,
 	}
 }
 
+// Defaults returns the default configuration, whatever the environment variables.
+func Defaults() models.Config {
+	return load(func(string) string { return "" })
+}
+
+// load builds the configuration from the values getenv returns for the environment variables.
+func load(getenv func(key string) string) models.Config {
+	cfg := models.Config{}
+	cfg.WepPage = getStringVal(getenv, keyWebPage, defaultWebPage)
+	cfg.Workers = getIntValue(getenv, keyWorkers, defaultWorkers)
+	cfg.UserAgent = getStringVal(getenv, keyUserAgent, defaultUserAgent)
+	cfg.HostDelay = time.Duration(getIntValue(getenv, keyHostDelay, defaultHostDelay)) * time.Millisecond
+	cfg.HostConcurrency = getIntValue(getenv, keyHostLimit, defaultHostLimit)
+	cfg.ShutdownTimeout = time.Duration(getIntValue(getenv, keyShutdown, defaultShutdown)) * time.Millisecond
+	cfg.MaxDepth = getIntValue(getenv, keyMaxDepth, defaultMaxDepth)
+	cfg.MaxPages = getIntValue(getenv, keyMaxPages, defaultMaxPages)
+	cfg.MaxDuration = time.Duration(getIntValue(getenv, keyMaxTime, defaultMaxTime)) * time.Millisecond
+	cfg.DropQueryParams = getListValue(getenv, keyDropQuery, defaultDropQuery)
+	cfg.LinkSources = getListValue(getenv, keySources, defaultSources)
+	cfg.AssetMode = getBoolValue(getenv, keyAssetMode, defaultAssetMode)
+	cfg.AssetMaxBytes = int64(getIntValue(getenv, keyAssetSize, defaultAssetSize))
+	cfg.AssetWorkers = getIntValue(getenv, keyAssetJobs, defaultAssetJobs)
+	cfg.AssetQueueSize = getIntValue(getenv, keyAssetWait, defaultAssetWait)
+	cfg.HonorDirectives = getBoolValue(getenv, keyDirective, defaultDirective)
+	cfg.ContentTypes = getListValue(getenv, keyTypes, defaultTypes)
+	cfg.HeadProbe = getBoolValue(getenv, keyHeadProbe, defaultHeadProbe)
+	cfg.MaxBodyBytes = int64(getIntValue(getenv, keyBodySize, defaultBodySize))
+	cfg.StoreBackend = storeBackend(getenv)
+	cfg.StoreDir = getStringVal(getenv, keyStoreDir, defaultStoreDir)
+	cfg.StoreCompactEvery = getIntValue(getenv, keyCompact, defaultCompact)
+	cfg.StoreSnapshotInterval = time.Duration(getIntValue(getenv, keySnapshot, defaultSnapshot)) * time.Millisecond
+	cfg.SQLitePath = getStringVal(getenv, keySQLite, defaultSQLite)
+	cfg.BloomFalsePositiveRate = getFloatValue(getenv, keyBloomRate, defaultBloomRate)
+	cfg.BloomCapacity = getIntValue(getenv, keyBloomSize, defaultBloomSize)
+	cfg.BloomVerifyDir = getStringVal(getenv, keyBloomDir, defaultBloomDir)
+	cfg.FrontierBackend = getStringVal(getenv, keyFrontierBackend, defaultFrontierBackend)
+	cfg.FrontierDir = getStringVal(getenv, keyFrontierDir, defaultFrontierDir)
+	cfg.FrontierMemoryItems = getIntValue(getenv, keyFrontierMemory, defaultFrontierMemory)
+	cfg.RedisURL = getStringVal(getenv, keyRedisURL, defaultRedisURL)
+	cfg.RedisKeyPrefix = getStringVal(getenv, keyRedisKeys, defaultRedisKeys)
+	cfg.RedisPollInterval = time.Duration(getIntValue(getenv, keyRedisPoll, defaultRedisPoll)) * time.Millisecond
+	cfg.RedisLeaseTimeout = time.Duration(getIntValue(getenv, keyRedisLease, defaultRedisLease)) * time.Millisecond
+	cfg.QueueURL = getStringVal(getenv, keyQueueURL, defaultQueueURL)
+	cfg.QueueSubjectPrefix = getStringVal(getenv, keyQueuePrefix, defaultQueuePrefix)
+	cfg.QueueAckTimeout = time.Duration(getIntValue(getenv, keyAckWait, defaultAckWait)) * time.Millisecond
+	cfg.CheckpointDir = getStringVal(getenv, keyCheckDir, defaultCheckDir)
+	cfg.CheckpointInterval = time.Duration(getIntValue(getenv, keyCheckTime, defaultCheckTime)) * time.Millisecond
+	cfg.ConnectTimeout = time.Duration(getIntValue(getenv, keyConnect, defaultConnect)) * time.Millisecond
+	cfg.ReadTimeout = time.Duration(getIntValue(getenv, keyRead, defaultRead)) * time.Millisecond
+	cfg.RequestTimeout = time.Duration(getIntValue(getenv, keyRequest, defaultRequest)) * time.Millisecond
+	cfg.ExtraHeaders = getHeadersValue(getenv, keyHeaders, defaultHeaders)
+	cfg.ProxyURL = getStringVal(getenv, keyProxy, defaultProxy)
+	cfg.TLSCAFile = getStringVal(getenv, keyCAFile, defaultCAFile)
+	cfg.TLSCertFile = getStringVal(getenv, keyCertFile, defaultCertFile)
+	cfg.TLSKeyFile = getStringVal(getenv, keyKeyFile, defaultKeyFile)
+	cfg.TLSInsecureSkipVerify = getBoolValue(getenv, keyInsecure, defaultInsecure)
+	cfg.RetryMaxAttempts = getIntValue(getenv, keyAttempts, defaultAttempts)
+	cfg.RetryBaseDelay = time.Duration(getIntValue(getenv, keyRetryBase, defaultRetryBase)) * time.Millisecond
+	cfg.RetryMaxDelay = time.Duration(getIntValue(getenv, keyRetryMax, defaultRetryMax)) * time.Millisecond
+	return cfg
+}
+
 func (c *config) GetConfig() models.Config {
 	return c.cfg
 }
 
-func getStringVal(key string, def string) string {
-	val := os.Getenv(key)
+func getStringVal(getenv func(key string) string, key string, def string) string {
+	val := getenv(key)
 	if val == "" {
 		return def
 	}
 	return val
 }
 
-func getIntValue(key string, def int) int {
+func getIntValue(getenv func(key string) string, key string, def int) int {
 	returnValue := 0
-	val := os.Getenv(key)
+	val := getenv(key)
 	if val == "" {
 		return def
 	}
@@ -52,3 +121,71 @@ func getIntValue(key string, def int) int {
 	}
 	return returnValue
 }
+
+func getBoolValue(getenv func(key string) string, key string, def bool) bool {
+	val := getenv(key)
+	if val == "" {
+		return def
+	}
+	returnValue, err := strconv.ParseBool(val)
+	if err != nil {
+		fmt.Printf("invalid boolean value for %s, app will use default value: %t\n", key, def)
+		return def
+	}
+	return returnValue
+}
+
+// storeBackend returns the store backend of STORE_BACKEND. Without it the store options select the
+// backend as they did before the backends were named: STORE_DIR the file backend, a positive
+// BLOOM_FALSE_POSITIVE_RATE the bloom one and the default backend otherwise.
+func storeBackend(getenv func(key string) string) string {
+	switch {
+	case getenv(keyBackend) != "":
+		return getenv(keyBackend)
+	case getenv(keyStoreDir) != "":
+		return "file"
+	case getFloatValue(getenv, keyBloomRate, 0) > 0:
+		return "bloom"
+	}
+	return defaultBackend
+}
+
+func getFloatValue(getenv func(key string) string, key string, def float64) float64 {
+	val := getenv(key)
+	if val == "" {
+		return def
+	}
+	returnValue, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		fmt.Printf("invalid float value for %s, app will use default value: %g\n", key, def)
+		return def
+	}
+	return returnValue
+}
+
+func getListValue(getenv func(key string) string, key string, def string) []string {
+	list := []string{}
+	for _, item := range strings.Split(getStringVal(getenv, key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getHeadersValue parses a list of headers separated by ";" such as "Accept-Language: es; X-Team: seo".
+func getHeadersValue(getenv func(key string) string, key string, def string) map[string]string {
+	headers := map[string]string{}
+	for _, header := range strings.Split(getStringVal(getenv, key, def), ";") {
+		name, value, found := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			if strings.TrimSpace(header) != "" {
+				fmt.Printf("invalid header %q in %s, it will be ignored\n", header, key)
+			}
+			continue
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers
+}
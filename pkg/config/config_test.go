@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -59,16 +60,132 @@ func TestConfig(t *testing.T) {
 			defer os.Unsetenv(tc.envKey)
 
 			if tc.defaultVal == "default_value" {
-				actual := getStringVal(tc.envKey, tc.defaultVal.(string))
+				actual := getStringVal(os.Getenv, tc.envKey, tc.defaultVal.(string))
 				assert.Equal(t, tc.expectedVal.(string), actual)
 			} else {
-				actual := getIntValue(tc.envKey, tc.defaultVal.(int))
+				actual := getIntValue(os.Getenv, tc.envKey, tc.defaultVal.(int))
 				assert.Equal(t, tc.expectedVal.(int), actual)
 			}
 		})
 	}
 }
 
+func TestGetBoolValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		envValue    string
+		defaultVal  bool
+		expectedVal bool
+	}{
+		{
+			name:        "valid boolean",
+			envValue:    "true",
+			defaultVal:  false,
+			expectedVal: true,
+		},
+		{
+			name:        "invalid boolean",
+			envValue:    "yes please",
+			defaultVal:  false,
+			expectedVal: false,
+		},
+		{
+			name:        "missing boolean",
+			envValue:    "",
+			defaultVal:  true,
+			expectedVal: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("BOOL_KEY", tc.envValue)
+			defer os.Unsetenv("BOOL_KEY")
+
+			actual := getBoolValue(os.Getenv, "BOOL_KEY", tc.defaultVal)
+			assert.Equal(t, tc.expectedVal, actual)
+		})
+	}
+}
+
+func TestGetFloatValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		envValue    string
+		defaultVal  float64
+		expectedVal float64
+	}{
+		{
+			name:        "valid float",
+			envValue:    "0.001",
+			defaultVal:  0,
+			expectedVal: 0.001,
+		},
+		{
+			name:        "scientific notation",
+			envValue:    "1e-4",
+			defaultVal:  0,
+			expectedVal: 0.0001,
+		},
+		{
+			name:        "invalid float",
+			envValue:    "one percent",
+			defaultVal:  0.01,
+			expectedVal: 0.01,
+		},
+		{
+			name:        "missing float",
+			envValue:    "",
+			defaultVal:  0.01,
+			expectedVal: 0.01,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("FLOAT_KEY", tc.envValue)
+			defer os.Unsetenv("FLOAT_KEY")
+
+			actual := getFloatValue(os.Getenv, "FLOAT_KEY", tc.defaultVal)
+			assert.Equal(t, tc.expectedVal, actual)
+		})
+	}
+}
+
+func TestGetHeadersValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		envValue    string
+		expectedVal map[string]string
+	}{
+		{
+			name:        "missing headers",
+			envValue:    "",
+			expectedVal: map[string]string{},
+		},
+		{
+			name:        "several headers",
+			envValue:    "Accept-Language: es, en; X-Team:seo",
+			expectedVal: map[string]string{"Accept-Language": "es, en", "X-Team": "seo"},
+		},
+		{
+			name:        "invalid header is ignored",
+			envValue:    "X-Team: seo; invalid",
+			expectedVal: map[string]string{"X-Team": "seo"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("HEADERS_KEY", tc.envValue)
+			defer os.Unsetenv("HEADERS_KEY")
+
+			actual := getHeadersValue(os.Getenv, "HEADERS_KEY", "")
+			assert.Equal(t, tc.expectedVal, actual)
+		})
+	}
+}
+
 func TestNewConfig(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -177,3 +294,107 @@ func TestGetConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaults(t *testing.T) {
+	os.Setenv(keyWorkers, "3")
+	defer os.Unsetenv(keyWorkers)
+
+	assert.Equal(t, 3, NewConfig().GetConfig().Workers)
+	// the environment variables are ignored
+	cfg := Defaults()
+	assert.Equal(t, defaultWorkers, cfg.Workers)
+	assert.Equal(t, defaultWebPage, cfg.WepPage)
+	assert.Equal(t, []string{"a", "area", "link", "iframe", "frame", "meta"}, cfg.LinkSources)
+	assert.Equal(t, cfg, NewStaticConfig(cfg).GetConfig())
+}
+
+func TestStoreBackend(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		expectedBackend string
+		expectedDir     string
+	}{
+		{
+			name:            "defaults",
+			env:             map[string]string{},
+			expectedBackend: defaultBackend,
+			expectedDir:     defaultStoreDir,
+		},
+		{
+			name:            "store dir alone selects the file backend",
+			env:             map[string]string{keyStoreDir: "data"},
+			expectedBackend: "file",
+			expectedDir:     "data",
+		},
+		{
+			name:            "bloom rate alone selects the bloom backend",
+			env:             map[string]string{keyBloomRate: "0.01"},
+			expectedBackend: "bloom",
+			expectedDir:     defaultStoreDir,
+		},
+		{
+			name:            "zero bloom rate keeps the default backend",
+			env:             map[string]string{keyBloomRate: "0"},
+			expectedBackend: defaultBackend,
+			expectedDir:     defaultStoreDir,
+		},
+		{
+			name:            "store dir wins over the bloom rate",
+			env:             map[string]string{keyStoreDir: "data", keyBloomRate: "0.01"},
+			expectedBackend: "file",
+			expectedDir:     "data",
+		},
+		{
+			name:            "explicit backend wins over the store dir",
+			env:             map[string]string{keyBackend: "sqlite", keyStoreDir: "data"},
+			expectedBackend: "sqlite",
+			expectedDir:     "data",
+		},
+		{
+			name:            "explicit backend wins over the bloom rate",
+			env:             map[string]string{keyBackend: "memory", keyBloomRate: "0.01"},
+			expectedBackend: "memory",
+			expectedDir:     defaultStoreDir,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := load(func(key string) string { return test.env[key] })
+			assert.Equal(t, test.expectedBackend, cfg.StoreBackend)
+			assert.Equal(t, test.expectedDir, cfg.StoreDir)
+		})
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected time.Duration
+	}{
+		{
+			name:     "unlimited by default",
+			env:      map[string]string{},
+			expected: 0,
+		},
+		{
+			name:     "milliseconds",
+			env:      map[string]string{keyMaxTime: "90000"},
+			expected: 90 * time.Second,
+		},
+		{
+			name:     "invalid value",
+			env:      map[string]string{keyMaxTime: "90s"},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := load(func(key string) string { return test.env[key] })
+			assert.Equal(t, test.expected, cfg.MaxDuration)
+		})
+	}
+}
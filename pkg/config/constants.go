@@ -2,11 +2,108 @@ package config
 
 const (
 	// environment variables names
-	keyWebPage = "WEB_PAGE"
-	keyWorkers = "WORKERS"
+	keyWebPage         = "WEB_PAGE"
+	keyWorkers         = "WORKERS"
+	keyUserAgent       = "USER_AGENT"
+	keyHostDelay       = "HOST_DELAY_MS"
+	keyHostLimit       = "HOST_MAX_CONCURRENCY"
+	keyShutdown        = "SHUTDOWN_TIMEOUT_MS"
+	keyMaxDepth        = "MAX_DEPTH"
+	keyMaxPages        = "MAX_PAGES"
+	keyMaxTime         = "MAX_DURATION_MS"
+	keyDropQuery       = "DROP_QUERY_PARAMS"
+	keySources         = "LINK_SOURCES"
+	keyAssetMode       = "ASSET_MODE"
+	keyAssetSize       = "ASSET_MAX_BYTES"
+	keyAssetJobs       = "ASSET_WORKERS"
+	keyAssetWait       = "ASSET_QUEUE_SIZE"
+	keyDirective       = "HONOR_ROBOTS_DIRECTIVES"
+	keyTypes           = "PAGE_CONTENT_TYPES"
+	keyHeadProbe       = "HEAD_PROBE"
+	keyBodySize        = "MAX_BODY_BYTES"
+	keyBackend         = "STORE_BACKEND"
+	keyStoreDir        = "STORE_DIR"
+	keyCompact         = "STORE_COMPACT_EVERY"
+	keySnapshot        = "STORE_SNAPSHOT_INTERVAL_MS"
+	keySQLite          = "SQLITE_PATH"
+	keyBloomRate       = "BLOOM_FALSE_POSITIVE_RATE"
+	keyBloomSize       = "BLOOM_CAPACITY"
+	keyBloomDir        = "BLOOM_VERIFY_DIR"
+	keyFrontierBackend = "FRONTIER_BACKEND"
+	keyFrontierDir     = "FRONTIER_DIR"
+	keyFrontierMemory  = "FRONTIER_MEMORY_ITEMS"
+	keyRedisURL        = "REDIS_URL"
+	keyRedisKeys       = "REDIS_KEY_PREFIX"
+	keyRedisPoll       = "REDIS_POLL_INTERVAL_MS"
+	keyRedisLease      = "REDIS_LEASE_MS"
+	keyQueueURL        = "QUEUE_URL"
+	keyQueuePrefix     = "QUEUE_SUBJECT_PREFIX"
+	keyAckWait         = "QUEUE_ACK_TIMEOUT_MS"
+	keyCheckDir        = "CHECKPOINT_DIR"
+	keyCheckTime       = "CHECKPOINT_INTERVAL_MS"
+	keyConnect         = "CONNECT_TIMEOUT_MS"
+	keyRead            = "READ_TIMEOUT_MS"
+	keyRequest         = "REQUEST_TIMEOUT_MS"
+	keyHeaders         = "EXTRA_HEADERS"
+	keyProxy           = "PROXY_URL"
+	keyCAFile          = "TLS_CA_FILE"
+	keyCertFile        = "TLS_CERT_FILE"
+	keyKeyFile         = "TLS_KEY_FILE"
+	keyInsecure        = "TLS_INSECURE_SKIP_VERIFY"
+	keyAttempts        = "RETRY_MAX_ATTEMPTS"
+	keyRetryBase       = "RETRY_BASE_DELAY_MS"
+	keyRetryMax        = "RETRY_MAX_DELAY_MS"
 
 	// default values
-	defaultWebPage   = "https://parserdigital.com/"
-	defaultWorkers   = 10
-	detaultQueueSize = 100000
+	defaultWebPage         = "https://parserdigital.com/"
+	defaultWorkers         = 10
+	defaultUserAgent       = "crawler"
+	defaultHostDelay       = 100
+	defaultHostLimit       = 4
+	defaultShutdown        = 10000
+	defaultMaxDepth        = 0
+	defaultMaxPages        = 0
+	defaultMaxTime         = 0
+	defaultDropQuery       = "utm_*,gclid,fbclid,msclkid,mc_cid,mc_eid"
+	defaultSources         = "a,area,link,iframe,frame,meta"
+	defaultAssetMode       = false
+	defaultAssetSize       = 1024 * 1024
+	defaultAssetJobs       = 4
+	defaultAssetWait       = 1000
+	defaultDirective       = false
+	defaultTypes           = "text/html,application/xhtml+xml"
+	defaultHeadProbe       = false
+	defaultBodySize        = 10 * 1024 * 1024
+	defaultBackend         = "memory"
+	defaultStoreDir        = "store"
+	defaultCompact         = 10000
+	defaultSnapshot        = 10000
+	defaultSQLite          = "crawl.db"
+	defaultBloomRate       = 0.001
+	defaultBloomSize       = 1000000
+	defaultBloomDir        = ""
+	defaultFrontierBackend = "disk"
+	defaultFrontierDir     = ""
+	defaultFrontierMemory  = 10000
+	defaultRedisURL        = "redis://localhost:6379/0"
+	defaultRedisKeys       = "crawler"
+	defaultRedisPoll       = 200
+	defaultRedisLease      = 30000
+	defaultQueueURL        = "nats://localhost:4222"
+	defaultQueuePrefix     = "crawler"
+	defaultAckWait         = 120000
+	defaultCheckDir        = ""
+	defaultCheckTime       = 30000
+	defaultConnect         = 10000
+	defaultRead            = 30000
+	defaultRequest         = 60000
+	defaultHeaders         = ""
+	defaultProxy           = ""
+	defaultCAFile          = ""
+	defaultCertFile        = ""
+	defaultKeyFile         = ""
+	defaultInsecure        = false
+	defaultAttempts        = 3
+	defaultRetryBase       = 500
+	defaultRetryMax        = 30000
 )
@@ -0,0 +1,333 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
+	"github.com/csrar/crawler/pkg/store"
+	"golang.org/x/net/html"
+)
+
+const (
+	// assetKeyPrefix keeps asset keys apart from page keys in the store.
+	assetKeyPrefix = "asset:"
+	// maxStylesheetSize is the maximum number of bytes read from a stylesheet to find its references.
+	maxStylesheetSize = 1024 * 1024
+)
+
+// cssURLPattern matches url(...) references and @import strings in CSS.
+var cssURLPattern = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)|@import\s+['"]([^'"]+)['"]`)
+
+// assetSources lists the attributes of each tag that reference an asset.
+var assetSources = map[string][]string{
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"link":   {"href"},
+}
+
+//go:generate mockgen -source=assets.go -destination=mocks/assets_mock.go
+type IAssetChecker interface {
+	Check(ctx context.Context, asset *url.URL, link models.Link, parent string)
+	Shutdown(ctx context.Context) error
+}
+
+// assetChecker checks the assets on its own workers, so the crawl of a page does not wait for the
+// requests of its assets. The checks are queued up to the size of the checks channel, a page waits
+// for room in the queue once it is full. The requests to the crawled host wait for the politeness
+// scheduler like the ones of the pages.
+type assetChecker struct {
+	logger     logger.Ilogger
+	store      store.ICrawlerStore
+	normalizer INormalizer
+	fetcher    IFetcher
+	robots     robots.IRobots
+	scheduler  politeness.IScheduler
+	// host is the canonical host of the crawl, the robots.txt rules and politeness apply to it
+	host     string
+	maxBytes int64
+
+	checks chan assetCheck
+	wg     sync.WaitGroup
+	// ctx is the context of the requests, cancelled once the shutdown does not let them finish
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.RWMutex
+	closed bool
+}
+
+// assetCheck is an asset waiting to be checked.
+type assetCheck struct {
+	asset  *url.URL
+	link   models.Link
+	parent string
+}
+
+// NewAssetChecker creates a checker that verifies assets with HEAD requests on workers goroutines,
+// recording them in the store and flagging the ones bigger than maxBytes. Up to queueSize assets
+// wait to be checked. The assets of the host of root are requested as the rules and the scheduler
+// allow.
+func NewAssetChecker(log logger.Ilogger, store store.ICrawlerStore, normalizer INormalizer, fetcher IFetcher, root *url.URL,
+	rules robots.IRobots, scheduler politeness.IScheduler, maxBytes int64, workers int, queueSize int) IAssetChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &assetChecker{
+		logger:     log,
+		store:      store,
+		normalizer: normalizer,
+		fetcher:    fetcher,
+		robots:     rules,
+		scheduler:  scheduler,
+		host:       normalizer.Normalize(root).Host,
+		maxBytes:   maxBytes,
+		checks:     make(chan assetCheck, queueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			for check := range a.checks {
+				if a.ctx.Err() != nil {
+					// shutting down, the queued assets are dropped
+					continue
+				}
+				a.check(a.ctx, check.asset, check.link, check.parent)
+			}
+		}()
+	}
+	return a
+}
+
+// Check queues the asset to be checked once, waiting for room in the queue until the context is
+// done. Assets found once the checker is shut down are dropped.
+func (a *assetChecker) Check(ctx context.Context, asset *url.URL, link models.Link, parent string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.checks <- assetCheck{asset: asset, link: link, parent: parent}:
+	case <-ctx.Done():
+	case <-a.ctx.Done():
+	}
+}
+
+// Shutdown stops taking assets and waits for the queued ones to be checked. Once the context is
+// done the requests are cancelled and the assets left are dropped, its error is returned.
+func (a *assetChecker) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.checks)
+	}
+	a.mu.Unlock()
+	defer a.cancel()
+	finished := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		a.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// check requests the asset once, records its status, size and content type, and checks the
+// references of stylesheets.
+func (a *assetChecker) check(ctx context.Context, asset *url.URL, link models.Link, parent string) {
+	if asset.Scheme != "http" && asset.Scheme != "https" {
+		return
+	}
+	// the asset is requested as linked, its canonical form is only the key it is deduplicated by
+	asset = withoutFragment(asset)
+	visited, err := a.store.WasAlreadyVisited(assetKeyPrefix + a.normalizer.Key(asset))
+	if err != nil {
+		a.logger.Error(err)
+		return
+	}
+	if visited {
+		return
+	}
+
+	result := models.AssetResult{
+		URL:    asset.String(),
+		Parent: parent,
+		Tag:    link.Tag,
+		Attr:   link.Attr,
+	}
+	response, err := a.fetch(ctx, http.MethodHead, asset)
+	if err == nil && (response.StatusCode == http.StatusMethodNotAllowed || response.StatusCode == http.StatusNotImplemented) {
+		// some servers do not support HEAD, the body of the GET is not read
+		response.Body.Close()
+		response, err = a.fetch(ctx, http.MethodGet, asset)
+	}
+	if err != nil && ctx.Err() != nil {
+		// the check was cancelled by the shutdown, the asset is not broken
+		return
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Broken = true
+	} else {
+		response.Body.Close()
+		result.StatusCode = response.StatusCode
+		result.ContentType = response.Header.Get("Content-Type")
+		result.Size = response.ContentLength
+		result.Broken = response.StatusCode >= http.StatusBadRequest
+		result.Oversized = a.maxBytes > 0 && response.ContentLength > a.maxBytes
+	}
+
+	switch {
+	case result.Broken:
+		a.logger.Warn(fmt.Sprintf("broken asset: %s (status: %d %s) found in: %s", result.URL, result.StatusCode, result.Error, parent))
+	case result.Oversized:
+		a.logger.Warn(fmt.Sprintf("oversized asset: %s (%d bytes) found in: %s", result.URL, result.Size, parent))
+	}
+	if err := a.store.RecordAsset(result); err != nil {
+		a.logger.Error(err)
+	}
+
+	if !result.Broken && isStylesheet(result.ContentType) {
+		a.checkStylesheet(ctx, asset)
+	}
+}
+
+// checkStylesheet downloads a stylesheet and checks the assets it references.
+func (a *assetChecker) checkStylesheet(ctx context.Context, stylesheet *url.URL) {
+	response, err := a.fetch(ctx, http.MethodGet, stylesheet)
+	if err != nil {
+		a.logger.Error(fmt.Errorf("error fetching stylesheet %s: %v", stylesheet, err))
+		return
+	}
+	defer response.Body.Close()
+	css, err := io.ReadAll(io.LimitReader(response.Body, maxStylesheetSize))
+	if err != nil {
+		a.logger.Error(fmt.Errorf("error reading stylesheet %s: %v", stylesheet, err))
+		return
+	}
+	// the references are checked by this worker, queueing them could wait for itself on a full queue
+	for _, reference := range cssURLs(string(css)) {
+		asset, err := stylesheet.Parse(reference)
+		if err != nil {
+			continue
+		}
+		if a.onHost(asset) && !a.robots.IsAssetAllowed(asset) {
+			continue
+		}
+		a.check(ctx, asset, models.Link{Href: reference, Tag: "css", Attr: "url"}, stylesheet.String())
+	}
+}
+
+// fetch requests the asset, holding a slot of the politeness scheduler when it is on the crawled host.
+func (a *assetChecker) fetch(ctx context.Context, method string, asset *url.URL) (*http.Response, error) {
+	if !a.onHost(asset) {
+		return a.fetcher.Fetch(ctx, method, asset.String())
+	}
+	if err := a.scheduler.Acquire(ctx, asset.Host); err != nil {
+		return nil, err
+	}
+	defer a.scheduler.Release(asset.Host)
+	return a.fetcher.Fetch(ctx, method, asset.String())
+}
+
+// onHost reports whether the asset is on the crawled host.
+func (a *assetChecker) onHost(asset *url.URL) bool {
+	return a.normalizer.Normalize(asset).Host == a.host
+}
+
+// extractAssets returns the asset references of an HTML token.
+func extractAssets(token html.Token) []models.Link {
+	attrs, ok := assetSources[token.Data]
+	if !ok {
+		return nil
+	}
+	if token.Data == "link" && !hasRel(token, "stylesheet") {
+		return nil
+	}
+
+	links := []models.Link{}
+	for _, attr := range token.Attr {
+		for _, assetAttr := range attrs {
+			if attr.Key != assetAttr {
+				continue
+			}
+			hrefs := []string{attr.Val}
+			if attr.Key == "srcset" {
+				hrefs = srcsetURLs(attr.Val)
+			}
+			for _, href := range hrefs {
+				if href = strings.TrimSpace(href); href != "" && !strings.HasPrefix(href, "data:") {
+					links = append(links, models.Link{Href: href, Tag: token.Data, Attr: attr.Key})
+				}
+			}
+		}
+	}
+	return links
+}
+
+// srcsetURLs returns the image candidate URLs of a srcset attribute such as "a.png 1x, b.png 2x".
+func srcsetURLs(srcset string) []string {
+	urls := []string{}
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// cssURLs returns the url(...) and @import references of a stylesheet, skipping data URIs.
+func cssURLs(css string) []string {
+	urls := []string{}
+	for _, match := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		reference := strings.TrimSpace(match[1] + match[2])
+		if reference != "" && !strings.HasPrefix(reference, "data:") {
+			urls = append(urls, reference)
+		}
+	}
+	return urls
+}
+
+// hasRel reports whether the token rel attribute contains the value.
+func hasRel(token html.Token, value string) bool {
+	for _, attr := range token.Attr {
+		if attr.Key != "rel" {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attr.Val)) {
+			if rel == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStylesheet reports whether the content type is CSS.
+func isStylesheet(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/css"
+}
@@ -0,0 +1,435 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_crawler "github.com/csrar/crawler/pkg/crawler/mocks"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	"github.com/csrar/crawler/pkg/politeness"
+	"github.com/csrar/crawler/pkg/robots"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	"github.com/csrar/crawler/pkg/store"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/dsnet/golib/memfile"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestExtractAssets(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected []models.Link
+	}{
+		{
+			name:     "image src",
+			tag:      `<img src="/logo.png" alt="logo">`,
+			expected: []models.Link{{Href: "/logo.png", Tag: "img", Attr: "src"}},
+		},
+		{
+			name: "image src and srcset",
+			tag:  `<img src="/small.png" srcset="/small.png 1x, /large.png 2x">`,
+			expected: []models.Link{
+				{Href: "/small.png", Tag: "img", Attr: "src"},
+				{Href: "/small.png", Tag: "img", Attr: "srcset"},
+				{Href: "/large.png", Tag: "img", Attr: "srcset"},
+			},
+		},
+		{
+			name:     "script src",
+			tag:      `<script src="/app.js"></script>`,
+			expected: []models.Link{{Href: "/app.js", Tag: "script", Attr: "src"}},
+		},
+		{
+			name:     "inline script",
+			tag:      `<script>console.log("hi")</script>`,
+			expected: []models.Link{},
+		},
+		{
+			name:     "stylesheet",
+			tag:      `<link rel="Stylesheet" href="/style.css">`,
+			expected: []models.Link{{Href: "/style.css", Tag: "link", Attr: "href"}},
+		},
+		{
+			name:     "link that is not a stylesheet",
+			tag:      `<link rel="next" href="/page/2">`,
+			expected: nil,
+		},
+		{
+			name: "video src and poster",
+			tag:  `<video src="/clip.mp4" poster="/poster.jpg"></video>`,
+			expected: []models.Link{
+				{Href: "/clip.mp4", Tag: "video", Attr: "src"},
+				{Href: "/poster.jpg", Tag: "video", Attr: "poster"},
+			},
+		},
+		{
+			name:     "picture source srcset",
+			tag:      `<source srcset="/wide.webp 1200w" type="image/webp">`,
+			expected: []models.Link{{Href: "/wide.webp", Tag: "source", Attr: "srcset"}},
+		},
+		{
+			name:     "data uri is skipped",
+			tag:      `<img src="data:image/png;base64,iVBORw0KGgo=">`,
+			expected: []models.Link{},
+		},
+		{
+			name:     "anchor is not an asset",
+			tag:      `<a href="/about">About</a>`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenizer := html.NewTokenizer(strings.NewReader(tc.tag))
+			tokenizer.Next()
+			assert.Equal(t, tc.expected, extractAssets(tokenizer.Token()))
+		})
+	}
+}
+
+func TestSrcsetURLs(t *testing.T) {
+	tests := []struct {
+		srcset   string
+		expected []string
+	}{
+		{srcset: "/a.png", expected: []string{"/a.png"}},
+		{srcset: "/a.png 1x, /b.png 2x", expected: []string{"/a.png", "/b.png"}},
+		{srcset: " /a.png 480w ,\n/b.png 800w ", expected: []string{"/a.png", "/b.png"}},
+		{srcset: "", expected: []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.srcset, func(t *testing.T) {
+			assert.Equal(t, tc.expected, srcsetURLs(tc.srcset))
+		})
+	}
+}
+
+func TestCSSURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		css      string
+		expected []string
+	}{
+		{
+			name:     "unquoted url",
+			css:      `body { background: url(/bg.png) no-repeat; }`,
+			expected: []string{"/bg.png"},
+		},
+		{
+			name:     "quoted urls",
+			css:      `@font-face { src: url("fonts/a.woff2") format("woff2"), url( 'fonts/a.woff' ); }`,
+			expected: []string{"fonts/a.woff2", "fonts/a.woff"},
+		},
+		{
+			name:     "import",
+			css:      `@import "theme.css"; @import url(print.css) print;`,
+			expected: []string{"theme.css", "print.css"},
+		},
+		{
+			name:     "data uri is skipped",
+			css:      `.icon { background: url(data:image/svg+xml;base64,PHN2Zz4=); }`,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, cssURLs(tc.css))
+		})
+	}
+}
+
+// countingScheduler counts the slots acquired and released on a scheduler without delays.
+type countingScheduler struct {
+	politeness.IScheduler
+	acquired atomic.Int32
+	released atomic.Int32
+}
+
+func newCountingScheduler() *countingScheduler {
+	return &countingScheduler{IScheduler: politeness.NewScheduler(0, 100)}
+}
+
+func (s *countingScheduler) Acquire(ctx context.Context, host string) error {
+	s.acquired.Add(1)
+	return s.IScheduler.Acquire(ctx, host)
+}
+
+func (s *countingScheduler) Release(host string) {
+	s.released.Add(1)
+	s.IScheduler.Release(host)
+}
+
+func TestAssetCheck(t *testing.T) {
+	requests := atomic.Int32{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", "512")
+		case "/hero.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", "5000000")
+		case "/style.css":
+			body := `body { background: url(img/bg.png); } .logo { background: url("/logo.png"); }`
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, body)
+			}
+			return
+		case "/no-head.js":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/javascript")
+			w.Header().Set("Content-Length", "10")
+			fmt.Fprint(w, "alert(1);\n")
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request for %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name     string
+		asset    string
+		robots   string
+		blocked  int
+		expected []models.AssetResult
+	}{
+		{
+			name:  "existing asset",
+			asset: "/logo.png",
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/logo.png", StatusCode: 200, ContentType: "image/png", Size: 512},
+			},
+		},
+		{
+			name:  "broken asset",
+			asset: "/missing.png",
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/missing.png", StatusCode: 404, ContentType: "text/plain; charset=utf-8", Size: 19, Broken: true},
+			},
+		},
+		{
+			name:  "oversized asset",
+			asset: "/hero.jpg",
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/hero.jpg", StatusCode: 200, ContentType: "image/jpeg", Size: 5000000, Oversized: true},
+			},
+		},
+		{
+			name:  "asset without head support",
+			asset: "/no-head.js",
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/no-head.js", StatusCode: 200, ContentType: "text/javascript", Size: 10},
+			},
+		},
+		{
+			name:  "stylesheet references are checked once",
+			asset: "/style.css",
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/style.css", StatusCode: 200, ContentType: "text/css; charset=utf-8", Size: 77},
+				{URL: testServer.URL + "/img/bg.png", Parent: testServer.URL + "/style.css", Tag: "css", Attr: "url",
+					StatusCode: 404, ContentType: "text/plain; charset=utf-8", Size: 19, Broken: true},
+				{URL: testServer.URL + "/logo.png", Parent: testServer.URL + "/style.css", Tag: "css", Attr: "url",
+					StatusCode: 200, ContentType: "image/png", Size: 512},
+			},
+		},
+		{
+			name:    "stylesheet references disallowed by robots are skipped",
+			asset:   "/style.css",
+			robots:  "User-agent: *\nDisallow: /img/\n",
+			blocked: 1,
+			expected: []models.AssetResult{
+				{URL: testServer.URL + "/style.css", StatusCode: 200, ContentType: "text/css; charset=utf-8", Size: 77},
+				{URL: testServer.URL + "/logo.png", Parent: testServer.URL + "/style.css", Tag: "css", Attr: "url",
+					StatusCode: 200, ContentType: "image/png", Size: 512},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Warn(gomock.Any()).AnyTimes()
+
+			crawlerStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+			assert.Nil(t, crawlerStore.StoreData("", models.SiteStore{Sites: map[string]bool{}}))
+
+			requests.Store(0)
+			rules := robots.Parse(strings.NewReader(tc.robots), "crawler")
+			scheduler := newCountingScheduler()
+			root, _ := url.Parse(testServer.URL + "/")
+			checker := NewAssetChecker(logMock, crawlerStore, NewNormalizer(nil), NewFetcher(http.DefaultClient), root, rules, scheduler,
+				1024*1024, 1, 10)
+			asset, _ := url.Parse(testServer.URL + tc.asset)
+			link := models.Link{Href: tc.asset, Tag: "img", Attr: "src"}
+			checker.Check(context.Background(), asset, link, "https://parent.com/page")
+			// a second reference to the same asset is not requested again
+			checker.Check(context.Background(), asset, link, "https://parent.com/other")
+			assert.Nil(t, checker.Shutdown(context.Background()))
+
+			assets, err := crawlerStore.Assets()
+			assert.Nil(t, err)
+			tc.expected[0].Parent = "https://parent.com/page"
+			tc.expected[0].Tag = "img"
+			tc.expected[0].Attr = "src"
+			assert.Equal(t, tc.expected, assets)
+			assert.Equal(t, tc.blocked, rules.AssetsBlocked())
+			// every request to the crawled host held a slot of the scheduler
+			assert.Equal(t, requests.Load(), scheduler.acquired.Load())
+			assert.Equal(t, requests.Load(), scheduler.released.Load())
+		})
+	}
+}
+
+func TestAssetCheckerQueue(t *testing.T) {
+	tests := []struct {
+		name        string
+		interrupted bool
+		expected    int
+	}{
+		{name: "queued assets are checked on shutdown", expected: 2},
+		{name: "queued assets are dropped on an interrupted shutdown", interrupted: true, expected: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			release := make(chan struct{})
+			requests := make(chan string, 10)
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests <- r.URL.Path
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer testServer.Close()
+			defer close(release)
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Warn(gomock.Any()).AnyTimes()
+
+			crawlerStore := store.NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), 0)
+			assert.Nil(t, crawlerStore.StoreData("", models.SiteStore{Sites: map[string]bool{}}))
+
+			// a single worker with room for one more asset
+			root, _ := url.Parse(testServer.URL + "/")
+			checker := NewAssetChecker(logMock, crawlerStore, NewNormalizer(nil), NewFetcher(http.DefaultClient), root, robots.NewAllowAll(),
+				politeness.NewScheduler(0, 100), 0, 1, 1)
+			check := func(ctx context.Context, path string) {
+				asset, _ := url.Parse(testServer.URL + path)
+				checker.Check(ctx, asset, models.Link{Href: path, Tag: "img", Attr: "src"}, "https://parent.com/page")
+			}
+			// the page does not wait for the request of its first asset
+			check(context.Background(), "/first.png")
+			assert.Equal(t, "/first.png", <-requests)
+			check(context.Background(), "/second.png")
+			// the queue is full, the page waits for room until its context is done
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			check(ctx, "/third.png")
+			assert.NotNil(t, ctx.Err())
+
+			if tc.interrupted {
+				shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancelShutdown()
+				assert.Equal(t, context.DeadlineExceeded, checker.Shutdown(shutdownCtx))
+			} else {
+				go func() {
+					release <- struct{}{}
+					release <- struct{}{}
+				}()
+				assert.Nil(t, checker.Shutdown(context.Background()))
+			}
+			// assets found after the shutdown are dropped
+			check(context.Background(), "/fourth.png")
+
+			assets, err := crawlerStore.Assets()
+			assert.Nil(t, err)
+			assert.Len(t, assets, tc.expected)
+		})
+	}
+}
+
+func TestExtractLinksAssets(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><style>body { background: url(/bg.png); }</style></head><body><img src="/logo.png"><a href="/about">About</a></body></html>`)
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		queued   int
+	}{
+		{name: "assets and links", maxDepth: 0, queued: 1},
+		{name: "assets of a page at max depth", maxDepth: 1, queued: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(tc.queued)
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+			robotsMock.EXPECT().IsAssetAllowed(gomock.Any()).Return(true).Times(2)
+
+			page := testServer.URL + "/"
+			assetsMock := mock_crawler.NewMockIAssetChecker(ctrl)
+			gomock.InOrder(
+				assetsMock.EXPECT().Check(gomock.Any(), gomock.Any(), models.Link{Href: "/bg.png", Tag: "style", Attr: "url"}, page),
+				assetsMock.EXPECT().Check(gomock.Any(), gomock.Any(), models.Link{Href: "/logo.png", Tag: "img", Attr: "src"}, page),
+			)
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			item := models.CrawlItem{URL: page, Depth: 1}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, item, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), assetsMock, models.Config{MaxDepth: tc.maxDepth})
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, tc.queued, <-ch.Finished)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// charsetPreviewSize is the number of bytes of a page looked at to find its charset.
+const charsetPreviewSize = 1024
+
+// decodeBody returns the page body transcoded to UTF-8 and the name of its charset.
+func decodeBody(body *bufio.Reader, contentType string) (io.Reader, string) {
+	preview, _ := body.Peek(charsetPreviewSize)
+	enc, name := pageEncoding(preview, contentType)
+	if enc == encoding.Nop {
+		return body, name
+	}
+	return transform.NewReader(body, enc.NewDecoder()), name
+}
+
+// pageEncoding finds the charset of a page from its BOM, the Content-Type header or its meta tags,
+// in that order. Undeclared pages are read as UTF-8 unless their first bytes are not valid UTF-8,
+// in which case they are read as windows-1252 like browsers do.
+func pageEncoding(preview []byte, contentType string) (encoding.Encoding, string) {
+	enc, name, certain := charset.DetermineEncoding(preview, contentType)
+	if certain {
+		return enc, name
+	}
+	if label := metaCharset(preview); label != "" {
+		if enc, name := charset.Lookup(label); enc != nil {
+			// a page declaring UTF-16 in its meta tags can't be UTF-16, the tag would not be readable
+			if strings.HasPrefix(name, "utf-16") {
+				return encoding.Nop, "utf-8"
+			}
+			return enc, name
+		}
+	}
+	if !hasHighBit(preview) {
+		// nothing tells the charset yet, the tokenizer default is kept
+		return encoding.Nop, "utf-8"
+	}
+	return enc, name
+}
+
+// metaCharset returns the charset declared in the meta tags of the preview, either
+// <meta charset="..."> or <meta http-equiv="Content-Type" content="text/html; charset=...">.
+func metaCharset(preview []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(preview))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data != "meta" {
+				continue
+			}
+			httpEquiv, content := "", ""
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "charset":
+					return strings.TrimSpace(attr.Val)
+				case "http-equiv":
+					httpEquiv = attr.Val
+				case "content":
+					content = attr.Val
+				}
+			}
+			if strings.EqualFold(httpEquiv, "content-type") {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
+
+// hasHighBit reports whether the content has any non ASCII byte.
+func hasHighBit(content []byte) bool {
+	for _, b := range content {
+		if b >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// encode encodes an UTF-8 string in the charset.
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	assert.Nil(t, err)
+	return encoded
+}
+
+func TestPageEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		preview     string
+		contentType string
+		expected    string
+	}{
+		{
+			name:        "content type header",
+			preview:     "<html><body>caf\xe9</body></html>",
+			contentType: "text/html; charset=ISO-8859-1",
+			expected:    "windows-1252",
+		},
+		{
+			name:        "byte order mark over the header",
+			preview:     "\xef\xbb\xbf<html></html>",
+			contentType: "text/html; charset=Shift_JIS",
+			expected:    "utf-8",
+		},
+		{
+			name:        "header over the meta tags",
+			preview:     `<html><head><meta charset="euc-jp"></head></html>`,
+			contentType: "text/html; charset=shift_jis",
+			expected:    "shift_jis",
+		},
+		{
+			name:        "meta charset",
+			preview:     `<html><head><meta charset="Shift_JIS"></head></html>`,
+			contentType: "text/html",
+			expected:    "shift_jis",
+		},
+		{
+			name:        "meta http-equiv",
+			preview:     `<html><head><meta http-equiv="Content-Type" content="text/html; charset=iso-8859-2"></head></html>`,
+			contentType: "text/html",
+			expected:    "iso-8859-2",
+		},
+		{
+			name:        "meta utf-16",
+			preview:     `<html><head><meta charset="utf-16le"></head></html>`,
+			contentType: "text/html",
+			expected:    "utf-8",
+		},
+		{
+			name:        "undeclared ascii",
+			preview:     `<html><body><a href="/about">About</a></body></html>`,
+			contentType: "text/html",
+			expected:    "utf-8",
+		},
+		{
+			name:        "undeclared utf-8",
+			preview:     "<html><body>café</body></html>",
+			contentType: "text/html",
+			expected:    "utf-8",
+		},
+		{
+			name:        "undeclared legacy bytes",
+			preview:     "<html><body>caf\xe9</body></html>",
+			contentType: "text/html",
+			expected:    "windows-1252",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, name := pageEncoding([]byte(tc.preview), tc.contentType)
+			assert.Equal(t, tc.expected, name)
+		})
+	}
+}
+
+func TestExtractLinksCharsets(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            []byte
+		expectedCharset string
+		expectedQueue   []string
+	}{
+		{
+			name:            "shift_jis header",
+			contentType:     "text/html; charset=Shift_JIS",
+			body:            encode(t, japanese.ShiftJIS, `<html><body><a href="/ニュース/記事">記事</a></body></html>`),
+			expectedCharset: "shift_jis",
+			expectedQueue:   []string{"/%E3%83%8B%E3%83%A5%E3%83%BC%E3%82%B9/%E8%A8%98%E4%BA%8B"},
+		},
+		{
+			name:            "windows-1252 meta",
+			contentType:     "text/html",
+			body:            encode(t, charmap.Windows1252, `<html><head><meta charset="windows-1252"></head><body><a href="/café">Café</a></body></html>`),
+			expectedCharset: "windows-1252",
+			expectedQueue:   []string{"/caf%C3%A9"},
+		},
+		{
+			name:            "iso-8859-2 http-equiv",
+			contentType:     "text/html",
+			body:            encode(t, charmap.ISO8859_2, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=iso-8859-2"></head><body><a href="/łódź">Łódź</a></body></html>`),
+			expectedCharset: "iso-8859-2",
+			expectedQueue:   []string{"/%C5%82%C3%B3d%C5%BA"},
+		},
+		{
+			name:            "utf-8",
+			contentType:     "text/html; charset=utf-8",
+			body:            []byte(`<html><body><a href="/ニュース">ニュース</a></body></html>`),
+			expectedCharset: "utf-8",
+			expectedQueue:   []string{"/%E3%83%8B%E3%83%A5%E3%83%BC%E3%82%B9"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write(tc.body)
+			}))
+			defer testServer.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			var recorded models.PageResult
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).DoAndReturn(func(result models.PageResult) error {
+				recorded = result
+				return nil
+			})
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(len(tc.expectedQueue))
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL}, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock,
+				robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, len(tc.expectedQueue), <-ch.Finished)
+			for _, path := range tc.expectedQueue {
+				assert.Equal(t, testServer.URL+path, popLink(t, queue).URL)
+			}
+			assert.Equal(t, tc.expectedCharset, recorded.Charset)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// htmlContentTypes are the types parsed when none are configured.
+var htmlContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+// sniffSize is the number of bytes used to detect the content type of a response without one.
+const sniffSize = 512
+
+// isAllowedType reports whether the media type of a Content-Type header is one of the allowed ones.
+func isAllowedType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowedType := range allowed {
+		if strings.EqualFold(mediaType, allowedType) {
+			return true
+		}
+	}
+	return false
+}
+
+// capBody limits the bytes read from the body to maxBytes, 0 meaning unlimited.
+func capBody(body io.Reader, maxBytes int64) *io.LimitedReader {
+	if maxBytes <= 0 {
+		maxBytes = math.MaxInt64
+	}
+	return &io.LimitedReader{R: body, N: maxBytes}
+}
+
+// isTruncated reports whether the body has more bytes after the capped ones were read.
+func isTruncated(capped *io.LimitedReader) bool {
+	if capped.N > 0 {
+		return false
+	}
+	next := make([]byte, 1)
+	n, _ := capped.R.Read(next)
+	return n > 0
+}
+
+// contentType returns the Content-Type of the response, sniffed from the first bytes of the body
+// when the server does not send it.
+func contentType(header http.Header, body *bufio.Reader) string {
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		return contentType
+	}
+	sniff, _ := body.Peek(sniffSize)
+	return http.DetectContentType(sniff)
+}
+
+// probe sends a HEAD request for the page and reports whether its content type is not parsed,
+// in which case the page result is recorded and the page is not downloaded.
+func (c *Crawler) probe(ctx context.Context) bool {
+	start := time.Now()
+	response, err := c.fetcher.Fetch(ctx, http.MethodHead, c.page.String())
+	if err != nil {
+		// the GET request reports the error
+		return false
+	}
+	drain(response)
+	contentType := response.Header.Get("Content-Type")
+	if !isSuccess(response.StatusCode) || contentType == "" || isAllowedType(contentType, c.contentTypes) {
+		return false
+	}
+	c.recordPage(newPageResult(c.item, c.page, response, nil, start))
+	c.logger.Info(fmt.Sprintf("worker: %d - skipping %s page: %s", c.ID, contentType, c.page))
+	return true
+}
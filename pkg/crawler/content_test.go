@@ -0,0 +1,222 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAllowedType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{contentType: "text/html", expected: true},
+		{contentType: "text/html; charset=ISO-8859-1", expected: true},
+		{contentType: "TEXT/HTML", expected: true},
+		{contentType: "application/xhtml+xml", expected: true},
+		{contentType: "application/pdf", expected: false},
+		{contentType: "text/plain; charset=utf-8", expected: false},
+		{contentType: "html", expected: false},
+		{contentType: "", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.contentType, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isAllowedType(tc.contentType, htmlContentTypes))
+		})
+	}
+}
+
+// payloadServer serves HTML pages, a PDF and a large binary file, counting the requests and the
+// bytes written for each of them.
+type payloadServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+	written  map[string]*int64
+}
+
+func newPayloadServer(page string, binarySize int) *payloadServer {
+	server := &payloadServer{requests: map[string]int{}, written: map[string]*int64{}}
+	for _, path := range []string{"/page", "/page.xhtml", "/report.pdf", "/download", "/video"} {
+		server.written[path] = new(int64)
+	}
+	chunk := bytes.Repeat([]byte{0x00, 0xff, 0x1f, 0x8b}, 8*1024)
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.requests[r.Method+" "+r.URL.Path]++
+		server.mu.Unlock()
+		written := server.written[r.URL.Path]
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		case "/page.xhtml":
+			w.Header().Set("Content-Type", "application/xhtml+xml")
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "/video":
+			w.Header().Set("Content-Type", "video/mp4")
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		if r.URL.Path == "/page" || r.URL.Path == "/page.xhtml" {
+			n, _ := fmt.Fprint(w, page)
+			atomic.AddInt64(written, int64(n))
+			return
+		}
+		// binary payloads, /download without a content type
+		for sent := 0; sent < binarySize; sent += len(chunk) {
+			n, err := w.Write(chunk)
+			atomic.AddInt64(written, int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}))
+	return server
+}
+
+func (p *payloadServer) count(method string, path string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[method+" "+path]
+}
+
+func TestExtractLinksContentTypes(t *testing.T) {
+	page := `<html><body><a href="/about">About</a></body></html>`
+	binarySize := 64 * 1024 * 1024
+
+	tests := []struct {
+		name                string
+		path                string
+		headProbe           bool
+		expectedQueue       int
+		expectedGet         int
+		expectedContentType string
+	}{
+		{name: "html page", path: "/page", expectedQueue: 1, expectedGet: 1, expectedContentType: "text/html; charset=utf-8"},
+		{name: "xhtml page", path: "/page.xhtml", expectedQueue: 1, expectedGet: 1, expectedContentType: "application/xhtml+xml"},
+		{name: "pdf", path: "/report.pdf", expectedGet: 1, expectedContentType: "application/pdf"},
+		{name: "binary without content type", path: "/download", expectedGet: 1},
+		{name: "video", path: "/video", expectedGet: 1, expectedContentType: "video/mp4"},
+		{name: "probed html page", path: "/page", headProbe: true, expectedQueue: 1, expectedGet: 1, expectedContentType: "text/html; charset=utf-8"},
+		{name: "probed pdf", path: "/report.pdf", headProbe: true, expectedGet: 0, expectedContentType: "application/pdf"},
+		// the content type is not known until the body is sniffed
+		{name: "probed binary without content type", path: "/download", headProbe: true, expectedGet: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := newPayloadServer(page, binarySize)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			var recorded models.PageResult
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).DoAndReturn(func(result models.PageResult) error {
+				recorded = result
+				return nil
+			})
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(tc.expectedQueue)
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			cfg := models.Config{ContentTypes: htmlContentTypes, HeadProbe: tc.headProbe}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL + tc.path}, ch, queue, logMock, NewFetcher(http.DefaultClient),
+				storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, cfg)
+			crawler.SpinUpCrawler(context.Background())
+			assert.Equal(t, tc.expectedQueue, <-ch.Finished)
+			// closing waits for the handlers, which stop writing once the client closes the connection
+			testServer.Close()
+
+			assert.Equal(t, tc.expectedGet, testServer.count(http.MethodGet, tc.path))
+			assert.Less(t, atomic.LoadInt64(testServer.written[tc.path]), int64(binarySize))
+			if tc.expectedContentType != "" {
+				assert.Equal(t, tc.expectedContentType, recorded.ContentType)
+			}
+			assert.False(t, recorded.Truncated)
+		})
+	}
+}
+
+func TestExtractLinksMaxBodyBytes(t *testing.T) {
+	head := `<html><body><a href="/first">First</a>`
+	tail := `<a href="/last">Last</a></body></html>`
+	page := head + strings.Repeat("<p>filler</p>", 1024*1024) + tail
+
+	tests := []struct {
+		name              string
+		maxBodyBytes      int64
+		expectedQueue     []string
+		expectedTruncated bool
+	}{
+		{name: "unlimited", maxBodyBytes: 0, expectedQueue: []string{"/first", "/last"}},
+		{name: "body bigger than the limit", maxBodyBytes: 1024, expectedQueue: []string{"/first"}, expectedTruncated: true},
+		{name: "body as big as the limit", maxBodyBytes: int64(len(page)), expectedQueue: []string{"/first", "/last"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := newPayloadServer(page, 0)
+			defer testServer.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			var recorded models.PageResult
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).DoAndReturn(func(result models.PageResult) error {
+				recorded = result
+				return nil
+			})
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(len(tc.expectedQueue))
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			cfg := models.Config{MaxBodyBytes: tc.maxBodyBytes}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL + "/page"}, ch, queue, logMock, NewFetcher(http.DefaultClient),
+				storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, cfg)
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, len(tc.expectedQueue), <-ch.Finished)
+			for _, path := range tc.expectedQueue {
+				assert.Equal(t, testServer.URL+path, popLink(t, queue).URL)
+			}
+			assert.Equal(t, tc.expectedTruncated, recorded.Truncated)
+		})
+	}
+}
@@ -1,60 +1,242 @@
 package crawler
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/frontier"
 	"github.com/csrar/crawler/pkg/logger"
+	"github.com/csrar/crawler/pkg/robots"
 	"github.com/csrar/crawler/pkg/store"
 	"golang.org/x/net/html"
 )
 
 type Crawler struct {
-	ID       int
-	page     *url.URL
-	queue    chan string
-	logger   logger.Ilogger
-	workers  chan int
-	finished chan int
-	store    store.ICrawlerStore
+	ID   int
+	item models.CrawlItem
+	page *url.URL
+	base *url.URL
+	// host is the canonical host of the page, the links to other hosts are not followed
+	host     string
+	depth    int
+	maxDepth int
+	queue    frontier.IFrontier
+	// budget limits the links queued when the frontier has one
+	budget     frontier.IBudget
+	logger     logger.Ilogger
+	fetcher    IFetcher
+	workers    chan int
+	finished   chan int
+	store      store.ICrawlerStore
+	robots     robots.IRobots
+	normalizer INormalizer
+	extractor  IExtractor
+	assets     IAssetChecker
+	// links keeps the links between the pages when the store records them
+	links store.ILinkRecorder
+	// honorDirectives follows the nofollow and noindex directives of the pages for userAgent
+	honorDirectives bool
+	userAgent       string
+	// contentTypes are the types parsed for links, up to maxBodyBytes of their body
+	contentTypes []string
+	headProbe    bool
+	maxBodyBytes int64
+}
+
+// FetchError is the error of a page that could not be crawled, Page is the record kept in the
+// failed pages of the store.
+type FetchError struct {
+	Page models.FailedPage
+	Err  error
+}
+
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
 }
 
 //go:generate mockgen -source=crawler.go -destination=mocks/crawler_mock.go
 type ICrawler interface {
-	ExtractLinks() error
-	SpinUpCrawler()
+	ExtractLinks(ctx context.Context) error
+	SpinUpCrawler(ctx context.Context)
 }
 
-func NewCrawler(ID int, webPage string, channels *models.CommunitationChans, log logger.Ilogger, store store.ICrawlerStore) (ICrawler, error) {
-	linkURL, err := url.Parse(webPage)
+func NewCrawler(ID int, item models.CrawlItem, channels *models.CommunitationChans, queue frontier.IFrontier, log logger.Ilogger,
+	fetcher IFetcher, store store.ICrawlerStore, robots robots.IRobots, normalizer INormalizer, extractor IExtractor,
+	assets IAssetChecker, cfg models.Config) (ICrawler, error) {
+	linkURL, err := url.Parse(item.URL)
 
 	if err != nil {
 		return nil, fmt.Errorf("error parsing the provided URL: %v", err)
 	}
+	// the page is fetched as linked, its canonical form is only the key it is deduplicated by
+	page := withoutFragment(linkURL)
+	contentTypes := cfg.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = htmlContentTypes
+	}
 	return &Crawler{
-		ID:       ID,
-		page:     linkURL,
-		queue:    channels.Queue,
-		logger:   log,
-		workers:  channels.Workers,
-		finished: channels.Finished,
-		store:    store,
+		ID:         ID,
+		item:       item,
+		page:       page,
+		base:       page,
+		host:       normalizer.Normalize(linkURL).Host,
+		depth:      item.Depth,
+		maxDepth:   cfg.MaxDepth,
+		queue:      queue,
+		budget:     linkBudget(queue),
+		logger:     log,
+		fetcher:    fetcher,
+		workers:    channels.Workers,
+		finished:   channels.Finished,
+		store:      store,
+		robots:     robots,
+		normalizer: normalizer,
+		extractor:  extractor,
+		assets:     assets,
+		links:      linkRecorder(store),
+
+		honorDirectives: cfg.HonorDirectives,
+		userAgent:       cfg.UserAgent,
+		contentTypes:    contentTypes,
+		headProbe:       cfg.HeadProbe,
+		maxBodyBytes:    cfg.MaxBodyBytes,
 	}, nil
 }
 
 // ExtractLinks extracts links from a web page.
-func (c *Crawler) ExtractLinks() error {
-	defer c.returnWorker()
+func (c *Crawler) ExtractLinks(ctx context.Context) error {
+	queued, err := c.extractLinks(ctx)
+	c.returnWorker(queued)
+	return err
+}
 
-	pageBody, err := http.Get(c.page.String())
+// extractLinks fetches the page and queues its links, returning how many were queued.
+func (c *Crawler) extractLinks(ctx context.Context) (int, error) {
+
+	if c.headProbe && c.probe(ctx) {
+		return 0, nil
+	}
+	start := time.Now()
+	pageBody, err := c.fetcher.Fetch(ctx, http.MethodGet, c.page.String())
+	result := newPageResult(c.item, c.page, pageBody, err, start)
 	if err != nil {
-		return fmt.Errorf("worker: %d - error visiting page: %s", c.ID, err)
+		c.recordPage(result)
+		if !errors.Is(err, context.Canceled) {
+			err = &FetchError{Page: c.recordFailure(0, err.Error()), Err: err}
+		}
+		return 0, fmt.Errorf("worker: %d - error visiting page: %w", c.ID, err)
+	}
+	body := &countingReader{reader: pageBody.Body}
+	// the rest of the body is drained so the connection can be reused, unless it is not wanted
+	discard := true
+	defer func() {
+		if discard {
+			_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainSize))
+		}
+		pageBody.Body.Close()
+		// without a Content-Length the size is known when the whole body was read
+		if result.ContentLength < 0 && body.eof {
+			result.ContentLength = body.count
+		}
+		c.recordPage(result)
+	}()
+	if isRetryable(pageBody, nil) {
+		// the server kept failing after the retries
+		failure := &FetchError{Page: c.recordFailure(pageBody.StatusCode, pageBody.Status), Err: errors.New(pageBody.Status)}
+		return 0, fmt.Errorf("worker: %d - error visiting page %s: %w", c.ID, c.page, failure)
 	}
 	c.logger.Info(fmt.Sprintf("worker: %d - visiting page: %s", c.ID, c.page))
-	tokenizer := html.NewTokenizer(pageBody.Body)
+	if !isSuccess(pageBody.StatusCode) {
+		// error pages and redirects that were not followed are recorded but not parsed
+		c.logger.Info(fmt.Sprintf("worker: %d - page answered %s: %s", c.ID, pageBody.Status, c.page))
+		return 0, nil
+	}
+	if result.OffHostRedirect {
+		c.logger.Info(fmt.Sprintf("worker: %d - page redirected to another host: %s -> %s", c.ID, c.page, result.FinalURL))
+		return 0, nil
+	}
+	capped := capBody(body, c.maxBodyBytes)
+	page := bufio.NewReaderSize(capped, charsetPreviewSize)
+	contentType := contentType(pageBody.Header, page)
+	if !isAllowedType(contentType, c.contentTypes) {
+		// the body of other types is not downloaded
+		discard = false
+		c.logger.Info(fmt.Sprintf("worker: %d - skipping %s page: %s", c.ID, contentType, c.page))
+		return 0, nil
+	}
+	decoded, charset := decodeBody(page, contentType)
+	result.Charset = charset
+	// relative links are resolved against the URL the page was served from, after redirects
+	if pageBody.Request != nil && pageBody.Request.URL != nil {
+		c.base = pageBody.Request.URL
+	}
+	pageDirectives := directives{}
+	if c.honorDirectives {
+		pageDirectives.mergeHeader(pageBody.Header, c.userAgent)
+	}
+	// the links of a page at the maximum depth or found once the page budget is spent are not
+	// followed, its assets and directives are still checked
+	followLinks := (c.maxDepth == 0 || c.depth < c.maxDepth) && !c.budgetSpent()
+	if !followLinks && c.assets == nil && !c.honorDirectives {
+		return 0, nil
+	}
+
+	doc := document{}
+	items, err := c.tokenize(ctx, decoded, &pageDirectives, &doc, followLinks)
+	result.Title = doc.title
+	if isTruncated(capped) {
+		discard = false
+		result.Truncated = true
+		c.logger.Info(fmt.Sprintf("worker: %d - page truncated to %d bytes: %s", c.ID, c.maxBodyBytes, c.page))
+	}
+	if pageDirectives.noindex {
+		c.logger.Info(fmt.Sprintf("worker: %d - noindex page: %s", c.ID, c.page))
+		if err := c.store.RecordNoindex(c.page.String()); err != nil {
+			c.logger.Error(err)
+		}
+	}
+	if pageDirectives.nofollow {
+		c.logger.Info(fmt.Sprintf("worker: %d - nofollow page, links are not followed: %s", c.ID, c.page))
+		return 0, err
+	}
+	c.recordLinks(doc.links)
+	queued, queueErr := c.queueLinks(items)
+	if err != nil {
+		return queued, err
+	}
+	return queued, queueErr
+}
+
+// document is what is collected from a page besides its links to crawl: its title and, when the
+// store records them, its links along with their anchor text.
+type document struct {
+	title string
+	links []models.LinkResult
+}
+
+// tokenize reads the HTML document collecting the links of its tags, the robots directives of
+// its meta tags and its title, and checks its assets.
+func (c *Crawler) tokenize(ctx context.Context, body io.Reader, pageDirectives *directives, doc *document, followLinks bool) ([]models.CrawlItem, error) {
+	items := []models.CrawlItem{}
+	tokenizer := html.NewTokenizer(body)
+	baseFound := false
+	inStyle := false
+	// the text of the title and of the <a> tag being read, anchor is the first of its links
+	titleFound, inTitle := false, false
+	var title, text strings.Builder
+	anchor := -1
 
 	for {
 		tokenType := tokenizer.Next()
@@ -63,80 +245,239 @@ func (c *Crawler) ExtractLinks() error {
 			err := tokenizer.Err()
 			if err == io.EOF {
 				//end of the file, finish method
-				return nil
+				return items, nil
 			}
-			return fmt.Errorf("worker: %d error tokenizing HTML: %v", c.ID, tokenizer.Err())
+			return items, fmt.Errorf("worker: %d error tokenizing HTML: %v", c.ID, tokenizer.Err())
 		case html.StartTagToken, html.SelfClosingTagToken:
 			token := tokenizer.Token()
-			link, err := c.extractTagLink(token)
-			if err != nil {
-				return err
+			if token.Data == "base" && !baseFound {
+				baseFound = c.setBase(token)
+				continue
 			}
-			if link != nil {
-				c.queue <- *link
+			if token.Data == "meta" && c.honorDirectives {
+				pageDirectives.mergeMeta(token, c.userAgent)
 			}
+			if c.assets != nil {
+				inStyle = token.Data == "style" && tokenType == html.StartTagToken
+				c.checkAssets(ctx, extractAssets(token))
+			}
+			if token.Data == "title" && !titleFound && tokenType == html.StartTagToken {
+				inTitle = true
+			}
+			if followLinks && !pageDirectives.nofollow {
+				tagItems := c.extractTagLinks(token)
+				items = append(items, tagItems...)
+				if c.links != nil {
+					if token.Data == "a" && tokenType == html.StartTagToken && len(tagItems) > 0 {
+						anchor = len(doc.links)
+						text.Reset()
+					}
+					for _, item := range tagItems {
+						doc.links = append(doc.links, models.LinkResult{From: item.Parent, To: item.URL, Tag: item.Tag, Attr: item.Attr})
+					}
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+			if anchor >= 0 {
+				text.Write(tokenizer.Text())
+			}
+			if inStyle {
+				links := []models.Link{}
+				for _, href := range cssURLs(string(tokenizer.Text())) {
+					links = append(links, models.Link{Href: href, Tag: "style", Attr: "url"})
+				}
+				c.checkAssets(ctx, links)
+			}
+		case html.EndTagToken:
+			inStyle = false
+			tag, _ := tokenizer.TagName()
+			if inTitle && string(tag) == "title" {
+				inTitle, titleFound = false, true
+				doc.title = collapseSpaces(title.String())
+			}
+			if anchor >= 0 && string(tag) == "a" {
+				for i := anchor; i < len(doc.links); i++ {
+					doc.links[i].Text = collapseSpaces(text.String())
+				}
+				anchor = -1
+			}
+		}
+	}
+}
+
+// checkAssets queues the assets referenced by the page to be checked, skipping the ones of the
+// crawled host that robots.txt does not allow.
+func (c *Crawler) checkAssets(ctx context.Context, links []models.Link) {
+	for _, link := range links {
+		assetURL, err := c.parseURL(link.Href)
+		if err != nil {
+			c.logger.Error(fmt.Errorf("error worker: %d - found invalid asset:%s", c.ID, link.Href))
+			continue
 		}
+		if c.normalizer.Normalize(assetURL).Host == c.host && !c.robots.IsAssetAllowed(assetURL) {
+			continue
+		}
+		c.assets.Check(ctx, assetURL, link, c.page.String())
+	}
+}
+
+// recordPage keeps the result of the page fetch in the store.
+func (c *Crawler) recordPage(result models.PageResult) {
+	if err := c.store.RecordPage(result); err != nil {
+		c.logger.Error(err)
+	}
+}
+
+// linkBudget returns the budget of the frontier when it limits the links queued, nil otherwise.
+func linkBudget(queue frontier.IFrontier) frontier.IBudget {
+	budget, _ := queue.(frontier.IBudget)
+	return budget
+}
+
+// budgetSpent reports whether no more links fit in the budget of the frontier.
+func (c *Crawler) budgetSpent() bool {
+	return c.budget != nil && c.budget.Spent()
+}
+
+// linkRecorder returns the store when it records the links between the pages, nil otherwise.
+func linkRecorder(crawlerStore store.ICrawlerStore) store.ILinkRecorder {
+	links, _ := crawlerStore.(store.ILinkRecorder)
+	return links
+}
+
+// recordLinks keeps the links of the page when the store records them.
+func (c *Crawler) recordLinks(links []models.LinkResult) {
+	if c.links == nil || len(links) == 0 {
+		return
+	}
+	if err := c.links.RecordLinks(links); err != nil {
+		c.logger.Error(err)
+	}
+}
+
+// recordFailure keeps the page in the store failed pages, returning its record.
+func (c *Crawler) recordFailure(statusCode int, reason string) models.FailedPage {
+	page := models.FailedPage{Item: c.item, StatusCode: statusCode, Error: reason}
+	if err := c.store.RecordFailure(page); err != nil {
+		c.logger.Error(err)
 	}
+	return page
 }
 
-// returnWorker signals that a worker has finished.
-func (c Crawler) returnWorker() {
+// returnWorker signals that a worker has finished along with the number of links it queued.
+func (c Crawler) returnWorker(queued int) {
 	c.workers <- c.ID
-	c.finished <- 1
+	c.finished <- queued
 }
 
 // SpinUpCrawler initiates the crawling process.
-func (c *Crawler) SpinUpCrawler() {
-	err := c.ExtractLinks()
-	if err != nil {
+func (c *Crawler) SpinUpCrawler(ctx context.Context) {
+	queued, err := c.extractLinks(ctx)
+	// a cancelled context means the crawl is shutting down, not that the page failed
+	if err != nil && !errors.Is(err, context.Canceled) {
 		c.logger.Error(err)
 	}
+	// the worker is returned once the error is logged, the crawl may be over once it is
+	c.returnWorker(queued)
 }
 
-// extractTagLink extracts links from HTML tokens.
-func (c *Crawler) extractTagLink(token html.Token) (*string, error) {
-	var link *string
-	if token.Data == "a" {
-		for _, attr := range token.Attr {
-			if attr.Key == "href" {
-				if linkURL, err := c.parseURL(attr.Val); err == nil {
-					if c.checkURL(linkURL) {
-						visited, err := c.store.WasAlreadyVisited(linkURL.String())
-						if err != nil {
-							return nil, err
-						}
-						if !visited {
-							c.logger.Info(fmt.Sprintf("worker: %d - Found link: %s", c.ID, linkURL.String()))
-							tmp := linkURL.String()
-							link = &tmp
-							break
-						}
-					}
+// extractTagLinks extracts the same host links of an HTML token.
+func (c *Crawler) extractTagLinks(token html.Token) []models.CrawlItem {
+	items := []models.CrawlItem{}
+	for _, link := range c.extractor.Extract(token) {
+		if c.honorDirectives && hasRel(token, "nofollow") {
+			c.logger.Info(fmt.Sprintf("worker: %d - Skipped nofollow link: %s", c.ID, link.Href))
+			continue
+		}
+		linkURL, err := c.parseURL(link.Href)
+		if err != nil {
+			c.logger.Error(fmt.Errorf("error worker: %d - found invalid link:%s", c.ID, link.Href))
+			continue
+		}
+		if !c.checkURL(c.normalizer.Normalize(linkURL)) {
+			continue
+		}
+		items = append(items, models.CrawlItem{
+			URL:    withoutFragment(linkURL).String(),
+			Depth:  c.depth + 1,
+			Parent: c.page.String(),
+			Tag:    link.Tag,
+			Attr:   link.Attr,
+		})
+	}
+	return items
+}
 
-				} else {
-					c.logger.Error(fmt.Errorf("error worker: %d - found invalid link:%s", c.ID, attr.Val))
-				}
-			}
+// queueLinks queues the links that were not visited yet and are allowed by robots.txt,
+// returning how many were queued.
+func (c *Crawler) queueLinks(items []models.CrawlItem) (int, error) {
+	queued := 0
+	for _, item := range items {
+		linkURL, err := url.Parse(item.URL)
+		if err != nil {
+			continue
+		}
+		visited, err := c.store.WasAlreadyVisited(c.normalizer.Key(linkURL))
+		if err != nil {
+			return queued, err
+		}
+		if visited {
+			continue
+		}
+		if !c.robots.IsAllowed(linkURL) {
+			c.logger.Info(fmt.Sprintf("worker: %d - Blocked by robots.txt: %s", c.ID, item.URL))
+			continue
 		}
+		if c.budget != nil && !c.budget.Reserve() {
+			// the links left do not fit in the budget, they are not queued
+			c.logger.Info(fmt.Sprintf("worker: %d - page budget spent, links are not queued: %s", c.ID, c.page))
+			break
+		}
+		c.logger.Info(fmt.Sprintf("worker: %d - Found link: %s", c.ID, item.URL))
+		if err := c.queue.Push(item); err != nil {
+			return queued, err
+		}
+		queued++
 	}
-	return link, nil
+	return queued, nil
 }
 
-// parseURL parses a string URL into a *url.URL object.
+// setBase uses the href of a <base> tag as the base URL of the document, only the first
+// <base> with an href applies.
+func (c *Crawler) setBase(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key != "href" {
+			continue
+		}
+		href, err := url.Parse(strings.TrimSpace(attr.Val))
+		if err != nil {
+			c.logger.Error(fmt.Errorf("error worker: %d - found invalid base href:%s", c.ID, attr.Val))
+			return false
+		}
+		c.base = c.base.ResolveReference(href)
+		return true
+	}
+	return false
+}
+
+// parseURL parses a string URL and resolves it against the document base URL as defined in RFC 3986.
 func (c *Crawler) parseURL(strUrl string) (*url.URL, error) {
-	url, err := url.Parse(strUrl)
+	reference, err := url.Parse(strings.TrimSpace(strUrl))
 	if err != nil {
 		return nil, err
 	}
-	if url.Host == "" && url.Scheme == "" {
-		url.Host = c.page.Host
-		url.Scheme = c.page.Scheme
-	}
+	return c.base.ResolveReference(reference), nil
+}
 
-	return url, nil
+// collapseSpaces trims the text and replaces its runs of white space with a single space.
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
 }
 
-// checkURL checks if a URL is valid for crawling.
+// checkURL checks if the canonical form of a URL is valid for crawling.
 func (c *Crawler) checkURL(url *url.URL) bool {
 	if !url.IsAbs() {
 		return false
@@ -144,9 +485,18 @@ func (c *Crawler) checkURL(url *url.URL) bool {
 	if url.Path == "/" || url.Path == "" {
 		return false
 	}
-	if url.Host != c.page.Host {
+	if url.Host != c.host {
 		return false
 	}
 
 	return true
 }
+
+// withoutFragment returns the link without its fragment, which is not sent to the server, the
+// rest of it is requested as is.
+func withoutFragment(link *url.URL) *url.URL {
+	request := *link
+	request.Fragment = ""
+	request.RawFragment = ""
+	return &request
+}
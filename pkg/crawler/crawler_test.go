@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -12,12 +13,29 @@ import (
 	"testing"
 
 	"github.com/csrar/crawler/internal/models"
+	"github.com/csrar/crawler/pkg/frontier"
 	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
 	mock_store "github.com/csrar/crawler/pkg/store/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestQueue creates the frontier the links of a test crawler are queued to.
+func newTestQueue(t testing.TB) frontier.IFrontier {
+	queue, err := frontier.NewFrontier(t.TempDir(), 10)
+	assert.Nil(t, err)
+	return queue
+}
+
+// popLink returns the next link queued by a test crawler.
+func popLink(t *testing.T, queue frontier.IFrontier) models.CrawlItem {
+	item, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.True(t, ok, "no link queued")
+	return item
+}
+
 func TestCheckURL(t *testing.T) {
 	// Define test cases as a table.
 	tests := []struct {
@@ -56,9 +74,7 @@ func TestCheckURL(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create a Crawler instance with the specified page host.
 			c := &Crawler{
-				page: &url.URL{
-					Host: tc.pageHost,
-				},
+				host: tc.pageHost,
 			}
 
 			// Call the checkURL method and compare the result.
@@ -73,64 +89,86 @@ func TestParseURL(t *testing.T) {
 	tests := []struct {
 		name             string
 		strURL           string
-		pageHost         string
-		pageScheme       string
-		expectedParseURL *url.URL
+		base             string
+		expectedParseURL string
 		expectedParseErr error
 	}{
 		{
 			name:             "TestParseURL_ValidURL",
 			strURL:           "https://example.com/path",
-			pageHost:         "",
-			pageScheme:       "",
-			expectedParseURL: &url.URL{Scheme: "https", Host: "example.com", Path: "/path"},
-			expectedParseErr: nil,
+			base:             "https://other.com/blog/post/",
+			expectedParseURL: "https://example.com/path",
 		},
 		{
 			name:             "TestParseURL_InvalidURL",
 			strURL:           ":invalid",
-			pageHost:         "",
-			pageScheme:       "",
-			expectedParseURL: nil,
+			base:             "https://example.com/blog/post/",
 			expectedParseErr: errors.New(`parse ":invalid": missing protocol scheme`),
 		},
 		{
 			name:             "TestParseURL_RelativeURL",
 			strURL:           "/relative/path",
-			pageHost:         "example.com",
-			pageScheme:       "https",
-			expectedParseURL: &url.URL{Scheme: "https", Host: "example.com", Path: "/relative/path"},
-			expectedParseErr: nil,
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://example.com/relative/path",
 		},
 		{
 			name:             "TestParseURL_EmptyURL",
 			strURL:           "",
-			pageHost:         "example.com",
-			pageScheme:       "https",
-			expectedParseURL: &url.URL{Scheme: "https", Host: "example.com"},
-			expectedParseErr: nil,
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://example.com/blog/post/",
+		},
+		{
+			name:             "TestParseURL_ParentDirectory",
+			strURL:           "../about",
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://example.com/blog/about",
+		},
+		{
+			name:             "TestParseURL_SameDirectory",
+			strURL:           "page2.html",
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://example.com/blog/post/page2.html",
+		},
+		{
+			name:             "TestParseURL_SameDirectoryOfFile",
+			strURL:           "page2.html",
+			base:             "https://example.com/blog/post",
+			expectedParseURL: "https://example.com/blog/page2.html",
+		},
+		{
+			name:             "TestParseURL_SchemeRelative",
+			strURL:           "//cdn.example.com/app.js",
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://cdn.example.com/app.js",
+		},
+		{
+			name:             "TestParseURL_QueryOnly",
+			strURL:           " ?page=2 ",
+			base:             "https://example.com/blog/post/",
+			expectedParseURL: "https://example.com/blog/post/?page=2",
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a Crawler instance with the specified page host and scheme.
+			// Create a Crawler instance with the specified document base.
+			base, _ := url.Parse(tc.base)
 			c := &Crawler{
-				page: &url.URL{
-					Host:   tc.pageHost,
-					Scheme: tc.pageScheme,
-				},
+				page: base,
+				base: base,
 			}
 
 			// Call the parseURL method and check the result.
 			parsedURL, parseErr := c.parseURL(tc.strURL)
 
 			// Use the testify/assert library for assertions.
-			if parseErr != nil {
+			if tc.expectedParseErr != nil {
 				assert.Equal(t, tc.expectedParseErr.Error(), parseErr.Error())
+				assert.Nil(t, parsedURL)
+				return
 			}
-			assert.Equal(t, tc.expectedParseURL, parsedURL)
-
+			assert.Nil(t, parseErr)
+			assert.Equal(t, tc.expectedParseURL, parsedURL.String())
 		})
 	}
 }
@@ -140,6 +178,7 @@ func TestReturnWorker(t *testing.T) {
 	tests := []struct {
 		name             string
 		crawler          Crawler
+		queued           int
 		expectedWorkerID int
 	}{
 		{
@@ -149,6 +188,7 @@ func TestReturnWorker(t *testing.T) {
 				workers:  make(chan int, 1),
 				finished: make(chan int, 1),
 			},
+			queued:           3,
 			expectedWorkerID: 1,
 		},
 		{
@@ -165,14 +205,14 @@ func TestReturnWorker(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Call the returnWorker method.
-			go tc.crawler.returnWorker()
+			go tc.crawler.returnWorker(tc.queued)
 
 			// Check if the worker ID is received as expected.
 			workerID := <-tc.crawler.workers
 			assert.Equal(t, tc.expectedWorkerID, workerID)
 
-			// Check if the finished channel receives a value.
-			<-tc.crawler.finished
+			// Check if the finished channel receives the queued links.
+			assert.Equal(t, tc.queued, <-tc.crawler.finished)
 		})
 	}
 }
@@ -195,6 +235,10 @@ func TestExtractLinks(t *testing.T) {
 		mockStoreWasAlreadyVisitedCalls  int
 		mockStoreWasAlreadyVisitedResult bool
 		mockStoreWasAlreadyVisitedError  error
+		robotsBlockedPaths               []string
+		cancelled                        bool
+		depth                            int
+		maxDepth                         int
 		prefixURL                        string
 		ch                               *models.CommunitationChans
 		expectedCH                       extractLinksCh
@@ -207,13 +251,12 @@ func TestExtractLinks(t *testing.T) {
 			mockStoreWasAlreadyVisitedCalls:  5,
 			mockStoreWasAlreadyVisitedResult: false,
 			ch: &models.CommunitationChans{
-				Queue:    make(chan string, 5),
 				Workers:  make(chan int, 1),
 				Finished: make(chan int, 1),
 			},
 			expectedCH: extractLinksCh{
 				workers:  []int{1},
-				finished: []int{1},
+				finished: []int{5},
 				queue:    []string{"%host%/about", "%host%/contact", "%host%/test", "%host%/demo", "%host%/foo"},
 			},
 		},
@@ -227,13 +270,12 @@ func TestExtractLinks(t *testing.T) {
 			mockStoreWasAlreadyVisitedResult: false,
 			mockStoreWasAlreadyVisitedError:  errors.New("mock-error"),
 			ch: &models.CommunitationChans{
-				Queue:    make(chan string, 5),
 				Workers:  make(chan int, 1),
 				Finished: make(chan int, 1),
 			},
 			expectedCH: extractLinksCh{
 				workers:  []int{1},
-				finished: []int{1},
+				finished: []int{0},
 				queue:    []string{},
 			},
 		},
@@ -246,16 +288,97 @@ func TestExtractLinks(t *testing.T) {
 			mockStoreWasAlreadyVisitedCalls:  4,
 			mockStoreWasAlreadyVisitedResult: false,
 			ch: &models.CommunitationChans{
-				Queue:    make(chan string, 5),
 				Workers:  make(chan int, 1),
 				Finished: make(chan int, 1),
 			},
 			expectedCH: extractLinksCh{
 				workers:  []int{1},
-				finished: []int{1},
+				finished: []int{4},
 				queue:    []string{"%host%/about", "%host%/test", "%host%/demo", "%host%/foo"},
 			},
 		},
+		{
+			name:                             "successful with links blocked by robots",
+			mockHttpResponse:                 `<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"><meta name="viewport" content="width=device-width,initial-scale=1.0"><title>Example HTML Page</title></head><body><header><h1>mock Website</h1><nav><ul><li><a href="%host%">Home</a></li><li><a href="%host%/about">About Us</a></li><li><a href="%host%/contact">Contact</a></li></ul></nav></header><section><h2>About Us</h2><p>Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nulla eget risus eu purus efficitur ullamcorper.</p></section><section><h2>Our Services</h2><ul><li><a href="%host%/test">Service 1</a></li><li><a href="%host%/demo">Service 2</a></li><li><a href="%host%/foo">Service 3</a></li></ul></section><footer><p>&copy; 2023 My mock website. All rights reserved.</p></footer></body></html>`,
+			expectedError:                    nil,
+			mockLogInfoCalls:                 6,
+			mockStoreWasAlreadyVisitedCalls:  5,
+			mockStoreWasAlreadyVisitedResult: false,
+			robotsBlockedPaths:               []string{"/contact", "/demo"},
+			ch: &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			},
+			expectedCH: extractLinksCh{
+				workers:  []int{1},
+				finished: []int{3},
+				queue:    []string{"%host%/about", "%host%/test", "%host%/foo"},
+			},
+		},
+		{
+			name:                             "relative links resolved against base href",
+			mockHttpResponse:                 `<!DOCTYPE html><html lang="en"><head><base href="%host%/docs/guide/"><base href="%host%/ignored/"></head><body><a href="intro">Intro</a><a href="../api">API</a><a href="/about">About</a></body></html>`,
+			mockLogInfoCalls:                 4,
+			mockStoreWasAlreadyVisitedCalls:  3,
+			mockStoreWasAlreadyVisitedResult: false,
+			ch: &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			},
+			expectedCH: extractLinksCh{
+				workers:  []int{1},
+				finished: []int{3},
+				queue:    []string{"%host%/docs/guide/intro", "%host%/docs/api", "%host%/about"},
+			},
+		},
+		{
+			name:             "page at max depth does not queue links",
+			mockHttpResponse: `<!DOCTYPE html><html lang="en"><body><a href="%host%/about">About Us</a></body></html>`,
+			mockLogInfoCalls: 1,
+			depth:            2,
+			maxDepth:         2,
+			ch: &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			},
+			expectedCH: extractLinksCh{
+				workers:  []int{1},
+				finished: []int{0},
+				queue:    []string{},
+			},
+		},
+		{
+			name:                             "page below max depth queues links",
+			mockHttpResponse:                 `<!DOCTYPE html><html lang="en"><body><a href="%host%/about">About Us</a></body></html>`,
+			mockLogInfoCalls:                 2,
+			mockStoreWasAlreadyVisitedCalls:  1,
+			mockStoreWasAlreadyVisitedResult: false,
+			depth:                            1,
+			maxDepth:                         2,
+			ch: &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			},
+			expectedCH: extractLinksCh{
+				workers:  []int{1},
+				finished: []int{1},
+				queue:    []string{"%host%/about"},
+			},
+		},
+		{
+			name:             "cancelled context does not visit the page",
+			mockHttpResponse: `<!DOCTYPE html><html lang="en"><body><a href="%host%/about">About Us</a></body></html>`,
+			cancelled:        true,
+			ch: &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			},
+			expectedCH: extractLinksCh{
+				workers:  []int{1},
+				finished: []int{0},
+				queue:    []string{},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -296,15 +419,32 @@ func TestExtractLinks(t *testing.T) {
 			logMock.EXPECT().Error(gomock.Any()).Times(tc.mockLogErrorCalls)
 
 			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
 			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(tc.mockStoreWasAlreadyVisitedResult, tc.mockStoreWasAlreadyVisitedError).Times(tc.mockStoreWasAlreadyVisitedCalls)
 
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).DoAndReturn(func(link *url.URL) bool {
+				for _, path := range tc.robotsBlockedPaths {
+					if link.Path == path {
+						return false
+					}
+				}
+				return true
+			}).AnyTimes()
+
 			// Create and run the crawler.
-			crawler, _ := NewCrawler(1, testServer.URL, tc.ch, logMock, storeMock)
+			item := models.CrawlItem{URL: testServer.URL, Depth: tc.depth}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, item, tc.ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{MaxDepth: tc.maxDepth})
 
-			crawler.SpinUpCrawler()
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.cancelled {
+				cancel()
+			}
+			defer cancel()
+			crawler.SpinUpCrawler(ctx)
 			close(tc.ch.Finished)
 			close(tc.ch.Workers)
-			close(tc.ch.Queue)
 
 			// Compare expected and actual results.
 			expectedqueue := []string{}
@@ -313,8 +453,13 @@ func TestExtractLinks(t *testing.T) {
 
 			}
 			resultQueue := []string{}
-			for queue := range tc.ch.Queue {
-				resultQueue = append(resultQueue, queue)
+			queued, err := queue.Items()
+			assert.Nil(t, err)
+			for _, item := range queued {
+				resultQueue = append(resultQueue, item.URL)
+				assert.Equal(t, tc.depth+1, item.Depth)
+				// the parent is the page as it was linked
+				assert.Equal(t, testServer.URL, item.Parent)
 			}
 			assert.Equal(t, expectedqueue, resultQueue)
 
@@ -333,6 +478,161 @@ func TestExtractLinks(t *testing.T) {
 	}
 }
 
+func TestExtractLinksAfterRedirect(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+	}{
+		{
+			name:  "redirected page",
+			start: "/start",
+		},
+		{
+			// the page is requested as linked, the trailing slash is kept
+			name:  "page with a trailing slash",
+			start: "/blog/post/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requested := []string{}
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = append(requested, r.URL.Path)
+				if r.URL.Path == "/start" {
+					http.Redirect(w, r, "/blog/post/", http.StatusMovedPermanently)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, `<html><body><a href="next/">Next</a><a href="../archive">Archive</a></body></html>`)
+			}))
+			defer testServer.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+			// the links are deduplicated by their canonical form
+			storeMock.EXPECT().WasAlreadyVisited("//"+testServer.Listener.Addr().String()+"/blog/post/next").Return(false, nil)
+			storeMock.EXPECT().WasAlreadyVisited("//"+testServer.Listener.Addr().String()+"/blog/archive").Return(false, nil)
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).Times(2)
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL + tc.start}, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+			crawler.SpinUpCrawler(context.Background())
+
+			// relative links are resolved against the final URL, not the requested one
+			assert.Equal(t, tc.start, requested[0])
+			assert.Equal(t, "/blog/post/", requested[len(requested)-1])
+			assert.Equal(t, testServer.URL+"/blog/post/next/", popLink(t, queue).URL)
+			assert.Equal(t, testServer.URL+"/blog/archive", popLink(t, queue).URL)
+		})
+	}
+}
+
+func TestExtractLinksSources(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="next" href="/page/2"><link rel="stylesheet" href="/style.css"></head><body><a href="/about">About</a><form action="/search"></form></body></html>`)
+	}))
+	defer testServer.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logMock := mock_logger.NewMockIlogger(ctrl)
+	logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+	storeMock := mock_store.NewMockICrawlerStore(ctrl)
+	storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+	storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(2)
+
+	robotsMock := mock_robots.NewMockIRobots(ctrl)
+	robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).Times(2)
+
+	ch := &models.CommunitationChans{
+		Workers:  make(chan int, 1),
+		Finished: make(chan int, 1),
+	}
+	extractor := NewExtractor([]string{"a", "link"})
+	queue := newTestQueue(t)
+	crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL}, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock, NewNormalizer(nil), extractor, nil, models.Config{})
+	crawler.SpinUpCrawler(context.Background())
+
+	next := popLink(t, queue)
+	assert.Equal(t, testServer.URL+"/page/2", next.URL)
+	assert.Equal(t, "link", next.Tag)
+	assert.Equal(t, "href", next.Attr)
+
+	about := popLink(t, queue)
+	assert.Equal(t, testServer.URL+"/about", about.URL)
+	assert.Equal(t, "a", about.Tag)
+	assert.Equal(t, "href", about.Attr)
+}
+
+// linkRecordingStore is a store that records the links between the pages.
+type linkRecordingStore struct {
+	*mock_store.MockICrawlerStore
+	*mock_store.MockILinkRecorder
+}
+
+func TestExtractLinksRecordsLinks(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Home</title><link rel="next" href="/page/2"></head><body>
+			<a href="/about">About <b>us</b>
+			</a><a href="/">Home</a><a href="https://other-site.com/">Other</a><a href="/contact"><img src="/mail.png"></a>
+			<p>Not a link</p></body></html>`)
+	}))
+	defer testServer.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logMock := mock_logger.NewMockIlogger(ctrl)
+	logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+	var recorded models.PageResult
+	storeMock := mock_store.NewMockICrawlerStore(ctrl)
+	storeMock.EXPECT().RecordPage(gomock.Any()).DoAndReturn(func(result models.PageResult) error {
+		recorded = result
+		return nil
+	})
+	storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(3)
+	// the links to other sites and to the root page are not crawled, so not recorded
+	linksMock := mock_store.NewMockILinkRecorder(ctrl)
+	linksMock.EXPECT().RecordLinks([]models.LinkResult{
+		{From: testServer.URL + "/", To: testServer.URL + "/page/2", Tag: "link", Attr: "href"},
+		{From: testServer.URL + "/", To: testServer.URL + "/about", Text: "About us", Tag: "a", Attr: "href"},
+		{From: testServer.URL + "/", To: testServer.URL + "/contact", Tag: "a", Attr: "href"},
+	}).Return(nil)
+
+	robotsMock := mock_robots.NewMockIRobots(ctrl)
+	robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).Times(3)
+
+	ch := &models.CommunitationChans{
+		Workers:  make(chan int, 1),
+		Finished: make(chan int, 1),
+	}
+	crawlerStore := linkRecordingStore{MockICrawlerStore: storeMock, MockILinkRecorder: linksMock}
+	crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL + "/"}, ch, newTestQueue(t), logMock, NewFetcher(http.DefaultClient), crawlerStore,
+		robotsMock, NewNormalizer(nil), NewExtractor([]string{"a", "link"}), nil, models.Config{})
+	crawler.SpinUpCrawler(context.Background())
+
+	assert.Equal(t, 3, <-ch.Finished)
+	assert.Equal(t, "Home", recorded.Title)
+}
+
 func BenchmarkSpinUpCrawler(b *testing.B) {
 	b.StopTimer()
 
@@ -369,16 +669,21 @@ func BenchmarkSpinUpCrawler(b *testing.B) {
 	logMock.EXPECT().Error(gomock.Any()).AnyTimes()
 
 	storeMock := mock_store.NewMockICrawlerStore(ctrl)
+	storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil).AnyTimes()
 	storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).AnyTimes()
 
+	robotsMock := mock_robots.NewMockIRobots(ctrl)
+	robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
 	ch := &models.CommunitationChans{
-		Queue:    make(chan string, 5),
 		Workers:  make(chan int, 1),
 		Finished: make(chan int, 1),
 	}
 
+	queue := newTestQueue(b)
+
 	// Create and run the crawler.
 	b.StartTimer()
-	crawler, _ := NewCrawler(1, testServer.URL, ch, logMock, storeMock)
-	crawler.SpinUpCrawler()
+	crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL}, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+	crawler.SpinUpCrawler(context.Background())
 }
@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// directives are the indexing rules of a page, given by <meta name="robots"> tags and
+// X-Robots-Tag headers.
+type directives struct {
+	noindex  bool
+	nofollow bool
+}
+
+// merge adds the rules of a comma separated list such as "noindex, nofollow", rules that
+// are not about indexing or following are ignored.
+func (d *directives) merge(rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		switch strings.ToLower(strings.TrimSpace(rule)) {
+		case "noindex":
+			d.noindex = true
+		case "nofollow":
+			d.nofollow = true
+		case "none":
+			d.noindex = true
+			d.nofollow = true
+		}
+	}
+}
+
+// mergeHeader adds the rules of the X-Robots-Tag headers that apply to every crawler or
+// to the user agent, e.g. "noindex" or "crawler: nofollow".
+func (d *directives) mergeHeader(header http.Header, userAgent string) {
+	token := productToken(userAgent)
+	for _, value := range header.Values("X-Robots-Tag") {
+		agent, rules, found := strings.Cut(value, ":")
+		if !found || strings.Contains(agent, ",") || isRule(agent) {
+			// no user agent prefix, the colon belongs to a rule such as unavailable_after
+			d.merge(value)
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(agent), token) {
+			d.merge(rules)
+		}
+	}
+}
+
+// mergeMeta adds the rules of a <meta name="robots"> tag, or of a meta tag named after the user agent.
+func (d *directives) mergeMeta(token html.Token, userAgent string) {
+	name, content := "", ""
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "name":
+			name = strings.TrimSpace(attr.Val)
+		case "content":
+			content = attr.Val
+		}
+	}
+	if strings.EqualFold(name, "robots") || strings.EqualFold(name, productToken(userAgent)) {
+		d.merge(content)
+	}
+}
+
+// productToken returns the name the user agent is given directives for, "mybot" for
+// "mybot/1.0 (+https://example.com)", as robots.txt groups are matched.
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(userAgent, "/")
+	return strings.TrimSpace(token)
+}
+
+// isRule reports whether the X-Robots-Tag prefix is a rule name rather than a user agent.
+func isRule(prefix string) bool {
+	switch strings.ToLower(strings.TrimSpace(prefix)) {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestDirectivesMerge(t *testing.T) {
+	tests := []struct {
+		rules    string
+		expected directives
+	}{
+		{rules: "noindex", expected: directives{noindex: true}},
+		{rules: "NoFollow", expected: directives{nofollow: true}},
+		{rules: "noindex, nofollow", expected: directives{noindex: true, nofollow: true}},
+		{rules: "none", expected: directives{noindex: true, nofollow: true}},
+		{rules: "all", expected: directives{}},
+		{rules: "index,follow,max-snippet:20", expected: directives{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.rules, func(t *testing.T) {
+			d := directives{}
+			d.merge(tc.rules)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestDirectivesMergeHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []string
+		userAgent string
+		expected  directives
+	}{
+		{
+			name:     "no header",
+			expected: directives{},
+		},
+		{
+			name:     "rules for every crawler",
+			values:   []string{"noindex, nofollow"},
+			expected: directives{noindex: true, nofollow: true},
+		},
+		{
+			name:     "rules for the user agent",
+			values:   []string{"Crawler: nofollow"},
+			expected: directives{nofollow: true},
+		},
+		{
+			name:      "rules for the product token of a versioned user agent",
+			values:    []string{"mybot: noindex"},
+			userAgent: "mybot/1.0 (+https://example.com/bot)",
+			expected:  directives{noindex: true},
+		},
+		{
+			name:     "rules for other user agents",
+			values:   []string{"googlebot: noindex", "bingbot: nofollow"},
+			expected: directives{},
+		},
+		{
+			name:     "several headers",
+			values:   []string{"unavailable_after: 25 Jun 2010 15:00:00 PST", "noindex"},
+			expected: directives{noindex: true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range tc.values {
+				header.Add("X-Robots-Tag", value)
+			}
+			userAgent := tc.userAgent
+			if userAgent == "" {
+				userAgent = "crawler"
+			}
+			d := directives{}
+			d.mergeHeader(header, userAgent)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestDirectivesMergeMeta(t *testing.T) {
+	tests := []struct {
+		tag       string
+		userAgent string
+		expected  directives
+	}{
+		{tag: `<meta name="robots" content="noindex">`, expected: directives{noindex: true}},
+		{tag: `<meta name="ROBOTS" content="nofollow, noarchive">`, expected: directives{nofollow: true}},
+		{tag: `<meta name="crawler" content="none">`, expected: directives{noindex: true, nofollow: true}},
+		{tag: `<meta name="MyBot" content="noindex">`, userAgent: "mybot/1.0 (+https://example.com/bot)", expected: directives{noindex: true}},
+		{tag: `<meta name="googlebot" content="noindex">`, expected: directives{}},
+		{tag: `<meta name="description" content="noindex">`, expected: directives{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.tag, func(t *testing.T) {
+			tokenizer := html.NewTokenizer(strings.NewReader(tc.tag))
+			tokenizer.Next()
+			userAgent := tc.userAgent
+			if userAgent == "" {
+				userAgent = "crawler"
+			}
+			d := directives{}
+			d.mergeMeta(tokenizer.Token(), userAgent)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestExtractLinksDirectives(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		meta            string
+		honorDirectives bool
+		expectedQueue   []string
+		expectedNoindex bool
+	}{
+		{
+			name:          "directives are ignored by default",
+			meta:          `<meta name="robots" content="noindex, nofollow">`,
+			expectedQueue: []string{"/about", "/sponsor"},
+		},
+		{
+			name:            "nofollow links are skipped",
+			honorDirectives: true,
+			expectedQueue:   []string{"/about"},
+		},
+		{
+			name:            "meta nofollow page",
+			meta:            `<meta name="robots" content="nofollow">`,
+			honorDirectives: true,
+			expectedQueue:   []string{},
+		},
+		{
+			name:            "meta noindex page",
+			meta:            `<meta name="robots" content="noindex">`,
+			honorDirectives: true,
+			expectedQueue:   []string{"/about"},
+			expectedNoindex: true,
+		},
+		{
+			name:            "header none page",
+			header:          "none",
+			honorDirectives: true,
+			expectedQueue:   []string{},
+			expectedNoindex: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				if tc.header != "" {
+					w.Header().Set("X-Robots-Tag", tc.header)
+				}
+				// the meta tag comes after the first link, it applies to the whole page anyway
+				fmt.Fprintf(w, `<html><body><a href="/about">About</a>%s<a rel="sponsored nofollow" href="/sponsor">Sponsor</a></body></html>`, tc.meta)
+			}))
+			defer testServer.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(len(tc.expectedQueue))
+			if tc.expectedNoindex {
+				storeMock.EXPECT().RecordNoindex(testServer.URL + "/page").Return(nil)
+			}
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			cfg := models.Config{UserAgent: "crawler", HonorDirectives: tc.honorDirectives}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: testServer.URL + "/page"}, ch, queue, logMock, NewFetcher(http.DefaultClient),
+				storeMock, robotsMock, NewNormalizer(nil), NewExtractor([]string{"a"}), nil, cfg)
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, len(tc.expectedQueue), <-ch.Finished)
+			for _, path := range tc.expectedQueue {
+				assert.Equal(t, testServer.URL+path, popLink(t, queue).URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/csrar/crawler/internal/models"
+	"golang.org/x/net/html"
+)
+
+// linkRels are the <link rel> values that point to navigable pages.
+var linkRels = map[string]bool{
+	"alternate": true,
+	"next":      true,
+	"prev":      true,
+	"canonical": true,
+}
+
+// linkSource extracts the link of a tag from one of its attributes.
+type linkSource struct {
+	attr   string
+	accept func(attrs map[string]string) bool
+	value  func(attrs map[string]string) string
+}
+
+var linkSources = map[string]linkSource{
+	"a":      {attr: "href"},
+	"area":   {attr: "href"},
+	"iframe": {attr: "src"},
+	"frame":  {attr: "src"},
+	"link": {
+		attr: "href",
+		accept: func(attrs map[string]string) bool {
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if linkRels[rel] {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	"meta": {
+		attr: "content",
+		accept: func(attrs map[string]string) bool {
+			return strings.EqualFold(strings.TrimSpace(attrs["http-equiv"]), "refresh")
+		},
+		value: func(attrs map[string]string) string {
+			return refreshURL(attrs["content"])
+		},
+	},
+	"form": {
+		attr: "action",
+		accept: func(attrs map[string]string) bool {
+			method := strings.ToLower(strings.TrimSpace(attrs["method"]))
+			return method == "" || method == "get"
+		},
+	},
+}
+
+//go:generate mockgen -source=extract.go -destination=mocks/extract_mock.go
+type IExtractor interface {
+	Extract(token html.Token) []models.Link
+}
+
+type extractor struct {
+	sources map[string]linkSource
+}
+
+// NewExtractor creates a link extractor for the given tags, supported tags are
+// a, area, link, iframe, frame, meta and form. Unknown tags are ignored.
+func NewExtractor(tags []string) IExtractor {
+	e := &extractor{
+		sources: map[string]linkSource{},
+	}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if source, ok := linkSources[tag]; ok {
+			e.sources[tag] = source
+		}
+	}
+	return e
+}
+
+// Extract returns the raw link found in the token, if its tag is an enabled link source.
+func (e *extractor) Extract(token html.Token) []models.Link {
+	source, ok := e.sources[token.Data]
+	if !ok {
+		return nil
+	}
+	attrs := map[string]string{}
+	for _, attr := range token.Attr {
+		if _, found := attrs[attr.Key]; !found {
+			attrs[attr.Key] = attr.Val
+		}
+	}
+	if source.accept != nil && !source.accept(attrs) {
+		return nil
+	}
+
+	href, found := attrs[source.attr]
+	if source.value != nil {
+		href = source.value(attrs)
+	}
+	if !found || strings.TrimSpace(href) == "" {
+		return nil
+	}
+	return []models.Link{{Href: href, Tag: token.Data, Attr: source.attr}}
+}
+
+// refreshURL returns the URL of a meta refresh content such as "5; url=/next".
+func refreshURL(content string) string {
+	_, target, found := strings.Cut(content, ";")
+	if !found {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if len(target) < 4 || !strings.EqualFold(target[:3], "url") {
+		return ""
+	}
+	target = strings.TrimSpace(target[3:])
+	if !strings.HasPrefix(target, "=") {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(target[1:]), `"'`)
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestExtract(t *testing.T) {
+	allSources := []string{"a", "area", "link", "iframe", "frame", "meta", "form"}
+	tests := []struct {
+		name     string
+		sources  []string
+		tag      string
+		expected []models.Link
+	}{
+		{
+			name:     "anchor href",
+			sources:  allSources,
+			tag:      `<a href="/about">About</a>`,
+			expected: []models.Link{{Href: "/about", Tag: "a", Attr: "href"}},
+		},
+		{
+			name:     "anchor without href",
+			sources:  allSources,
+			tag:      `<a name="top">Top</a>`,
+			expected: nil,
+		},
+		{
+			name:     "disabled source",
+			sources:  []string{"a"},
+			tag:      `<iframe src="/embed"></iframe>`,
+			expected: nil,
+		},
+		{
+			name:     "area href",
+			sources:  allSources,
+			tag:      `<area shape="rect" href="/region">`,
+			expected: []models.Link{{Href: "/region", Tag: "area", Attr: "href"}},
+		},
+		{
+			name:     "iframe src",
+			sources:  allSources,
+			tag:      `<iframe src="/embed"></iframe>`,
+			expected: []models.Link{{Href: "/embed", Tag: "iframe", Attr: "src"}},
+		},
+		{
+			name:     "frame src",
+			sources:  allSources,
+			tag:      `<frame src="/menu">`,
+			expected: []models.Link{{Href: "/menu", Tag: "frame", Attr: "src"}},
+		},
+		{
+			name:     "link rel next",
+			sources:  allSources,
+			tag:      `<link rel="next" href="/page/2">`,
+			expected: []models.Link{{Href: "/page/2", Tag: "link", Attr: "href"}},
+		},
+		{
+			name:     "link with several rel values",
+			sources:  allSources,
+			tag:      `<link rel="Alternate nofollow" hreflang="es" href="/es">`,
+			expected: []models.Link{{Href: "/es", Tag: "link", Attr: "href"}},
+		},
+		{
+			name:     "link rel stylesheet is not a page",
+			sources:  allSources,
+			tag:      `<link rel="stylesheet" href="/style.css">`,
+			expected: nil,
+		},
+		{
+			name:     "meta refresh",
+			sources:  allSources,
+			tag:      `<meta http-equiv="Refresh" content="5; URL='/moved'">`,
+			expected: []models.Link{{Href: "/moved", Tag: "meta", Attr: "content"}},
+		},
+		{
+			name:     "meta refresh without url",
+			sources:  allSources,
+			tag:      `<meta http-equiv="refresh" content="30">`,
+			expected: nil,
+		},
+		{
+			name:     "meta without refresh",
+			sources:  allSources,
+			tag:      `<meta name="description" content="url=/nope">`,
+			expected: nil,
+		},
+		{
+			name:     "get form action",
+			sources:  allSources,
+			tag:      `<form action="/search"><input name="q"></form>`,
+			expected: []models.Link{{Href: "/search", Tag: "form", Attr: "action"}},
+		},
+		{
+			name:     "post form action",
+			sources:  allSources,
+			tag:      `<form method="POST" action="/login"></form>`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenizer := html.NewTokenizer(strings.NewReader(tc.tag))
+			tokenizer.Next()
+
+			extractor := NewExtractor(tc.sources)
+			assert.Equal(t, tc.expected, extractor.Extract(tokenizer.Token()))
+		})
+	}
+}
+
+func TestRefreshURL(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{content: "0;url=/next", expected: "/next"},
+		{content: "5; URL = \"/quoted\"", expected: "/quoted"},
+		{content: "10", expected: ""},
+		{content: "1; /no-url-key", expected: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.content, func(t *testing.T) {
+			assert.Equal(t, tc.expected, refreshURL(tc.content))
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+//go:generate mockgen -source=fetcher.go -destination=mocks/fetcher_mock.go
+type IFetcher interface {
+	Fetch(ctx context.Context, method string, link string) (*http.Response, error)
+}
+
+type fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher creates a fetcher that makes its requests with the client.
+func NewFetcher(client *http.Client) IFetcher {
+	return &fetcher{
+		client: client,
+	}
+}
+
+// Fetch requests the link, the caller must close the response body.
+func (f *fetcher) Fetch(ctx context.Context, method string, link string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %v", err)
+	}
+	return f.client.Do(request)
+}
+
+// NewHTTPClient creates the client every request of the crawl is made with, configured with
+// the timeouts, User-Agent, extra headers, proxy and TLS options.
+func NewHTTPClient(cfg models.Config) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %s: %v", cfg.ProxyURL, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.DialContext = (&net.Dialer{Timeout: cfg.ConnectTimeout}).DialContext
+	transport.TLSHandshakeTimeout = cfg.ConnectTimeout
+	// the read timeout applies to the response headers and then to every read of the body
+	transport.ResponseHeaderTimeout = cfg.ReadTimeout
+	transport.TLSClientConfig = tlsConfig
+	var base http.RoundTripper = transport
+	if cfg.ReadTimeout > 0 {
+		base = &idleTimeoutTransport{base: transport, timeout: cfg.ReadTimeout}
+	}
+	return &http.Client{
+		Transport: &headerTransport{
+			base:      base,
+			userAgent: cfg.UserAgent,
+			headers:   cfg.ExtraHeaders,
+		},
+		Timeout: cfg.RequestTimeout,
+	}, nil
+}
+
+// newTLSConfig adds the custom root CAs to the system ones and loads the client certificate for mTLS.
+func newTLSConfig(cfg models.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, errors.New("both the client certificate and key files are required for mTLS")
+		}
+		certificate, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// headerTransport sets the User-Agent and the extra headers on every request.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+func (t *headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the request it was given
+	request = request.Clone(request.Context())
+	for name, value := range t.headers {
+		request.Header.Set(name, value)
+	}
+	if t.userAgent != "" {
+		request.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(request)
+}
+
+// idleTimeoutTransport cancels the request once a read of its body waits for data longer than the
+// timeout, a server stalling in the middle of the body is cut without waiting for the total timeout.
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(request.Context())
+	response, err := t.base.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{ReadCloser: response.Body, cancel: cancel, timeout: t.timeout}
+	// the timer only runs while a read waits, the time the caller spends between reads is not counted
+	body.timer = time.AfterFunc(t.timeout, body.expire)
+	body.timer.Stop()
+	response.Body = body
+	return response, nil
+}
+
+// idleTimeoutBody is a response body cancelling its request when a read does not return within the
+// timeout.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && err != io.EOF && b.expired.Load() {
+		return n, fmt.Errorf("no data received from the body for %s: %w", b.timeout, err)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (b *idleTimeoutBody) expire() {
+	b.expired.Store(true)
+	b.cancel()
+}
@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_crawler "github.com/csrar/crawler/pkg/crawler/mocks"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePEM writes a PEM block to a file of the test temporary directory and returns its path.
+func writePEM(t *testing.T, name string, blockType string, bytes []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600)
+	assert.Nil(t, err)
+	return path
+}
+
+// newClientCertificate creates a self signed client certificate and returns its cert and key files.
+func newClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return writePEM(t, "client.crt", "CERTIFICATE", cert), writePEM(t, "client.key", "EC PRIVATE KEY", keyBytes)
+}
+
+func TestFetchHeaders(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		assert.Equal(t, "crawler/1.0", r.UserAgent())
+		assert.Equal(t, "es", r.Header.Get("Accept-Language"))
+		assert.Equal(t, "seo", r.Header.Get("X-Team"))
+	}))
+	defer testServer.Close()
+
+	client, err := NewHTTPClient(models.Config{
+		UserAgent:    "crawler/1.0",
+		ExtraHeaders: map[string]string{"Accept-Language": "es", "X-Team": "seo", "User-Agent": "overridden"},
+	})
+	assert.Nil(t, err)
+
+	response, err := NewFetcher(client).Fetch(context.Background(), http.MethodHead, testServer.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+}
+
+func TestFetchTimeouts(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name      string
+		cfg       models.Config
+		expectErr bool
+	}{
+		{name: "no timeouts", cfg: models.Config{}, expectErr: false},
+		{name: "read timeout", cfg: models.Config{ReadTimeout: 50 * time.Millisecond}, expectErr: true},
+		{name: "request timeout", cfg: models.Config{RequestTimeout: 50 * time.Millisecond}, expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tc.cfg)
+			assert.Nil(t, err)
+
+			response, err := NewFetcher(client).Fetch(context.Background(), http.MethodGet, testServer.URL)
+			assert.Equal(t, tc.expectErr, err != nil)
+			if err == nil {
+				response.Body.Close()
+			}
+		})
+	}
+}
+
+func TestFetchBodyReadTimeout(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pause, _ := time.ParseDuration(r.URL.Query().Get("pause"))
+		for i := 0; i < 4; i++ {
+			fmt.Fprint(w, "chunk")
+			w.(http.Flusher).Flush()
+			time.Sleep(pause)
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name      string
+		pause     time.Duration
+		expectErr bool
+	}{
+		{name: "body sent steadily past the read timeout", pause: 30 * time.Millisecond, expectErr: false},
+		{name: "body stalled", pause: 300 * time.Millisecond, expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewHTTPClient(models.Config{ReadTimeout: 100 * time.Millisecond})
+			assert.Nil(t, err)
+
+			response, err := NewFetcher(client).Fetch(context.Background(), http.MethodGet, fmt.Sprintf("%s?pause=%s", testServer.URL, tc.pause))
+			assert.Nil(t, err)
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			assert.Equal(t, tc.expectErr, err != nil)
+			if !tc.expectErr {
+				assert.Equal(t, strings.Repeat("chunk", 4), string(body))
+			}
+		})
+	}
+}
+
+func TestFetchProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxied request carries the absolute URL of the target
+		assert.Equal(t, "http://crawled.example.com/page", r.RequestURI)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(models.Config{ProxyURL: proxy.URL})
+	assert.Nil(t, err)
+
+	response, err := NewFetcher(client).Fetch(context.Background(), http.MethodGet, "http://crawled.example.com/page")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTeapot, response.StatusCode)
+	response.Body.Close()
+}
+
+func TestFetchTLS(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer testServer.Close()
+	caFile := writePEM(t, "ca.crt", "CERTIFICATE", testServer.Certificate().Raw)
+
+	tests := []struct {
+		name      string
+		cfg       models.Config
+		expectErr bool
+	}{
+		{name: "unknown certificate authority", cfg: models.Config{}, expectErr: true},
+		{name: "custom certificate authority", cfg: models.Config{TLSCAFile: caFile}, expectErr: false},
+		{name: "insecure skip verify", cfg: models.Config{TLSInsecureSkipVerify: true}, expectErr: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tc.cfg)
+			assert.Nil(t, err)
+
+			response, err := NewFetcher(client).Fetch(context.Background(), http.MethodGet, testServer.URL)
+			assert.Equal(t, tc.expectErr, err != nil)
+			if err == nil {
+				response.Body.Close()
+			}
+		})
+	}
+}
+
+func TestFetchMutualTLS(t *testing.T) {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Len(t, r.TLS.PeerCertificates, 1)
+	}))
+	testServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	testServer.StartTLS()
+	defer testServer.Close()
+	certFile, keyFile := newClientCertificate(t)
+
+	tests := []struct {
+		name      string
+		cfg       models.Config
+		expectErr bool
+	}{
+		{
+			name:      "without client certificate",
+			cfg:       models.Config{TLSInsecureSkipVerify: true},
+			expectErr: true,
+		},
+		{
+			name:      "with client certificate",
+			cfg:       models.Config{TLSInsecureSkipVerify: true, TLSCertFile: certFile, TLSKeyFile: keyFile},
+			expectErr: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tc.cfg)
+			assert.Nil(t, err)
+
+			response, err := NewFetcher(client).Fetch(context.Background(), http.MethodGet, testServer.URL)
+			assert.Equal(t, tc.expectErr, err != nil)
+			if err == nil {
+				response.Body.Close()
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientErrors(t *testing.T) {
+	certFile, _ := newClientCertificate(t)
+	invalidPEM := filepath.Join(t.TempDir(), "invalid.crt")
+	assert.Nil(t, os.WriteFile(invalidPEM, []byte("not a certificate"), 0600))
+
+	tests := []struct {
+		name        string
+		cfg         models.Config
+		expectedErr string
+	}{
+		{
+			name:        "missing CA file",
+			cfg:         models.Config{TLSCAFile: "/missing/ca.crt"},
+			expectedErr: "error reading CA file",
+		},
+		{
+			name:        "CA file without certificates",
+			cfg:         models.Config{TLSCAFile: invalidPEM},
+			expectedErr: "no certificates found in CA file",
+		},
+		{
+			name:        "client certificate without key",
+			cfg:         models.Config{TLSCertFile: certFile},
+			expectedErr: "both the client certificate and key files are required",
+		},
+		{
+			name:        "invalid client key",
+			cfg:         models.Config{TLSCertFile: certFile, TLSKeyFile: invalidPEM},
+			expectedErr: "error loading client certificate",
+		},
+		{
+			name:        "invalid proxy",
+			cfg:         models.Config{ProxyURL: "http://proxy:port"},
+			expectedErr: "invalid proxy URL",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tc.cfg)
+			assert.Nil(t, client)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestExtractLinksWithFetcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logMock := mock_logger.NewMockIlogger(ctrl)
+	logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+	storeMock := mock_store.NewMockICrawlerStore(ctrl)
+	storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+	storeMock.EXPECT().WasAlreadyVisited("//mock.com/about").Return(false, nil)
+
+	robotsMock := mock_robots.NewMockIRobots(ctrl)
+	robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true)
+
+	fetcherMock := mock_crawler.NewMockIFetcher(ctrl)
+	fetcherMock.EXPECT().Fetch(gomock.Any(), http.MethodGet, "https://mock.com/").Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(`<html><body><a href="/about">About</a></body></html>`)),
+	}, nil)
+
+	ch := &models.CommunitationChans{
+		Workers:  make(chan int, 1),
+		Finished: make(chan int, 1),
+	}
+	queue := newTestQueue(t)
+	crawler, _ := NewCrawler(1, models.CrawlItem{URL: "https://mock.com/"}, ch, queue, logMock, fetcherMock, storeMock, robotsMock,
+		NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+	crawler.SpinUpCrawler(context.Background())
+
+	assert.Equal(t, 1, <-ch.Finished)
+	assert.Equal(t, "https://mock.com/about", popLink(t, queue).URL)
+}
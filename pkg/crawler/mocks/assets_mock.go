@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: assets.go
+
+// Package mock_crawler is a generated GoMock package.
+package mock_crawler
+
+import (
+	context "context"
+	url "net/url"
+	reflect "reflect"
+
+	models "github.com/csrar/crawler/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIAssetChecker is a mock of IAssetChecker interface.
+type MockIAssetChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAssetCheckerMockRecorder
+}
+
+// MockIAssetCheckerMockRecorder is the mock recorder for MockIAssetChecker.
+type MockIAssetCheckerMockRecorder struct {
+	mock *MockIAssetChecker
+}
+
+// NewMockIAssetChecker creates a new mock instance.
+func NewMockIAssetChecker(ctrl *gomock.Controller) *MockIAssetChecker {
+	mock := &MockIAssetChecker{ctrl: ctrl}
+	mock.recorder = &MockIAssetCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIAssetChecker) EXPECT() *MockIAssetCheckerMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockIAssetChecker) Check(ctx context.Context, asset *url.URL, link models.Link, parent string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Check", ctx, asset, link, parent)
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockIAssetCheckerMockRecorder) Check(ctx, asset, link, parent interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockIAssetChecker)(nil).Check), ctx, asset, link, parent)
+}
+
+// Shutdown mocks base method.
+func (m *MockIAssetChecker) Shutdown(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shutdown", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Shutdown indicates an expected call of Shutdown.
+func (mr *MockIAssetCheckerMockRecorder) Shutdown(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockIAssetChecker)(nil).Shutdown), ctx)
+}
@@ -5,6 +5,7 @@
 package mock_crawler
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,27 +35,27 @@ func (m *MockICrawler) EXPECT() *MockICrawlerMockRecorder {
 }
 
 // ExtractLinks mocks base method.
-func (m *MockICrawler) ExtractLinks() error {
+func (m *MockICrawler) ExtractLinks(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExtractLinks")
+	ret := m.ctrl.Call(m, "ExtractLinks", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ExtractLinks indicates an expected call of ExtractLinks.
-func (mr *MockICrawlerMockRecorder) ExtractLinks() *gomock.Call {
+func (mr *MockICrawlerMockRecorder) ExtractLinks(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractLinks", reflect.TypeOf((*MockICrawler)(nil).ExtractLinks))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractLinks", reflect.TypeOf((*MockICrawler)(nil).ExtractLinks), ctx)
 }
 
 // SpinUpCrawler mocks base method.
-func (m *MockICrawler) SpinUpCrawler() {
+func (m *MockICrawler) SpinUpCrawler(ctx context.Context) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "SpinUpCrawler")
+	m.ctrl.Call(m, "SpinUpCrawler", ctx)
 }
 
 // SpinUpCrawler indicates an expected call of SpinUpCrawler.
-func (mr *MockICrawlerMockRecorder) SpinUpCrawler() *gomock.Call {
+func (mr *MockICrawlerMockRecorder) SpinUpCrawler(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpinUpCrawler", reflect.TypeOf((*MockICrawler)(nil).SpinUpCrawler))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpinUpCrawler", reflect.TypeOf((*MockICrawler)(nil).SpinUpCrawler), ctx)
 }
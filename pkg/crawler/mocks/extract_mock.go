@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: extract.go
+
+// Package mock_crawler is a generated GoMock package.
+package mock_crawler
+
+import (
+	reflect "reflect"
+
+	models "github.com/csrar/crawler/internal/models"
+	gomock "github.com/golang/mock/gomock"
+	html "golang.org/x/net/html"
+)
+
+// MockIExtractor is a mock of IExtractor interface.
+type MockIExtractor struct {
+	ctrl     *gomock.Controller
+	recorder *MockIExtractorMockRecorder
+}
+
+// MockIExtractorMockRecorder is the mock recorder for MockIExtractor.
+type MockIExtractorMockRecorder struct {
+	mock *MockIExtractor
+}
+
+// NewMockIExtractor creates a new mock instance.
+func NewMockIExtractor(ctrl *gomock.Controller) *MockIExtractor {
+	mock := &MockIExtractor{ctrl: ctrl}
+	mock.recorder = &MockIExtractorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIExtractor) EXPECT() *MockIExtractorMockRecorder {
+	return m.recorder
+}
+
+// Extract mocks base method.
+func (m *MockIExtractor) Extract(token html.Token) []models.Link {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Extract", token)
+	ret0, _ := ret[0].([]models.Link)
+	return ret0
+}
+
+// Extract indicates an expected call of Extract.
+func (mr *MockIExtractorMockRecorder) Extract(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Extract", reflect.TypeOf((*MockIExtractor)(nil).Extract), token)
+}
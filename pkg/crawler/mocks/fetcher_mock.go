@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: fetcher.go
+
+// Package mock_crawler is a generated GoMock package.
+package mock_crawler
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIFetcher is a mock of IFetcher interface.
+type MockIFetcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockIFetcherMockRecorder
+}
+
+// MockIFetcherMockRecorder is the mock recorder for MockIFetcher.
+type MockIFetcherMockRecorder struct {
+	mock *MockIFetcher
+}
+
+// NewMockIFetcher creates a new mock instance.
+func NewMockIFetcher(ctrl *gomock.Controller) *MockIFetcher {
+	mock := &MockIFetcher{ctrl: ctrl}
+	mock.recorder = &MockIFetcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIFetcher) EXPECT() *MockIFetcherMockRecorder {
+	return m.recorder
+}
+
+// Fetch mocks base method.
+func (m *MockIFetcher) Fetch(ctx context.Context, method, link string) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fetch", ctx, method, link)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fetch indicates an expected call of Fetch.
+func (mr *MockIFetcherMockRecorder) Fetch(ctx, method, link interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockIFetcher)(nil).Fetch), ctx, method, link)
+}
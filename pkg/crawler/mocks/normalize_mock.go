@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: normalize.go
+
+// Package mock_crawler is a generated GoMock package.
+package mock_crawler
+
+import (
+	url "net/url"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockINormalizer is a mock of INormalizer interface.
+type MockINormalizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockINormalizerMockRecorder
+}
+
+// MockINormalizerMockRecorder is the mock recorder for MockINormalizer.
+type MockINormalizerMockRecorder struct {
+	mock *MockINormalizer
+}
+
+// NewMockINormalizer creates a new mock instance.
+func NewMockINormalizer(ctrl *gomock.Controller) *MockINormalizer {
+	mock := &MockINormalizer{ctrl: ctrl}
+	mock.recorder = &MockINormalizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockINormalizer) EXPECT() *MockINormalizerMockRecorder {
+	return m.recorder
+}
+
+// Key mocks base method.
+func (m *MockINormalizer) Key(link *url.URL) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Key", link)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Key indicates an expected call of Key.
+func (mr *MockINormalizerMockRecorder) Key(link interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Key", reflect.TypeOf((*MockINormalizer)(nil).Key), link)
+}
+
+// Normalize mocks base method.
+func (m *MockINormalizer) Normalize(link *url.URL) *url.URL {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Normalize", link)
+	ret0, _ := ret[0].(*url.URL)
+	return ret0
+}
+
+// Normalize indicates an expected call of Normalize.
+func (mr *MockINormalizerMockRecorder) Normalize(link interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Normalize", reflect.TypeOf((*MockINormalizer)(nil).Normalize), link)
+}
@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+//go:generate mockgen -source=normalize.go -destination=mocks/normalize_mock.go
+type INormalizer interface {
+	Normalize(link *url.URL) *url.URL
+	Key(link *url.URL) string
+}
+
+type normalizer struct {
+	dropParams   map[string]bool
+	dropPrefixes []string
+}
+
+// NewNormalizer creates a URL normalizer that removes the given query parameters,
+// a trailing "*" drops every parameter starting with the prefix (e.g. "utm_*").
+func NewNormalizer(dropParams []string) INormalizer {
+	n := &normalizer{
+		dropParams: map[string]bool{},
+	}
+	for _, param := range dropParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		switch {
+		case param == "":
+		case strings.HasSuffix(param, "*"):
+			n.dropPrefixes = append(n.dropPrefixes, strings.TrimSuffix(param, "*"))
+		default:
+			n.dropParams[param] = true
+		}
+	}
+	return n
+}
+
+// Normalize returns the canonical form of the link: lowercase scheme and host, no default
+// port, no fragment, resolved dot segments, no trailing slash and sorted query parameters
+// without the tracking ones. Path case is preserved since paths are case sensitive.
+func (n *normalizer) Normalize(link *url.URL) *url.URL {
+	canonical := *link
+	canonical.Scheme = strings.ToLower(canonical.Scheme)
+	canonical.Host = strings.ToLower(canonical.Host)
+	if port := canonical.Port(); port != "" && defaultPorts[canonical.Scheme] == port {
+		canonical.Host = strings.TrimSuffix(canonical.Host, ":"+port)
+	}
+	canonical.Fragment = ""
+	canonical.RawFragment = ""
+
+	canonical.Path = removeDotSegments(canonical.Path)
+	if len(canonical.Path) > 1 {
+		canonical.Path = strings.TrimSuffix(canonical.Path, "/")
+	}
+	if canonical.Path == "" {
+		canonical.Path = "/"
+	}
+	// keep the raw path only when it carries escaped slashes that the decoded path would lose
+	if !strings.Contains(strings.ToLower(canonical.RawPath), "%2f") {
+		canonical.RawPath = ""
+	} else {
+		canonical.RawPath = removeDotSegments(canonical.RawPath)
+		if len(canonical.RawPath) > 1 {
+			canonical.RawPath = strings.TrimSuffix(canonical.RawPath, "/")
+		}
+	}
+
+	canonical.RawQuery = n.normalizeQuery(canonical.RawQuery)
+	canonical.ForceQuery = false
+	return &canonical
+}
+
+// Key returns the deduplication key of the link, its canonical form without the scheme
+// so the http and https variants of a page are visited once.
+func (n *normalizer) Key(link *url.URL) string {
+	canonical := n.Normalize(link)
+	canonical.Scheme = ""
+	return canonical.String()
+}
+
+// normalizeQuery sorts the query parameters by name, keeping the order of repeated
+// values, and removes the tracking parameters.
+func (n *normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// an unparseable query is kept as is rather than losing parameters
+		return rawQuery
+	}
+	for name := range values {
+		if n.isDropped(name) {
+			values.Del(name)
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for name := range values {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, name := range keys {
+		for _, value := range values[name] {
+			parts = append(parts, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// isDropped reports whether the query parameter is a configured tracking parameter.
+func (n *normalizer) isDropped(name string) bool {
+	name = strings.ToLower(name)
+	if n.dropParams[name] {
+		return true
+	}
+	for _, prefix := range n.dropPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDotSegments resolves the "." and ".." segments of a path as defined in RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return ""
+	}
+	output := []string{}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	result := strings.Join(output, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		link       string
+		dropParams []string
+		expected   string
+	}{
+		{
+			name:     "lowercase scheme and host",
+			link:     "HTTPS://Example.COM/Path",
+			expected: "https://example.com/Path",
+		},
+		{
+			name:     "path case is preserved",
+			link:     "https://example.com/About/Team",
+			expected: "https://example.com/About/Team",
+		},
+		{
+			name:     "default https port is removed",
+			link:     "https://example.com:443/page",
+			expected: "https://example.com/page",
+		},
+		{
+			name:     "default http port is removed",
+			link:     "http://example.com:80/page",
+			expected: "http://example.com/page",
+		},
+		{
+			name:     "non default port is kept",
+			link:     "https://example.com:8443/page",
+			expected: "https://example.com:8443/page",
+		},
+		{
+			name:     "fragment is removed",
+			link:     "https://example.com/page#section",
+			expected: "https://example.com/page",
+		},
+		{
+			name:     "query parameters are sorted",
+			link:     "https://example.com/page?b=1&a=2",
+			expected: "https://example.com/page?a=2&b=1",
+		},
+		{
+			name:     "repeated query parameters keep their order",
+			link:     "https://example.com/page?tag=z&a=1&tag=b",
+			expected: "https://example.com/page?a=1&tag=z&tag=b",
+		},
+		{
+			name:     "empty query is removed",
+			link:     "https://example.com/page?",
+			expected: "https://example.com/page",
+		},
+		{
+			name:       "tracking parameters are removed",
+			link:       "https://example.com/page?utm_source=news&id=3&UTM_Medium=mail&gclid=abc",
+			dropParams: []string{"utm_*", "gclid"},
+			expected:   "https://example.com/page?id=3",
+		},
+		{
+			name:     "dot segments are resolved",
+			link:     "https://example.com/a/./b/../c",
+			expected: "https://example.com/a/c",
+		},
+		{
+			name:     "dot segments above the root are ignored",
+			link:     "https://example.com/../../a",
+			expected: "https://example.com/a",
+		},
+		{
+			name:     "trailing slash is removed",
+			link:     "https://example.com/blog/",
+			expected: "https://example.com/blog",
+		},
+		{
+			name:     "root path is kept",
+			link:     "https://example.com",
+			expected: "https://example.com/",
+		},
+		{
+			name:     "escaped characters are normalized",
+			link:     "https://example.com/%7Euser/a%20b",
+			expected: "https://example.com/~user/a%20b",
+		},
+		{
+			name:     "escaped slashes are kept",
+			link:     "https://example.com/a%2Fb/",
+			expected: "https://example.com/a%2Fb",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			link, err := url.Parse(tc.link)
+			assert.Nil(t, err)
+
+			original := *link
+			normalizer := NewNormalizer(tc.dropParams)
+			assert.Equal(t, tc.expected, normalizer.Normalize(link).String())
+			// the link itself is not modified
+			assert.Equal(t, original, *link)
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		link      string
+		other     string
+		sameKey   bool
+		expectKey string
+	}{
+		{
+			name:      "http and https share a key",
+			link:      "http://example.com/page",
+			other:     "https://example.com/page",
+			sameKey:   true,
+			expectKey: "//example.com/page",
+		},
+		{
+			name:      "query order shares a key",
+			link:      "https://example.com/page?b=1&a=2",
+			other:     "https://example.com/page?a=2&b=1",
+			sameKey:   true,
+			expectKey: "//example.com/page?a=2&b=1",
+		},
+		{
+			name:      "fragments and trailing slash share a key",
+			link:      "https://example.com/page/#top",
+			other:     "https://example.com:443/page",
+			sameKey:   true,
+			expectKey: "//example.com/page",
+		},
+		{
+			name:      "dashes and slashes do not share a key",
+			link:      "https://example.com/a-b",
+			other:     "https://example.com/a/b",
+			sameKey:   false,
+			expectKey: "//example.com/a-b",
+		},
+		{
+			name:      "path case does not share a key",
+			link:      "https://example.com/Page",
+			other:     "https://example.com/page",
+			sameKey:   false,
+			expectKey: "//example.com/Page",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			link, _ := url.Parse(tc.link)
+			other, _ := url.Parse(tc.other)
+
+			normalizer := NewNormalizer(nil)
+			assert.Equal(t, tc.expectKey, normalizer.Key(link))
+			assert.Equal(t, tc.sameKey, normalizer.Key(link) == normalizer.Key(other))
+		})
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "", expected: ""},
+		{path: "/", expected: "/"},
+		{path: "/a/b/c/./../../g", expected: "/a/g"},
+		{path: "mid/content=5/../6", expected: "mid/6"},
+		{path: "/a/..", expected: "/"},
+		{path: "/a/.", expected: "/a/"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, removeDotSegments(tc.path))
+		})
+	}
+}
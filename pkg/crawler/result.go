@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+// newPageResult builds the record of a page fetch started at start from its response, or from the
+// error when the page could not be fetched.
+func newPageResult(item models.CrawlItem, page *url.URL, response *http.Response, err error, start time.Time) models.PageResult {
+	result := models.PageResult{
+		URL:          page.String(),
+		Depth:        item.Depth,
+		Parent:       item.Parent,
+		FetchedAt:    start,
+		ResponseTime: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.FinalURL = page.String()
+	if response.Request != nil && response.Request.URL != nil {
+		result.FinalURL = response.Request.URL.String()
+		result.OffHostRedirect = response.Request.URL.Host != page.Host
+	}
+	result.Redirects = redirectChain(response)
+	result.StatusCode = response.StatusCode
+	result.ContentType = response.Header.Get("Content-Type")
+	result.ContentLength = response.ContentLength
+	return result
+}
+
+// redirectChain returns the redirects followed to get the response, in the order they happened.
+// Every request made after a redirect keeps the response that caused it.
+func redirectChain(response *http.Response) []models.Redirect {
+	chain := []models.Redirect{}
+	if response.Request == nil {
+		return chain
+	}
+	for redirect := response.Request.Response; redirect != nil && redirect.Request != nil; redirect = redirect.Request.Response {
+		chain = append([]models.Redirect{{
+			URL:        redirect.Request.URL.String(),
+			StatusCode: redirect.StatusCode,
+			Location:   redirect.Header.Get("Location"),
+		}}, chain...)
+	}
+	return chain
+}
+
+// isSuccess reports whether the status code is 2xx.
+func isSuccess(statusCode int) bool {
+	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+}
+
+// countingReader counts the bytes read from a reader and whether it was read to the end.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+	eof    bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
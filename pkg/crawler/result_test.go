@@ -0,0 +1,231 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_crawler "github.com/csrar/crawler/pkg/crawler/mocks"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractLinksPageResult(t *testing.T) {
+	page := `<html><head><title>Mock
+		page</title></head><body><a href="/about">About</a></body></html>`
+	otherHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer otherHost.Close()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, otherHost.URL+"/landing", http.StatusFound)
+		case "/missing":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, page)
+		case "/streamed":
+			w.Header().Set("Content-Type", "text/html")
+			// flushing before the end of the body sends it chunked, without a Content-Length
+			fmt.Fprint(w, page[:10])
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, page[10:])
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, page)
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedQueue int
+		expected      models.PageResult
+	}{
+		{
+			name:          "page",
+			path:          "/page",
+			expectedQueue: 1,
+			expected: models.PageResult{
+				FinalURL:      testServer.URL + "/page",
+				Redirects:     []models.Redirect{},
+				StatusCode:    http.StatusOK,
+				ContentType:   "text/html; charset=utf-8",
+				Charset:       "utf-8",
+				Title:         "Mock page",
+				ContentLength: int64(len(page)),
+			},
+		},
+		{
+			name:          "redirect chain",
+			path:          "/old",
+			expectedQueue: 1,
+			expected: models.PageResult{
+				FinalURL: testServer.URL + "/page",
+				Redirects: []models.Redirect{
+					{URL: testServer.URL + "/old", StatusCode: http.StatusMovedPermanently, Location: "/moved"},
+					{URL: testServer.URL + "/moved", StatusCode: http.StatusFound, Location: "/page"},
+				},
+				StatusCode:    http.StatusOK,
+				ContentType:   "text/html; charset=utf-8",
+				Charset:       "utf-8",
+				Title:         "Mock page",
+				ContentLength: int64(len(page)),
+			},
+		},
+		{
+			name: "off host redirect is not parsed",
+			path: "/away",
+			expected: models.PageResult{
+				FinalURL: otherHost.URL + "/landing",
+				Redirects: []models.Redirect{
+					{URL: testServer.URL + "/away", StatusCode: http.StatusFound, Location: otherHost.URL + "/landing"},
+				},
+				OffHostRedirect: true,
+				StatusCode:      http.StatusOK,
+				ContentType:     "text/html",
+				ContentLength:   int64(len(page)),
+			},
+		},
+		{
+			name: "error page is not parsed",
+			path: "/missing",
+			expected: models.PageResult{
+				FinalURL:      testServer.URL + "/missing",
+				Redirects:     []models.Redirect{},
+				StatusCode:    http.StatusNotFound,
+				ContentType:   "text/html",
+				ContentLength: int64(len(page)),
+			},
+		},
+		{
+			name:          "length of a body without content length",
+			path:          "/streamed",
+			expectedQueue: 1,
+			expected: models.PageResult{
+				FinalURL:      testServer.URL + "/streamed",
+				Redirects:     []models.Redirect{},
+				StatusCode:    http.StatusOK,
+				ContentType:   "text/html",
+				Charset:       "utf-8",
+				Title:         "Mock page",
+				ContentLength: int64(len(page)),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			var recorded models.PageResult
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).DoAndReturn(func(result models.PageResult) error {
+				recorded = result
+				return nil
+			})
+			storeMock.EXPECT().WasAlreadyVisited(gomock.Any()).Return(false, nil).Times(tc.expectedQueue)
+
+			robotsMock := mock_robots.NewMockIRobots(ctrl)
+			robotsMock.EXPECT().IsAllowed(gomock.Any()).Return(true).AnyTimes()
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			item := models.CrawlItem{URL: testServer.URL + tc.path, Depth: 1, Parent: testServer.URL + "/"}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, item, ch, queue, logMock, NewFetcher(http.DefaultClient), storeMock, robotsMock,
+				NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, tc.expectedQueue, <-ch.Finished)
+			assert.Greater(t, recorded.ResponseTime.Nanoseconds(), int64(0))
+			assert.False(t, recorded.FetchedAt.IsZero())
+			tc.expected.URL = item.URL
+			tc.expected.Depth = item.Depth
+			tc.expected.Parent = item.Parent
+			tc.expected.FetchedAt = recorded.FetchedAt
+			tc.expected.ResponseTime = recorded.ResponseTime
+			assert.Equal(t, tc.expected, recorded)
+		})
+	}
+}
+
+// closeTracker is a response body that remembers whether it was closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestExtractLinksClosesBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		cfg    models.Config
+	}{
+		{name: "parsed page", status: http.StatusOK},
+		{name: "page at max depth", status: http.StatusOK, cfg: models.Config{MaxDepth: 1}},
+		{name: "error page", status: http.StatusGone},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Info(gomock.Any()).AnyTimes()
+
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+
+			body := &closeTracker{Reader: strings.NewReader(`<html><body></body></html>`)}
+			fetcherMock := mock_crawler.NewMockIFetcher(ctrl)
+			fetcherMock.EXPECT().Fetch(gomock.Any(), http.MethodGet, "https://mock.com/").Return(&http.Response{
+				StatusCode:    tc.status,
+				Status:        http.StatusText(tc.status),
+				Header:        http.Header{},
+				Body:          body,
+				ContentLength: -1,
+			}, nil)
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, models.CrawlItem{URL: "https://mock.com/", Depth: 1}, ch, queue, logMock, fetcherMock, storeMock,
+				mock_robots.NewMockIRobots(ctrl), NewNormalizer(nil), NewExtractor([]string{"a"}), nil, tc.cfg)
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, 0, <-ch.Finished)
+			assert.True(t, body.closed)
+		})
+	}
+}
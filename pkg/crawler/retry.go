@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxDrainSize is the maximum number of bytes read from a discarded response so its connection can be reused.
+const maxDrainSize = 64 * 1024
+
+// RetryPolicy configures how failed requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of requests made for a link, including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, a Retry-After longer than it is not waited for
+	MaxDelay time.Duration
+}
+
+type retryFetcher struct {
+	fetcher IFetcher
+	policy  RetryPolicy
+	now     func() time.Time
+	jitter  func(time.Duration) time.Duration
+}
+
+// NewRetryFetcher wraps a fetcher retrying transient network errors, 429 and 5xx responses with
+// exponential backoff and jitter, honoring the Retry-After header.
+func NewRetryFetcher(fetcher IFetcher, policy RetryPolicy) IFetcher {
+	return &retryFetcher{
+		fetcher: fetcher,
+		policy:  policy,
+		now:     time.Now,
+		jitter: func(delay time.Duration) time.Duration {
+			// equal jitter, between half and the whole delay
+			return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		},
+	}
+}
+
+// Fetch requests the link until it succeeds, the response is not retryable or the attempts are
+// exhausted, in which case the last response or error is returned.
+func (r *retryFetcher) Fetch(ctx context.Context, method string, link string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := r.fetcher.Fetch(ctx, method, link)
+		if !isRetryable(response, err) || ctx.Err() != nil {
+			return response, err
+		}
+		delay, ok := r.delay(attempt, response)
+		if attempt >= r.policy.MaxAttempts || !ok {
+			if err != nil {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return response, nil
+		}
+		if response != nil {
+			drain(response)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt, the Retry-After of the response when
+// there is one or the exponential backoff otherwise. It is not ok to wait longer than MaxDelay.
+func (r *retryFetcher) delay(attempt int, response *http.Response) (time.Duration, bool) {
+	if response != nil {
+		if retryAfter, found := parseRetryAfter(response.Header.Get("Retry-After"), r.now()); found {
+			return retryAfter, r.policy.MaxDelay <= 0 || retryAfter <= r.policy.MaxDelay
+		}
+	}
+	backoff := r.policy.BaseDelay
+	for i := 1; i < attempt && (r.policy.MaxDelay <= 0 || backoff < r.policy.MaxDelay); i++ {
+		backoff *= 2
+	}
+	if r.policy.MaxDelay > 0 && backoff > r.policy.MaxDelay {
+		backoff = r.policy.MaxDelay
+	}
+	return r.jitter(backoff), true
+}
+
+// isRetryable reports whether the request failed with a transient network error, a 429 or a 5xx
+// response.
+func isRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		return isTransient(err)
+	}
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+}
+
+// isTransient reports whether the error may not happen again: a timeout, a connection refused,
+// reset or closed before the response was read, or a temporary DNS failure. Errors such as invalid
+// certificates, unsupported schemes or a cancelled context fail the same way on every attempt.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter parses a Retry-After header, either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// drain discards the rest of a response body and closes it.
+func drain(response *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainSize))
+	response.Body.Close()
+}
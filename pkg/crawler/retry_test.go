@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	mock_crawler "github.com/csrar/crawler/pkg/crawler/mocks"
+	mock_logger "github.com/csrar/crawler/pkg/logger/mocks"
+	mock_robots "github.com/csrar/crawler/pkg/robots/mocks"
+	mock_store "github.com/csrar/crawler/pkg/store/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fetchResult is the outcome of one attempt of a mocked fetch.
+type fetchResult struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+func (f fetchResult) response() *http.Response {
+	if f.err != nil {
+		return nil
+	}
+	header := http.Header{}
+	if f.retryAfter != "" {
+		header.Set("Retry-After", f.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: f.status,
+		Status:     http.StatusText(f.status),
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("body")),
+	}
+}
+
+// timeoutError is a network error of a request that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// requestError wraps the error the way the HTTP client does.
+func requestError(err error) error {
+	return &url.Error{Op: "Get", URL: "https://mock.com/page", Err: err}
+}
+
+func TestRetryFetcher(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	tests := []struct {
+		name           string
+		attempts       []fetchResult
+		policy         RetryPolicy
+		expectedStatus int
+		expectedErr    string
+	}{
+		{
+			name:           "success on the first attempt",
+			attempts:       []fetchResult{{status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:           "client errors are not retried",
+			attempts:       []fetchResult{{status: 404}},
+			policy:         policy,
+			expectedStatus: 404,
+		},
+		{
+			name:           "server errors are retried",
+			attempts:       []fetchResult{{status: 503}, {status: 502}, {status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:           "connection resets are retried",
+			attempts:       []fetchResult{{err: requestError(&net.OpError{Op: "read", Err: syscall.ECONNRESET})}, {status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:           "timeouts are retried",
+			attempts:       []fetchResult{{err: requestError(timeoutError{})}, {status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:           "responses cut short are retried",
+			attempts:       []fetchResult{{err: requestError(io.ErrUnexpectedEOF)}, {status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:        "certificate errors are not retried",
+			attempts:    []fetchResult{{err: requestError(x509.UnknownAuthorityError{})}},
+			policy:      policy,
+			expectedErr: `Get "https://mock.com/page": x509: certificate signed by unknown authority`,
+		},
+		{
+			name:        "unsupported schemes are not retried",
+			attempts:    []fetchResult{{err: requestError(errors.New(`unsupported protocol scheme "ftp"`))}},
+			policy:      policy,
+			expectedErr: `Get "https://mock.com/page": unsupported protocol scheme "ftp"`,
+		},
+		{
+			name:        "request build errors are not retried",
+			attempts:    []fetchResult{{err: fmt.Errorf("error building request: %v", errors.New("invalid method"))}},
+			policy:      policy,
+			expectedErr: "error building request: invalid method",
+		},
+		{
+			name:        "unknown hosts are not retried",
+			attempts:    []fetchResult{{err: requestError(&net.DNSError{Err: "no such host", Name: "mock.com", IsNotFound: true})}},
+			policy:      policy,
+			expectedErr: `Get "https://mock.com/page": lookup mock.com: no such host`,
+		},
+		{
+			name:        "cancelled requests are not retried",
+			attempts:    []fetchResult{{err: requestError(context.Canceled)}},
+			policy:      policy,
+			expectedErr: `Get "https://mock.com/page": context canceled`,
+		},
+		{
+			name:           "too many requests honors retry after",
+			attempts:       []fetchResult{{status: 429, retryAfter: "0"}, {status: 200}},
+			policy:         policy,
+			expectedStatus: 200,
+		},
+		{
+			name:           "retry after longer than the max delay is not waited for",
+			attempts:       []fetchResult{{status: 429, retryAfter: "3600"}},
+			policy:         policy,
+			expectedStatus: 429,
+		},
+		{
+			name:           "attempts are capped with the last response",
+			attempts:       []fetchResult{{status: 500}, {status: 500}, {status: 503}},
+			policy:         policy,
+			expectedStatus: 503,
+		},
+		{
+			name: "attempts are capped with the last error",
+			attempts: []fetchResult{
+				{err: timeoutError{}}, {err: timeoutError{}}, {err: syscall.ECONNREFUSED},
+			},
+			policy:      policy,
+			expectedErr: "giving up after 3 attempts: connection refused",
+		},
+		{
+			name:           "single attempt",
+			attempts:       []fetchResult{{status: 500}},
+			policy:         RetryPolicy{MaxAttempts: 1},
+			expectedStatus: 500,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fetcherMock := mock_crawler.NewMockIFetcher(ctrl)
+			calls := []*gomock.Call{}
+			for _, attempt := range tc.attempts {
+				calls = append(calls, fetcherMock.EXPECT().Fetch(gomock.Any(), http.MethodGet, "https://mock.com/page").
+					Return(attempt.response(), attempt.err))
+			}
+			gomock.InOrder(calls...)
+
+			response, err := NewRetryFetcher(fetcherMock, tc.policy).Fetch(context.Background(), http.MethodGet, "https://mock.com/page")
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, response)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, response.StatusCode)
+		})
+	}
+}
+
+func TestRetryFetcherCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fetcherMock := mock_crawler.NewMockIFetcher(ctrl)
+	fetcherMock.EXPECT().Fetch(gomock.Any(), http.MethodGet, "https://mock.com/page").DoAndReturn(
+		func(context.Context, string, string) (*http.Response, error) {
+			cancel()
+			return fetchResult{status: 503}.response(), nil
+		})
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	response, _ := NewRetryFetcher(fetcherMock, policy).Fetch(ctx, http.MethodGet, "https://mock.com/page")
+	// the response of the cancelled attempt is returned instead of waiting for the next one
+	assert.Equal(t, 503, response.StatusCode)
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter string
+		expected   time.Duration
+		expectedOk bool
+	}{
+		{name: "first retry", attempt: 1, expected: 100 * time.Millisecond, expectedOk: true},
+		{name: "backoff doubles", attempt: 3, expected: 400 * time.Millisecond, expectedOk: true},
+		{name: "backoff is capped", attempt: 10, expected: time.Second, expectedOk: true},
+		{name: "retry after seconds", attempt: 1, retryAfter: "1", expected: time.Second, expectedOk: true},
+		{name: "retry after date", attempt: 1, retryAfter: "Thu, 01 Jun 2023 12:00:01 GMT", expected: time.Second, expectedOk: true},
+		{name: "retry after past date", attempt: 1, retryAfter: "Thu, 01 Jun 2023 11:00:00 GMT", expected: 0, expectedOk: true},
+		{name: "retry after too long", attempt: 1, retryAfter: "120", expected: 2 * time.Minute, expectedOk: false},
+		{name: "invalid retry after", attempt: 2, retryAfter: "soon", expected: 200 * time.Millisecond, expectedOk: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fetcher := NewRetryFetcher(nil, RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}).(*retryFetcher)
+			fetcher.now = func() time.Time { return now }
+			fetcher.jitter = func(delay time.Duration) time.Duration { return delay }
+
+			response := &http.Response{Header: http.Header{}}
+			if tc.retryAfter != "" {
+				response.Header.Set("Retry-After", tc.retryAfter)
+			}
+			delay, ok := fetcher.delay(tc.attempt, response)
+			assert.Equal(t, tc.expected, delay)
+			assert.Equal(t, tc.expectedOk, ok)
+		})
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	fetcher := NewRetryFetcher(nil, RetryPolicy{}).(*retryFetcher)
+	for i := 0; i < 100; i++ {
+		delay := fetcher.jitter(time.Second)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestExtractLinksRecordsFailures(t *testing.T) {
+	tests := []struct {
+		name           string
+		result         fetchResult
+		expectedFailed models.FailedPage
+	}{
+		{
+			name:   "network error",
+			result: fetchResult{err: errors.New("giving up after 3 attempts: connection refused")},
+			expectedFailed: models.FailedPage{
+				Item:  models.CrawlItem{URL: "https://mock.com/page", Depth: 2, Parent: "https://mock.com/"},
+				Error: "giving up after 3 attempts: connection refused",
+			},
+		},
+		{
+			name:   "server error",
+			result: fetchResult{status: 503},
+			expectedFailed: models.FailedPage{
+				Item:       models.CrawlItem{URL: "https://mock.com/page", Depth: 2, Parent: "https://mock.com/"},
+				StatusCode: 503,
+				Error:      "Service Unavailable",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			logMock := mock_logger.NewMockIlogger(ctrl)
+			logMock.EXPECT().Error(gomock.Any())
+
+			storeMock := mock_store.NewMockICrawlerStore(ctrl)
+			storeMock.EXPECT().RecordPage(gomock.Any()).Return(nil)
+			storeMock.EXPECT().RecordFailure(tc.expectedFailed).Return(nil)
+
+			fetcherMock := mock_crawler.NewMockIFetcher(ctrl)
+			fetcherMock.EXPECT().Fetch(gomock.Any(), http.MethodGet, "https://mock.com/page").Return(tc.result.response(), tc.result.err)
+
+			ch := &models.CommunitationChans{
+				Workers:  make(chan int, 1),
+				Finished: make(chan int, 1),
+			}
+			queue := newTestQueue(t)
+			crawler, _ := NewCrawler(1, tc.expectedFailed.Item, ch, queue, logMock, fetcherMock, storeMock, mock_robots.NewMockIRobots(ctrl),
+				NewNormalizer(nil), NewExtractor([]string{"a"}), nil, models.Config{})
+			crawler.SpinUpCrawler(context.Background())
+
+			assert.Equal(t, 0, <-ch.Finished)
+		})
+	}
+}
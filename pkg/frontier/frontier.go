@@ -0,0 +1,319 @@
+package frontier
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+const segmentFilePattern = "segment-*.jsonl"
+
+//go:generate mockgen -source=frontier.go -destination=mocks/frontier_mock.go
+type IFrontier interface {
+	Push(item models.CrawlItem) error
+	Pop() (models.CrawlItem, bool, error)
+	Ready() <-chan struct{}
+	Len() int
+	Items() ([]models.CrawlItem, error)
+	Close() error
+}
+
+// IShared is implemented by the frontiers shared by several crawler processes, the links pushed by
+// one of them may be popped by another. The first process to Seed the frontier pushes the links the
+// crawl starts from. Every popped link is settled with Done once the links found on it are pushed,
+// so the crawl is over once the frontier is Drained.
+type IShared interface {
+	Seed(items ...models.CrawlItem) (bool, error)
+	Done(item models.CrawlItem) error
+	Drained() (bool, error)
+}
+
+// IBudget is implemented by the frontiers that limit the links queued, such as to the MaxPages
+// budget. A link is only pushed once Reserve made room for it, and the links of a page are not
+// extracted once the budget is Spent.
+type IBudget interface {
+	Reserve() bool
+	Spent() bool
+}
+
+// SegmentError is returned by Pop when a segment could not be read back, its Items are lost.
+type SegmentError struct {
+	Segment string
+	Items   int
+	Err     error
+}
+
+func (e *SegmentError) Error() string {
+	return fmt.Sprintf("error reading frontier segment %s, %d links lost: %v", e.Segment, e.Items, e.Err)
+}
+
+func (e *SegmentError) Unwrap() error {
+	return e.Err
+}
+
+// frontier is a FIFO queue of links keeping up to memoryItems of its oldest links in memory and
+// spilling the newer ones to segment files of memoryItems links each. Once the links in memory
+// are popped the oldest segment is read back, so the queue can grow without bounds in a
+// bounded amount of memory and pushing never waits for a consumer.
+type frontier struct {
+	mu          sync.Mutex
+	dir         string
+	temporary   bool
+	memoryItems int
+	head        []models.CrawlItem
+	// segments are the spilled links from the oldest to the newest, the newest one is written to
+	segments []*segment
+	writer   *segmentWriter
+	created  int
+	count    int
+	ready    chan struct{}
+}
+
+// segment is a file of spilled links, one JSON encoded link per line.
+type segment struct {
+	path  string
+	items int
+}
+
+type segmentWriter struct {
+	segment *segment
+	file    *os.File
+	buffer  *bufio.Writer
+}
+
+// NewFrontier creates an empty frontier keeping up to memoryItems links in memory and spilling the
+// rest to dir, removing the segments left by a previous crawl. When dir is empty the segments are
+// kept in a temporary directory created on the first spill and removed on close.
+func NewFrontier(dir string, memoryItems int) (IFrontier, error) {
+	if memoryItems < 1 {
+		memoryItems = 1
+	}
+	f := &frontier{
+		dir:         dir,
+		temporary:   dir == "",
+		memoryItems: memoryItems,
+		ready:       make(chan struct{}, 1),
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating frontier directory: %w", err)
+		}
+		if err := f.removeSegments(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Push adds the link to the end of the frontier, writing it to disk when the memory is full.
+func (f *frontier) Push(item models.CrawlItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.segments) == 0 && len(f.head) < f.memoryItems {
+		f.head = append(f.head, item)
+	} else if err := f.spill(item); err != nil {
+		return err
+	}
+	f.count++
+	// consumers waiting on Ready pop every link, a pending signal is enough
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pop removes and returns the oldest link, reporting false when the frontier is empty.
+func (f *frontier) Pop() (models.CrawlItem, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.head) == 0 && len(f.segments) > 0 {
+		if err := f.load(); err != nil {
+			return models.CrawlItem{}, false, err
+		}
+	}
+	if len(f.head) == 0 {
+		return models.CrawlItem{}, false, nil
+	}
+	item := f.head[0]
+	f.head[0] = models.CrawlItem{}
+	f.head = f.head[1:]
+	f.count--
+	return item, true, nil
+}
+
+// Ready returns a channel signaled after links are pushed, consumers pop until the frontier is
+// empty before waiting on it again.
+func (f *frontier) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Len returns the number of links in the frontier.
+func (f *frontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+// Items returns every link of the frontier from the oldest to the newest without removing them.
+func (f *frontier) Items() ([]models.CrawlItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make([]models.CrawlItem, 0, f.count)
+	items = append(items, f.head...)
+	for _, segment := range f.segments {
+		segmentItems, err := f.read(segment)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, segmentItems...)
+	}
+	return items, nil
+}
+
+// Close drops the links of the frontier and removes its segments.
+func (f *frontier) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.head = nil
+	f.segments = nil
+	f.count = 0
+	if f.writer != nil {
+		f.writer.file.Close()
+		f.writer = nil
+	}
+	if f.dir == "" {
+		return nil
+	}
+	if f.temporary {
+		if err := os.RemoveAll(f.dir); err != nil {
+			return fmt.Errorf("error removing frontier directory: %w", err)
+		}
+		f.dir = ""
+		return nil
+	}
+	return f.removeSegments()
+}
+
+// spill appends the link to the newest segment, starting a new one when it is full.
+func (f *frontier) spill(item models.CrawlItem) error {
+	if f.writer == nil || f.writer.segment.items >= f.memoryItems {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("error marshaling frontier link: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := f.writer.buffer.Write(line); err != nil {
+		return fmt.Errorf("error writing frontier segment: %w", err)
+	}
+	f.writer.segment.items++
+	return nil
+}
+
+// rotate closes the segment being written and starts a new one.
+func (f *frontier) rotate() error {
+	if err := f.closeWriter(); err != nil {
+		return err
+	}
+	if f.dir == "" {
+		dir, err := os.MkdirTemp("", "crawler-frontier-")
+		if err != nil {
+			return fmt.Errorf("error creating frontier directory: %w", err)
+		}
+		f.dir = dir
+	}
+	f.created++
+	path := filepath.Join(f.dir, fmt.Sprintf("segment-%08d.jsonl", f.created))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating frontier segment: %w", err)
+	}
+	s := &segment{path: path}
+	f.segments = append(f.segments, s)
+	f.writer = &segmentWriter{segment: s, file: file, buffer: bufio.NewWriter(file)}
+	return nil
+}
+
+// closeWriter flushes and closes the segment being written, if any.
+func (f *frontier) closeWriter() error {
+	if f.writer == nil {
+		return nil
+	}
+	writer := f.writer
+	f.writer = nil
+	if err := writer.buffer.Flush(); err != nil {
+		writer.file.Close()
+		return fmt.Errorf("error writing frontier segment: %w", err)
+	}
+	if err := writer.file.Close(); err != nil {
+		return fmt.Errorf("error closing frontier segment: %w", err)
+	}
+	return nil
+}
+
+// load moves the links of the oldest segment to memory and removes it. A segment that cannot
+// be read is dropped along with its links.
+func (f *frontier) load() error {
+	oldest := f.segments[0]
+	f.segments = f.segments[1:]
+	items, err := f.read(oldest)
+	if err == nil {
+		err = os.Remove(oldest.path)
+	}
+	if err != nil {
+		f.count -= oldest.items
+		os.Remove(oldest.path)
+		return &SegmentError{Segment: filepath.Base(oldest.path), Items: oldest.items, Err: err}
+	}
+	f.head = items
+	return nil
+}
+
+// read returns the links of the segment, flushing them first when it is being written.
+func (f *frontier) read(s *segment) ([]models.CrawlItem, error) {
+	if f.writer != nil && f.writer.segment == s {
+		if err := f.closeWriter(); err != nil {
+			return nil, err
+		}
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.CrawlItem, 0, s.items)
+	for _, line := range bytes.Split(content, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		item := models.CrawlItem{}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return nil, fmt.Errorf("error decoding frontier link: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// removeSegments removes the segment files of the directory.
+func (f *frontier) removeSegments() error {
+	segments, err := filepath.Glob(filepath.Join(f.dir, segmentFilePattern))
+	if err != nil {
+		return fmt.Errorf("error listing frontier segments: %w", err)
+	}
+	for _, path := range segments {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing frontier segment: %w", err)
+		}
+	}
+	return nil
+}
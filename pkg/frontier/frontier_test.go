@@ -0,0 +1,175 @@
+package frontier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newItem(i int) models.CrawlItem {
+	return models.CrawlItem{URL: fmt.Sprintf("https://mock-site.com/page/%d", i), Depth: 1, Parent: "https://mock-site.com/", Tag: "a", Attr: "href"}
+}
+
+// segmentFiles returns the segments written to dir.
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, segmentFilePattern))
+	assert.Nil(t, err)
+	return files
+}
+
+func TestFrontierPastCapacity(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewFrontier(dir, 10)
+	assert.Nil(t, err)
+
+	// nothing pops the links, pushing does not wait for a consumer
+	total := 1005
+	for i := 0; i < total; i++ {
+		assert.Nil(t, queue.Push(newItem(i)))
+	}
+	assert.Equal(t, total, queue.Len())
+	assert.Len(t, queue.(*frontier).head, 10)
+	assert.Len(t, segmentFiles(t, dir), 100)
+
+	items, err := queue.Items()
+	assert.Nil(t, err)
+	assert.Len(t, items, total)
+	for i, item := range items {
+		assert.Equal(t, newItem(i), item)
+	}
+
+	for i := 0; i < total; i++ {
+		item, ok, err := queue.Pop()
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, newItem(i), item)
+		assert.LessOrEqual(t, len(queue.(*frontier).head), 10)
+	}
+	_, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, queue.Len())
+	assert.Empty(t, segmentFiles(t, dir))
+}
+
+func TestFrontierInterleaved(t *testing.T) {
+	queue, err := NewFrontier(t.TempDir(), 3)
+	assert.Nil(t, err)
+
+	// links pushed while older ones are on disk are popped after them
+	pushed, popped := 0, 0
+	for round := 0; round < 50; round++ {
+		for i := 0; i < round%7+1; i++ {
+			assert.Nil(t, queue.Push(newItem(pushed)))
+			pushed++
+		}
+		for i := 0; i < round%5; i++ {
+			item, ok, err := queue.Pop()
+			assert.Nil(t, err)
+			if !ok {
+				break
+			}
+			assert.Equal(t, newItem(popped), item)
+			popped++
+		}
+		assert.Equal(t, pushed-popped, queue.Len())
+	}
+	for {
+		item, ok, err := queue.Pop()
+		assert.Nil(t, err)
+		if !ok {
+			break
+		}
+		assert.Equal(t, newItem(popped), item)
+		popped++
+	}
+	assert.Equal(t, pushed, popped)
+}
+
+func TestFrontierReady(t *testing.T) {
+	queue, err := NewFrontier("", 1)
+	assert.Nil(t, err)
+	defer queue.Close()
+	select {
+	case <-queue.Ready():
+		t.Fatal("empty frontier signaled")
+	default:
+	}
+
+	assert.Nil(t, queue.Push(newItem(0)))
+	assert.Nil(t, queue.Push(newItem(1)))
+	<-queue.Ready()
+	select {
+	case <-queue.Ready():
+		t.Fatal("frontier signaled twice")
+	default:
+	}
+}
+
+func TestFrontierTemporaryDirectory(t *testing.T) {
+	queue, err := NewFrontier("", 1)
+	assert.Nil(t, err)
+	assert.Nil(t, queue.Push(newItem(0)))
+	assert.Equal(t, "", queue.(*frontier).dir)
+
+	assert.Nil(t, queue.Push(newItem(1)))
+	dir := queue.(*frontier).dir
+	assert.NotEmpty(t, dir)
+	assert.Len(t, segmentFiles(t, dir), 1)
+
+	assert.Nil(t, queue.Close())
+	_, err = os.Stat(dir)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestFrontierSegmentsOfPreviousCrawl(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewFrontier(dir, 1)
+	assert.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, queue.Push(newItem(i)))
+	}
+	assert.Len(t, segmentFiles(t, dir), 4)
+
+	queue, err = NewFrontier(dir, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, segmentFiles(t, dir))
+	_, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestFrontierLostSegment(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewFrontier(dir, 2)
+	assert.Nil(t, err)
+	for i := 0; i < 6; i++ {
+		assert.Nil(t, queue.Push(newItem(i)))
+	}
+	for i := 0; i < 2; i++ {
+		_, _, err := queue.Pop()
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, queue.(*frontier).closeWriter())
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "segment-00000001.jsonl"), []byte("not json\n"), 0644))
+
+	_, ok, err := queue.Pop()
+	assert.False(t, ok)
+	lost := &SegmentError{}
+	if assert.True(t, errors.As(err, &lost)) {
+		assert.Equal(t, 2, lost.Items)
+		assert.Equal(t, "segment-00000001.jsonl", lost.Segment)
+	}
+	assert.Equal(t, 2, queue.Len())
+
+	// the next segment is still read
+	item, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, newItem(4), item)
+}
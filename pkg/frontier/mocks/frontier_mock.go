@@ -0,0 +1,244 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: frontier.go
+
+// Package mock_frontier is a generated GoMock package.
+package mock_frontier
+
+import (
+	reflect "reflect"
+
+	models "github.com/csrar/crawler/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIFrontier is a mock of IFrontier interface.
+type MockIFrontier struct {
+	ctrl     *gomock.Controller
+	recorder *MockIFrontierMockRecorder
+}
+
+// MockIFrontierMockRecorder is the mock recorder for MockIFrontier.
+type MockIFrontierMockRecorder struct {
+	mock *MockIFrontier
+}
+
+// NewMockIFrontier creates a new mock instance.
+func NewMockIFrontier(ctrl *gomock.Controller) *MockIFrontier {
+	mock := &MockIFrontier{ctrl: ctrl}
+	mock.recorder = &MockIFrontierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIFrontier) EXPECT() *MockIFrontierMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockIFrontier) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockIFrontierMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockIFrontier)(nil).Close))
+}
+
+// Items mocks base method.
+func (m *MockIFrontier) Items() ([]models.CrawlItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Items")
+	ret0, _ := ret[0].([]models.CrawlItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Items indicates an expected call of Items.
+func (mr *MockIFrontierMockRecorder) Items() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Items", reflect.TypeOf((*MockIFrontier)(nil).Items))
+}
+
+// Len mocks base method.
+func (m *MockIFrontier) Len() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockIFrontierMockRecorder) Len() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockIFrontier)(nil).Len))
+}
+
+// Pop mocks base method.
+func (m *MockIFrontier) Pop() (models.CrawlItem, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pop")
+	ret0, _ := ret[0].(models.CrawlItem)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Pop indicates an expected call of Pop.
+func (mr *MockIFrontierMockRecorder) Pop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pop", reflect.TypeOf((*MockIFrontier)(nil).Pop))
+}
+
+// Push mocks base method.
+func (m *MockIFrontier) Push(item models.CrawlItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Push", item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Push indicates an expected call of Push.
+func (mr *MockIFrontierMockRecorder) Push(item interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockIFrontier)(nil).Push), item)
+}
+
+// Ready mocks base method.
+func (m *MockIFrontier) Ready() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockIFrontierMockRecorder) Ready() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockIFrontier)(nil).Ready))
+}
+
+// MockIShared is a mock of IShared interface.
+type MockIShared struct {
+	ctrl     *gomock.Controller
+	recorder *MockISharedMockRecorder
+}
+
+// MockISharedMockRecorder is the mock recorder for MockIShared.
+type MockISharedMockRecorder struct {
+	mock *MockIShared
+}
+
+// NewMockIShared creates a new mock instance.
+func NewMockIShared(ctrl *gomock.Controller) *MockIShared {
+	mock := &MockIShared{ctrl: ctrl}
+	mock.recorder = &MockISharedMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIShared) EXPECT() *MockISharedMockRecorder {
+	return m.recorder
+}
+
+// Done mocks base method.
+func (m *MockIShared) Done(item models.CrawlItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Done", item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Done indicates an expected call of Done.
+func (mr *MockISharedMockRecorder) Done(item interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*MockIShared)(nil).Done), item)
+}
+
+// Drained mocks base method.
+func (m *MockIShared) Drained() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Drained")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Drained indicates an expected call of Drained.
+func (mr *MockISharedMockRecorder) Drained() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drained", reflect.TypeOf((*MockIShared)(nil).Drained))
+}
+
+// Seed mocks base method.
+func (m *MockIShared) Seed(items ...models.CrawlItem) (bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range items {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Seed", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Seed indicates an expected call of Seed.
+func (mr *MockISharedMockRecorder) Seed(items ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seed", reflect.TypeOf((*MockIShared)(nil).Seed), items...)
+}
+
+// MockIBudget is a mock of IBudget interface.
+type MockIBudget struct {
+	ctrl     *gomock.Controller
+	recorder *MockIBudgetMockRecorder
+}
+
+// MockIBudgetMockRecorder is the mock recorder for MockIBudget.
+type MockIBudgetMockRecorder struct {
+	mock *MockIBudget
+}
+
+// NewMockIBudget creates a new mock instance.
+func NewMockIBudget(ctrl *gomock.Controller) *MockIBudget {
+	mock := &MockIBudget{ctrl: ctrl}
+	mock.recorder = &MockIBudgetMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIBudget) EXPECT() *MockIBudgetMockRecorder {
+	return m.recorder
+}
+
+// Reserve mocks base method.
+func (m *MockIBudget) Reserve() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockIBudgetMockRecorder) Reserve() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockIBudget)(nil).Reserve))
+}
+
+// Spent mocks base method.
+func (m *MockIBudget) Spent() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Spent")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Spent indicates an expected call of Spent.
+func (mr *MockIBudgetMockRecorder) Spent() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Spent", reflect.TypeOf((*MockIBudget)(nil).Spent))
+}
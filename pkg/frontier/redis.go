@@ -0,0 +1,339 @@
+package frontier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// popScript moves the oldest link to the processing list of the process and registers the process
+// with a fresh lease in the same step, so the link is never out of both lists.
+var popScript = redis.NewScript(`
+local item = redis.call('LMOVE', KEYS[1], KEYS[2], 'LEFT', 'RIGHT')
+if item then
+	redis.call('SADD', KEYS[3], ARGV[1])
+	redis.call('SET', KEYS[4], '1', 'PX', ARGV[2])
+end
+return item
+`)
+
+// requeueScript moves the links of the processing list of a process whose lease expired back to
+// the head of the frontier, in their order, and unregisters the process. It returns the number of
+// links moved.
+var requeueScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local moved = 0
+while redis.call('LMOVE', KEYS[2], KEYS[3], 'RIGHT', 'LEFT') do
+	moved = moved + 1
+end
+redis.call('SREM', KEYS[4], ARGV[1])
+return moved
+`)
+
+// drainedScript reports 1 when the frontier is empty and no registered process has a link left to
+// settle in its processing list.
+var drainedScript = redis.NewScript(`
+if redis.call('LLEN', KEYS[1]) > 0 then
+	return 0
+end
+for _, id in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	if redis.call('LLEN', ARGV[1] .. id) > 0 then
+		return 0
+	end
+end
+return 1
+`)
+
+// seedScript pushes the seeds when the frontier was never seeded, reporting 1 then.
+var seedScript = redis.NewScript(`
+if redis.call('SETNX', KEYS[1], '1') == 0 then
+	return 0
+end
+for _, item in ipairs(ARGV) do
+	redis.call('RPUSH', KEYS[2], item)
+end
+return 1
+`)
+
+// redisFrontier is a FIFO queue of links kept in a Redis list, so several crawler processes
+// pointed at the same Redis and key prefix split its links. Popping a link atomically moves it to
+// the processing list of the process, so it is handed to only one of them and kept there until the
+// process settles it. Every process holds a lease it renews every pollInterval, the links of a
+// process whose lease expired, such as a crashed one, are queued again by the other processes.
+// The links pushed and settled by the other processes are noticed by polling the frontier every
+// pollInterval.
+type redisFrontier struct {
+	client     *redis.Client
+	id         string
+	key        string
+	processing string
+	processes  string
+	lease      string
+	seeded     string
+	prefix     string
+	leaseTime  time.Duration
+	ready      chan struct{}
+	stop       chan struct{}
+	stopped    chan struct{}
+	once       sync.Once
+}
+
+const (
+	// defaultPollInterval is used when the poll interval is not positive
+	defaultPollInterval = 200 * time.Millisecond
+	// defaultLeaseTime is used when the lease time is not positive
+	defaultLeaseTime = 30 * time.Second
+	// leaseRenewals is the minimum number of times a lease is renewed before it expires
+	leaseRenewals = 3
+)
+
+// NewRedisFrontier creates a frontier keeping its links under prefix in the Redis of client,
+// closing the client when the frontier is closed. The links popped by the process are queued again
+// by the other processes when it does not renew its lease for leaseTime, which is kept to at least
+// a few poll intervals.
+func NewRedisFrontier(client *redis.Client, prefix string, pollInterval time.Duration, leaseTime time.Duration) IFrontier {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	if leaseTime <= 0 {
+		leaseTime = defaultLeaseTime
+	}
+	if leaseTime < leaseRenewals*pollInterval {
+		leaseTime = leaseRenewals * pollInterval
+	}
+	id := processID()
+	f := &redisFrontier{
+		client:     client,
+		id:         id,
+		key:        prefix + ":frontier",
+		processing: prefix + ":frontier:processing:" + id,
+		processes:  prefix + ":frontier:processes",
+		lease:      prefix + ":frontier:lease:" + id,
+		seeded:     prefix + ":frontier:seeded",
+		prefix:     prefix,
+		leaseTime:  leaseTime,
+		ready:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go f.poll(pollInterval)
+	return f
+}
+
+// processID returns a random identifier of the process, unique among the processes sharing the
+// frontier.
+func processID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Push adds the link to the end of the frontier.
+func (f *redisFrontier) Push(item models.CrawlItem) error {
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("error marshaling frontier link: %w", err)
+	}
+	if err := f.client.RPush(context.Background(), f.key, payload).Err(); err != nil {
+		return fmt.Errorf("error pushing frontier link: %w", err)
+	}
+	f.signal()
+	return nil
+}
+
+// Pop removes and returns the oldest link, reporting false when the frontier is empty. The link is
+// kept in the processing list of the process until it is settled with Done.
+func (f *redisFrontier) Pop() (models.CrawlItem, bool, error) {
+	payload, err := popScript.Run(context.Background(), f.client, []string{f.key, f.processing, f.processes, f.lease},
+		f.id, f.leaseTime.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return models.CrawlItem{}, false, nil
+	}
+	if err != nil {
+		return models.CrawlItem{}, false, fmt.Errorf("error popping frontier link: %w", err)
+	}
+	item := models.CrawlItem{}
+	if err := json.Unmarshal([]byte(payload), &item); err != nil {
+		// the link is gone, it is settled right away
+		if doneErr := f.settle(payload); doneErr != nil {
+			return models.CrawlItem{}, false, doneErr
+		}
+		return models.CrawlItem{}, false, fmt.Errorf("error decoding frontier link, it is lost: %w", err)
+	}
+	return item, true, nil
+}
+
+// Ready returns a channel signaled after links are pushed by this process and every poll interval,
+// so consumers notice the links pushed and settled by the other processes and retry when Redis
+// cannot be read.
+func (f *redisFrontier) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Len returns the number of links in the frontier, 0 when it cannot be read.
+func (f *redisFrontier) Len() int {
+	length, err := f.client.LLen(context.Background(), f.key).Result()
+	if err != nil {
+		return 0
+	}
+	return int(length)
+}
+
+// Items returns every link of the frontier from the oldest to the newest without removing them.
+func (f *redisFrontier) Items() ([]models.CrawlItem, error) {
+	payloads, err := f.client.LRange(context.Background(), f.key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading frontier links: %w", err)
+	}
+	items := make([]models.CrawlItem, 0, len(payloads))
+	for _, payload := range payloads {
+		item := models.CrawlItem{}
+		if err := json.Unmarshal([]byte(payload), &item); err != nil {
+			return nil, fmt.Errorf("error decoding frontier link: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Seed pushes the links the crawl starts from unless a process seeded the frontier already,
+// reporting whether they were pushed.
+func (f *redisFrontier) Seed(items ...models.CrawlItem) (bool, error) {
+	payloads := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		payload, err := json.Marshal(item)
+		if err != nil {
+			return false, fmt.Errorf("error marshaling frontier link: %w", err)
+		}
+		payloads = append(payloads, payload)
+	}
+	seeded, err := seedScript.Run(context.Background(), f.client, []string{f.seeded, f.key}, payloads...).Int()
+	if err != nil {
+		return false, fmt.Errorf("error seeding frontier: %w", err)
+	}
+	if seeded == 1 {
+		f.signal()
+	}
+	return seeded == 1, nil
+}
+
+// Done settles a link popped by the process, after the links found on it were pushed. A link
+// queued again after the lease of the process expired is left to the process that pops it.
+func (f *redisFrontier) Done(item models.CrawlItem) error {
+	// a link is marshaled the same way it was pushed, so it matches its payload
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("error marshaling frontier link: %w", err)
+	}
+	return f.settle(string(payload))
+}
+
+// settle removes the payload of a popped link from the processing list.
+func (f *redisFrontier) settle(payload string) error {
+	if err := f.client.LRem(context.Background(), f.processing, 1, payload).Err(); err != nil {
+		return fmt.Errorf("error settling frontier link: %w", err)
+	}
+	return nil
+}
+
+// Drained reports whether the frontier is empty and every link popped by any process was settled,
+// no more links can be pushed then.
+func (f *redisFrontier) Drained() (bool, error) {
+	drained, err := drainedScript.Run(context.Background(), f.client, []string{f.key, f.processes},
+		f.prefix+":frontier:processing:").Int()
+	if err != nil {
+		return false, fmt.Errorf("error reading frontier state: %w", err)
+	}
+	return drained == 1, nil
+}
+
+// Close stops polling, queues the links the process did not settle again and closes the client,
+// the links are left in Redis for the other processes.
+func (f *redisFrontier) Close() error {
+	f.once.Do(func() {
+		close(f.stop)
+	})
+	<-f.stopped
+	err := f.client.Del(context.Background(), f.lease).Err()
+	if err == nil {
+		err = f.requeue(f.id)
+	}
+	if err != nil {
+		f.client.Close()
+		return fmt.Errorf("error handing back the frontier links: %w", err)
+	}
+	return f.client.Close()
+}
+
+// poll renews the lease of the process, queues the links of the processes whose lease expired
+// again and signals the frontier as ready every interval until it is closed.
+func (f *redisFrontier) poll(interval time.Duration) {
+	defer close(f.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			// Redis may not be reachable, the lease is renewed on the next tick or by the next pop
+			_ = f.renew()
+			f.signal()
+		}
+	}
+}
+
+// renew extends the lease of the process and queues the links of the expired processes again.
+func (f *redisFrontier) renew() error {
+	ctx := context.Background()
+	if err := f.client.Set(ctx, f.lease, "1", f.leaseTime).Err(); err != nil {
+		return err
+	}
+	ids, err := f.client.SMembers(ctx, f.processes).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == f.id {
+			continue
+		}
+		if err := f.requeue(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requeue moves the links of the process back to the frontier when its lease is gone.
+func (f *redisFrontier) requeue(id string) error {
+	keys := []string{f.prefix + ":frontier:lease:" + id, f.prefix + ":frontier:processing:" + id, f.key, f.processes}
+	moved, err := requeueScript.Run(context.Background(), f.client, keys, id).Int()
+	if err != nil {
+		return err
+	}
+	if moved > 0 {
+		f.signal()
+	}
+	return nil
+}
+
+// signal wakes the consumer waiting on Ready, a pending signal is enough.
+func (f *redisFrontier) signal() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
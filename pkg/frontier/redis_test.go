@@ -0,0 +1,219 @@
+package frontier
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/csrar/crawler/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedisQueue(server *miniredis.Miniredis, pollInterval time.Duration) IFrontier {
+	return NewRedisFrontier(redis.NewClient(&redis.Options{Addr: server.Addr()}), "crawler", pollInterval, 0)
+}
+
+func TestRedisFrontier(t *testing.T) {
+	server := miniredis.RunT(t)
+	queue := newRedisQueue(server, time.Second)
+	defer queue.Close()
+
+	total := 25
+	for i := 0; i < total; i++ {
+		assert.Nil(t, queue.Push(newItem(i)))
+	}
+	assert.Equal(t, total, queue.Len())
+
+	items, err := queue.Items()
+	assert.Nil(t, err)
+	assert.Len(t, items, total)
+	for i, item := range items {
+		assert.Equal(t, newItem(i), item)
+	}
+
+	for i := 0; i < total; i++ {
+		item, ok, err := queue.Pop()
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, newItem(i), item)
+	}
+	_, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, queue.Len())
+}
+
+func TestRedisFrontierDrained(t *testing.T) {
+	server := miniredis.RunT(t)
+	queue := newRedisQueue(server, time.Second)
+	defer queue.Close()
+	shared := queue.(IShared)
+
+	assertDrained := func(expected bool) {
+		drained, err := shared.Drained()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, drained)
+	}
+	assertDrained(true)
+	assert.Nil(t, queue.Push(newItem(0)))
+	assertDrained(false)
+
+	// the popped link may still queue more links until it is settled
+	item, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assertDrained(false)
+	assert.Nil(t, queue.Push(newItem(1)))
+	assert.Nil(t, shared.Done(item))
+	assertDrained(false)
+
+	item, ok, err = queue.Pop()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, shared.Done(item))
+	assertDrained(true)
+}
+
+func TestRedisFrontierShared(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newRedisQueue(server, 10*time.Millisecond)
+	defer first.Close()
+	second := newRedisQueue(server, 10*time.Millisecond)
+	defer second.Close()
+
+	// the links pushed by a process are noticed by the other one polling the list
+	assert.Nil(t, first.Push(newItem(0)))
+	select {
+	case <-second.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("the frontier was not signaled as ready")
+	}
+
+	// every link is popped by exactly one of the consumers
+	total := 200
+	for i := 1; i < total; i++ {
+		assert.Nil(t, second.Push(newItem(i)))
+	}
+	popped := make([]map[string]bool, 2)
+	var wg sync.WaitGroup
+	for c, queue := range []IFrontier{first, second} {
+		popped[c] = map[string]bool{}
+		wg.Add(1)
+		go func(queue IFrontier, popped map[string]bool) {
+			defer wg.Done()
+			for item, ok, err := queue.Pop(); ok; item, ok, err = queue.Pop() {
+				assert.Nil(t, err)
+				popped[item.URL] = true
+			}
+		}(queue, popped[c])
+	}
+	wg.Wait()
+	assert.Equal(t, total, len(popped[0])+len(popped[1]))
+	for i := 0; i < total; i++ {
+		assert.True(t, popped[0][newItem(i).URL] != popped[1][newItem(i).URL], newItem(i).URL)
+	}
+}
+
+func TestRedisFrontierErrors(t *testing.T) {
+	server := miniredis.RunT(t)
+	queue := newRedisQueue(server, 10*time.Millisecond)
+	defer queue.Close()
+
+	_, err := server.Lpush("crawler:frontier", "not a link")
+	assert.Nil(t, err)
+	_, ok, err := queue.Pop()
+	assert.False(t, ok)
+	assert.EqualError(t, err, "error decoding frontier link, it is lost: invalid character 'o' in literal null (expecting 'u')")
+	// the lost link is settled
+	drained, err := queue.(IShared).Drained()
+	assert.Nil(t, err)
+	assert.True(t, drained)
+
+	// consumers are woken to retry while the list cannot be read
+	server.Close()
+	_, _, err = queue.Pop()
+	assert.NotNil(t, err)
+	select {
+	case <-queue.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("the frontier was not signaled as ready")
+	}
+}
+
+func TestRedisFrontierSeed(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newRedisQueue(server, time.Second)
+	defer first.Close()
+	second := newRedisQueue(server, time.Second)
+	defer second.Close()
+
+	// only the first process pushes its seeds
+	seeded, err := first.(IShared).Seed(newItem(0), newItem(1))
+	assert.Nil(t, err)
+	assert.True(t, seeded)
+	seeded, err = second.(IShared).Seed(newItem(0))
+	assert.Nil(t, err)
+	assert.False(t, seeded)
+
+	items, err := second.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.CrawlItem{newItem(0), newItem(1)}, items)
+}
+
+func TestRedisFrontierLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	crashed := NewRedisFrontier(redis.NewClient(&redis.Options{Addr: server.Addr()}), "crawler", 10*time.Millisecond, time.Second).(*redisFrontier)
+	defer crashed.client.Close()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, crashed.Push(newItem(i)))
+	}
+	first, _, err := crashed.Pop()
+	assert.Nil(t, err)
+	assert.Nil(t, crashed.Done(first))
+	for i := 1; i < 3; i++ {
+		_, ok, err := crashed.Pop()
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+	// the process stops renewing its lease without handing its links back
+	close(crashed.stop)
+	<-crashed.stopped
+
+	queue := newRedisQueue(server, 10*time.Millisecond)
+	defer queue.Close()
+	drained, err := queue.(IShared).Drained()
+	assert.Nil(t, err)
+	assert.False(t, drained)
+	// the links stay with the process while its lease holds
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, queue.Len())
+
+	server.FastForward(time.Second)
+	assert.Eventually(t, func() bool { return queue.Len() == 2 }, time.Second, 10*time.Millisecond)
+	items, err := queue.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.CrawlItem{newItem(1), newItem(2)}, items)
+	assert.False(t, server.Exists("crawler:frontier:processing:"+crashed.id))
+}
+
+func TestRedisFrontierClose(t *testing.T) {
+	server := miniredis.RunT(t)
+	queue := newRedisQueue(server, time.Second)
+	assert.Nil(t, queue.Push(newItem(0)))
+	_, ok, err := queue.Pop()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// the links not settled are handed back on close
+	assert.Nil(t, queue.Close())
+	other := newRedisQueue(server, time.Second)
+	defer other.Close()
+	items, err := other.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.CrawlItem{newItem(0)}, items)
+	drained, err := other.(IShared).Drained()
+	assert.Nil(t, err)
+	assert.False(t, drained)
+}
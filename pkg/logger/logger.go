@@ -36,3 +36,15 @@ func (l *lLogger) Warn(message string) {
 func (l *lLogger) Error(err error) {
 	l.Log.Error(err)
 }
+
+// nopLogger discards every message.
+type nopLogger struct{}
+
+// NewNopLogger creates a logger discarding every message.
+func NewNopLogger() Ilogger {
+	return nopLogger{}
+}
+
+func (nopLogger) Info(message string) {}
+func (nopLogger) Warn(message string) {}
+func (nopLogger) Error(err error)     {}
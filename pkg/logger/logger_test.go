@@ -41,3 +41,11 @@ func TestLoggerError(t *testing.T) {
 	logger := NewLogrusLogger()
 	logger.Error(errors.New("mock-error"))
 }
+
+func TestNopLogger(t *testing.T) {
+	logger := NewNopLogger()
+	assert.NotNil(t, logger)
+	logger.Info("mock-info")
+	logger.Warn("mock-warn")
+	logger.Error(errors.New("mock-error"))
+}
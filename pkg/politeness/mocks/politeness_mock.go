@@ -0,0 +1,58 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: politeness.go
+
+// Package mock_politeness is a generated GoMock package.
+package mock_politeness
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIScheduler is a mock of IScheduler interface.
+type MockIScheduler struct {
+	ctrl     *gomock.Controller
+	recorder *MockISchedulerMockRecorder
+}
+
+// MockISchedulerMockRecorder is the mock recorder for MockIScheduler.
+type MockISchedulerMockRecorder struct {
+	mock *MockIScheduler
+}
+
+// NewMockIScheduler creates a new mock instance.
+func NewMockIScheduler(ctrl *gomock.Controller) *MockIScheduler {
+	mock := &MockIScheduler{ctrl: ctrl}
+	mock.recorder = &MockISchedulerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIScheduler) EXPECT() *MockISchedulerMockRecorder {
+	return m.recorder
+}
+
+// Acquire mocks base method.
+func (m *MockIScheduler) Acquire(host string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Acquire", host)
+}
+
+// Acquire indicates an expected call of Acquire.
+func (mr *MockISchedulerMockRecorder) Acquire(host interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockIScheduler)(nil).Acquire), host)
+}
+
+// Release mocks base method.
+func (m *MockIScheduler) Release(host string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Release", host)
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockISchedulerMockRecorder) Release(host interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIScheduler)(nil).Release), host)
+}
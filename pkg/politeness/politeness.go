@@ -0,0 +1,87 @@
+package politeness
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//go:generate mockgen -source=politeness.go -destination=mocks/politeness_mock.go
+type IScheduler interface {
+	Acquire(ctx context.Context, host string) error
+	Release(host string)
+}
+
+type hostSlot struct {
+	active    chan struct{}
+	nextStart time.Time
+}
+
+type scheduler struct {
+	delay         time.Duration
+	maxConcurrent int
+	hosts         map[string]*hostSlot
+	mu            sync.Mutex
+}
+
+// NewScheduler creates a scheduler that spaces requests to the same host by delay
+// and allows at most maxConcurrent requests in flight per host.
+func NewScheduler(delay time.Duration, maxConcurrent int) IScheduler {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &scheduler{
+		delay:         delay,
+		maxConcurrent: maxConcurrent,
+		hosts:         map[string]*hostSlot{},
+	}
+}
+
+// Acquire blocks until a request to the host is allowed to start or the context is done.
+func (s *scheduler) Acquire(ctx context.Context, host string) error {
+	slot := s.slot(host)
+	select {
+	case slot.active <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	start := slot.nextStart
+	if start.Before(now) {
+		start = now
+	}
+	// reserve the start time so concurrent callers line up one delay apart
+	slot.nextStart = start.Add(s.delay)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		<-slot.active
+		return ctx.Err()
+	}
+}
+
+// Release frees the request slot taken by Acquire for the host.
+func (s *scheduler) Release(host string) {
+	<-s.slot(host).active
+}
+
+// slot returns the state of the host, creating it on first use.
+func (s *scheduler) slot(host string) *hostSlot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot, ok := s.hosts[host]
+	if !ok {
+		slot = &hostSlot{
+			active: make(chan struct{}, s.maxConcurrent),
+		}
+		s.hosts[host] = slot
+	}
+	return slot
+}
@@ -0,0 +1,141 @@
+package politeness
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireDelay(t *testing.T) {
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		hosts       []string
+		minDuration time.Duration
+		maxDuration time.Duration
+	}{
+		{
+			name:        "same host requests are spaced by the delay",
+			delay:       50 * time.Millisecond,
+			hosts:       []string{"example.com", "example.com", "example.com"},
+			minDuration: 100 * time.Millisecond,
+			maxDuration: time.Second,
+		},
+		{
+			name:        "different hosts do not wait for each other",
+			delay:       time.Second,
+			hosts:       []string{"example.com", "example.org", "example.net"},
+			minDuration: 0,
+			maxDuration: 500 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := NewScheduler(tc.delay, 10)
+			start := time.Now()
+			for _, host := range tc.hosts {
+				assert.Nil(t, scheduler.Acquire(context.Background(), host))
+				scheduler.Release(host)
+			}
+			elapsed := time.Since(start)
+			assert.GreaterOrEqual(t, elapsed, tc.minDuration)
+			assert.Less(t, elapsed, tc.maxDuration)
+		})
+	}
+}
+
+func TestAcquireMaxConcurrent(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		requests      int
+	}{
+		{
+			name:          "single request per host",
+			maxConcurrent: 1,
+			requests:      10,
+		},
+		{
+			name:          "three requests per host",
+			maxConcurrent: 3,
+			requests:      10,
+		},
+		{
+			name:          "invalid limit falls back to one",
+			maxConcurrent: 0,
+			requests:      5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := NewScheduler(0, tc.maxConcurrent)
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			active, peak := 0, 0
+
+			for i := 0; i < tc.requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.Nil(t, scheduler.Acquire(context.Background(), "example.com"))
+					defer scheduler.Release("example.com")
+
+					mu.Lock()
+					active++
+					if active > peak {
+						peak = active
+					}
+					mu.Unlock()
+
+					time.Sleep(5 * time.Millisecond)
+
+					mu.Lock()
+					active--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			expected := tc.maxConcurrent
+			if expected < 1 {
+				expected = 1
+			}
+			assert.LessOrEqual(t, peak, expected)
+		})
+	}
+}
+
+func TestAcquireCancelled(t *testing.T) {
+	tests := []struct {
+		name          string
+		delay         time.Duration
+		maxConcurrent int
+	}{
+		{
+			name:          "cancelled while waiting for the delay",
+			delay:         time.Minute,
+			maxConcurrent: 2,
+		},
+		{
+			name:          "cancelled while waiting for a free slot",
+			delay:         0,
+			maxConcurrent: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := NewScheduler(tc.delay, tc.maxConcurrent)
+			assert.Nil(t, scheduler.Acquire(context.Background(), "example.com"))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			assert.Equal(t, context.DeadlineExceeded, scheduler.Acquire(ctx, "example.com"))
+		})
+	}
+}
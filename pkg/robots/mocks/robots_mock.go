@@ -0,0 +1,120 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: robots.go
+
+// Package mock_robots is a generated GoMock package.
+package mock_robots
+
+import (
+	url "net/url"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIRobots is a mock of IRobots interface.
+type MockIRobots struct {
+	ctrl     *gomock.Controller
+	recorder *MockIRobotsMockRecorder
+}
+
+// MockIRobotsMockRecorder is the mock recorder for MockIRobots.
+type MockIRobotsMockRecorder struct {
+	mock *MockIRobots
+}
+
+// NewMockIRobots creates a new mock instance.
+func NewMockIRobots(ctrl *gomock.Controller) *MockIRobots {
+	mock := &MockIRobots{ctrl: ctrl}
+	mock.recorder = &MockIRobotsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIRobots) EXPECT() *MockIRobotsMockRecorder {
+	return m.recorder
+}
+
+// AssetsBlocked mocks base method.
+func (m *MockIRobots) AssetsBlocked() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssetsBlocked")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// AssetsBlocked indicates an expected call of AssetsBlocked.
+func (mr *MockIRobotsMockRecorder) AssetsBlocked() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssetsBlocked", reflect.TypeOf((*MockIRobots)(nil).AssetsBlocked))
+}
+
+// Blocked mocks base method.
+func (m *MockIRobots) Blocked() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Blocked")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Blocked indicates an expected call of Blocked.
+func (mr *MockIRobotsMockRecorder) Blocked() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Blocked", reflect.TypeOf((*MockIRobots)(nil).Blocked))
+}
+
+// CrawlDelay mocks base method.
+func (m *MockIRobots) CrawlDelay() time.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CrawlDelay")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// CrawlDelay indicates an expected call of CrawlDelay.
+func (mr *MockIRobotsMockRecorder) CrawlDelay() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CrawlDelay", reflect.TypeOf((*MockIRobots)(nil).CrawlDelay))
+}
+
+// IsAllowed mocks base method.
+func (m *MockIRobots) IsAllowed(link *url.URL) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAllowed", link)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAllowed indicates an expected call of IsAllowed.
+func (mr *MockIRobotsMockRecorder) IsAllowed(link interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAllowed", reflect.TypeOf((*MockIRobots)(nil).IsAllowed), link)
+}
+
+// IsAssetAllowed mocks base method.
+func (m *MockIRobots) IsAssetAllowed(asset *url.URL) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAssetAllowed", asset)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAssetAllowed indicates an expected call of IsAssetAllowed.
+func (mr *MockIRobotsMockRecorder) IsAssetAllowed(asset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAssetAllowed", reflect.TypeOf((*MockIRobots)(nil).IsAssetAllowed), asset)
+}
+
+// Sitemaps mocks base method.
+func (m *MockIRobots) Sitemaps() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sitemaps")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Sitemaps indicates an expected call of Sitemaps.
+func (mr *MockIRobotsMockRecorder) Sitemaps() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sitemaps", reflect.TypeOf((*MockIRobots)(nil).Sitemaps))
+}
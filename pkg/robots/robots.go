@@ -0,0 +1,292 @@
+package robots
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRobotsSize is the maximum number of bytes read from a robots.txt file.
+const maxRobotsSize = 500 * 1024
+
+//go:generate mockgen -source=robots.go -destination=mocks/robots_mock.go
+type IRobots interface {
+	IsAllowed(link *url.URL) bool
+	IsAssetAllowed(asset *url.URL) bool
+	CrawlDelay() time.Duration
+	Sitemaps() []string
+	Blocked() int
+	AssetsBlocked() int
+}
+
+type rule struct {
+	pattern string
+	allow   bool
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type robots struct {
+	rules      []rule
+	crawlDelay time.Duration
+	sitemaps   []string
+	blocked    int
+	// assetsBlocked counts the assets rejected apart from the pages
+	assetsBlocked int
+	mu            sync.Mutex
+}
+
+// NewAllowAll returns rules that allow every link, used when a site has no robots.txt.
+func NewAllowAll() IRobots {
+	return &robots{}
+}
+
+// NewDisallowAll returns rules that block every link, used when robots.txt is unreachable.
+func NewDisallowAll() IRobots {
+	return &robots{
+		rules: []rule{{pattern: "/", allow: false}},
+	}
+}
+
+// Fetch downloads with the client and parses the robots.txt file of the host of the root page.
+// Following RFC 9309 a missing file (4xx) allows every link and an unreachable one (5xx or a
+// network error) blocks them all, the error is returned along with the rules to be reported.
+func Fetch(ctx context.Context, client *http.Client, root *url.URL, userAgent string) (IRobots, error) {
+	robotsURL := url.URL{Scheme: root.Scheme, Host: root.Host, Path: "/robots.txt"}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return NewDisallowAll(), fmt.Errorf("error building robots.txt request, every link is disallowed: %v", err)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return NewDisallowAll(), fmt.Errorf("error fetching robots.txt, every link is disallowed: %v", err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= http.StatusInternalServerError:
+		return NewDisallowAll(), nil
+	case response.StatusCode >= http.StatusBadRequest:
+		return NewAllowAll(), nil
+	}
+	return Parse(io.LimitReader(response.Body, maxRobotsSize), userAgent), nil
+}
+
+// Parse reads a robots.txt file and keeps the group of rules that matches the user agent.
+func Parse(reader io.Reader, userAgent string) IRobots {
+	var groups []*group
+	var current *group
+	sitemaps := []string{}
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		key, value, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// an empty disallow means everything is allowed, so it adds no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && current != nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+		lastWasAgent = false
+	}
+
+	result := &robots{sitemaps: sitemaps}
+	for _, matched := range matchGroups(groups, userAgent) {
+		result.rules = append(result.rules, matched.rules...)
+		if matched.crawlDelay > result.crawlDelay {
+			result.crawlDelay = matched.crawlDelay
+		}
+	}
+	return result
+}
+
+// IsAllowed reports whether the link may be crawled, links that are not allowed are counted as blocked.
+func (r *robots) IsAllowed(link *url.URL) bool {
+	allowed := r.allows(link)
+	if !allowed {
+		r.mu.Lock()
+		r.blocked++
+		r.mu.Unlock()
+	}
+	return allowed
+}
+
+// IsAssetAllowed reports whether the asset may be requested, the assets that are not allowed are
+// counted apart from the links.
+func (r *robots) IsAssetAllowed(asset *url.URL) bool {
+	allowed := r.allows(asset)
+	if !allowed {
+		r.mu.Lock()
+		r.assetsBlocked++
+		r.mu.Unlock()
+	}
+	return allowed
+}
+
+// allows matches the path of the link against the rules.
+func (r *robots) allows(link *url.URL) bool {
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if link.RawQuery != "" {
+		path += "?" + link.RawQuery
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !matchPattern(rule.pattern, path) {
+			continue
+		}
+		// the most specific rule wins, allow wins when both have the same length
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the delay requested between consecutive requests.
+func (r *robots) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
+
+// Sitemaps returns the sitemap URLs listed in the robots.txt file.
+func (r *robots) Sitemaps() []string {
+	return r.sitemaps
+}
+
+// Blocked returns the number of links rejected by the rules.
+func (r *robots) Blocked() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.blocked
+}
+
+// AssetsBlocked returns the number of assets rejected by the rules.
+func (r *robots) AssetsBlocked() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.assetsBlocked
+}
+
+// parseLine splits a robots.txt line into a lowercase key and its value, ignoring comments.
+func parseLine(line string) (string, string, bool) {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		line = line[:idx]
+	}
+	key, value, found := strings.Cut(line, ":")
+	if !found {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), true
+}
+
+// matchGroups returns the groups for the most specific user agent, falling back to the "*" groups.
+func matchGroups(groups []*group, userAgent string) []*group {
+	token := strings.ToLower(userAgent)
+	if idx := strings.Index(token, "/"); idx >= 0 {
+		token = token[:idx]
+	}
+
+	var specific, wildcard []*group
+	longest := 0
+	for _, g := range groups {
+		score := agentScore(g.agents, token)
+		switch {
+		case score == 0:
+			wildcard = append(wildcard, g)
+		case score > longest:
+			longest = score
+			specific = []*group{g}
+		case score > 0 && score == longest:
+			specific = append(specific, g)
+		}
+	}
+	if len(specific) > 0 {
+		return specific
+	}
+	return wildcard
+}
+
+// agentScore returns the length of the longest agent matching the token, 0 for "*" and -1 for no match.
+func agentScore(agents []string, token string) int {
+	score := -1
+	for _, agent := range agents {
+		switch {
+		case token != "" && agent != "*" && strings.Contains(token, agent):
+			if len(agent) > score {
+				score = len(agent)
+			}
+		case agent == "*" && score < 0:
+			score = 0
+		}
+	}
+	return score
+}
+
+// matchPattern reports whether path matches a robots.txt pattern supporting "*" and a trailing "$".
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
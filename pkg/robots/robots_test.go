@@ -0,0 +1,260 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mockRobots = `# robots.txt for mock site
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search?*q=
+Crawl-delay: 2
+
+User-agent: crawler
+User-agent: other-bot
+Disallow: /admin
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestIsAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		robots    string
+		userAgent string
+		link      string
+		expected  bool
+	}{
+		{
+			name:      "wildcard group disallowed path",
+			robots:    mockRobots,
+			userAgent: "unknown-bot",
+			link:      "https://example.com/private/data",
+			expected:  false,
+		},
+		{
+			name:      "wildcard group longest allow wins",
+			robots:    mockRobots,
+			userAgent: "unknown-bot",
+			link:      "https://example.com/private/public/page",
+			expected:  true,
+		},
+		{
+			name:      "wildcard group end anchored pattern",
+			robots:    mockRobots,
+			userAgent: "unknown-bot",
+			link:      "https://example.com/files/report.pdf",
+			expected:  false,
+		},
+		{
+			name:      "wildcard group end anchored pattern not matching",
+			robots:    mockRobots,
+			userAgent: "unknown-bot",
+			link:      "https://example.com/files/report.pdf.html",
+			expected:  true,
+		},
+		{
+			name:      "wildcard group pattern with query",
+			robots:    mockRobots,
+			userAgent: "unknown-bot",
+			link:      "https://example.com/search?page=1&q=go",
+			expected:  false,
+		},
+		{
+			name:      "specific group ignores wildcard rules",
+			robots:    mockRobots,
+			userAgent: "crawler/1.0",
+			link:      "https://example.com/private/data",
+			expected:  true,
+		},
+		{
+			name:      "specific group disallowed path",
+			robots:    mockRobots,
+			userAgent: "Crawler",
+			link:      "https://example.com/admin/users",
+			expected:  false,
+		},
+		{
+			name:      "robots file is always allowed",
+			robots:    "User-agent: *\nDisallow: /",
+			userAgent: "crawler",
+			link:      "https://example.com/robots.txt",
+			expected:  true,
+		},
+		{
+			name:      "empty disallow allows everything",
+			robots:    "User-agent: *\nDisallow:",
+			userAgent: "crawler",
+			link:      "https://example.com/anything",
+			expected:  true,
+		},
+		{
+			name:      "allow wins on equal length rules",
+			robots:    "User-agent: *\nDisallow: /page\nAllow: /page",
+			userAgent: "crawler",
+			link:      "https://example.com/page",
+			expected:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			link, err := url.Parse(tc.link)
+			assert.Nil(t, err)
+
+			rules := Parse(strings.NewReader(tc.robots), tc.userAgent)
+			assert.Equal(t, tc.expected, rules.IsAllowed(link))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name               string
+		robots             string
+		userAgent          string
+		expectedCrawlDelay time.Duration
+		expectedSitemaps   []string
+	}{
+		{
+			name:               "wildcard group crawl delay",
+			robots:             mockRobots,
+			userAgent:          "unknown-bot",
+			expectedCrawlDelay: 2 * time.Second,
+			expectedSitemaps:   []string{"https://example.com/sitemap.xml"},
+		},
+		{
+			name:               "specific group crawl delay",
+			robots:             mockRobots,
+			userAgent:          "other-bot",
+			expectedCrawlDelay: 500 * time.Millisecond,
+			expectedSitemaps:   []string{"https://example.com/sitemap.xml"},
+		},
+		{
+			name:               "invalid crawl delay is ignored",
+			robots:             "User-agent: *\nCrawl-delay: soon",
+			userAgent:          "crawler",
+			expectedCrawlDelay: 0,
+			expectedSitemaps:   []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := Parse(strings.NewReader(tc.robots), tc.userAgent)
+			assert.Equal(t, tc.expectedCrawlDelay, rules.CrawlDelay())
+			assert.Equal(t, tc.expectedSitemaps, rules.Sitemaps())
+		})
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	rules := Parse(strings.NewReader("User-agent: *\nDisallow: /private"), "crawler")
+	for _, link := range []string{"/private/a", "/public", "/private/b"} {
+		rules.IsAllowed(&url.URL{Path: link})
+	}
+	assert.Equal(t, 2, rules.Blocked())
+
+	// the blocked assets are counted apart from the links
+	assert.False(t, rules.IsAssetAllowed(&url.URL{Path: "/private/logo.png"}))
+	assert.True(t, rules.IsAssetAllowed(&url.URL{Path: "/public/logo.png"}))
+	assert.Equal(t, 2, rules.Blocked())
+	assert.Equal(t, 1, rules.AssetsBlocked())
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		body            string
+		expectedAllowed bool
+	}{
+		{
+			name:            "robots file found",
+			status:          http.StatusOK,
+			body:            "User-agent: *\nDisallow: /private",
+			expectedAllowed: false,
+		},
+		{
+			name:            "robots file not found allows everything",
+			status:          http.StatusNotFound,
+			expectedAllowed: true,
+		},
+		{
+			name:            "server error disallows everything",
+			status:          http.StatusServiceUnavailable,
+			expectedAllowed: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/robots.txt", r.URL.Path)
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer testServer.Close()
+
+			root, _ := url.Parse(testServer.URL + "/start")
+			rules, err := Fetch(context.Background(), http.DefaultClient, root, "crawler")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedAllowed, rules.IsAllowed(&url.URL{Path: "/private/page"}))
+		})
+	}
+
+	t.Run("unreachable robots file disallows everything", func(t *testing.T) {
+		testServer := httptest.NewServer(http.NotFoundHandler())
+		testServer.Close()
+		root, _ := url.Parse(testServer.URL + "/start")
+		rules, err := Fetch(context.Background(), http.DefaultClient, root, "crawler")
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "error fetching robots.txt, every link is disallowed")
+		}
+		assert.False(t, rules.IsAllowed(&url.URL{Path: "/page"}))
+	})
+}
+
+func TestFetchSitemap(t *testing.T) {
+	var host string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>%s/sitemap.xml</loc></sitemap></sitemapindex>`, host)
+		case "/partial_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>%s/missing.xml</loc></sitemap><sitemap><loc>%s/sitemap.xml</loc></sitemap></sitemapindex>`, host, host)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc> %s/about </loc></url><url><loc>%s/contact</loc></url></urlset>`, host, host)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	host = testServer.URL
+
+	links, err := FetchSitemap(context.Background(), http.DefaultClient, host+"/sitemap_index.xml")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{host + "/about", host + "/contact"}, links)
+
+	_, err = FetchSitemap(context.Background(), http.DefaultClient, host+"/missing.xml")
+	assert.NotNil(t, err)
+
+	// a failing child sitemap is skipped, the links of the other ones are kept
+	links, err = FetchSitemap(context.Background(), http.DefaultClient, host+"/partial_index.xml")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "/missing.xml")
+	}
+	assert.Equal(t, []string{host + "/about", host + "/contact"}, links)
+}
@@ -0,0 +1,70 @@
+package robots
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxSitemapSize is the maximum number of bytes read from a sitemap, as defined by the sitemaps protocol.
+	maxSitemapSize = 50 * 1024 * 1024
+	// maxSitemapDepth limits how many nested sitemap indexes are followed.
+	maxSitemapDepth = 3
+)
+
+type sitemapDocument struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// FetchSitemap downloads a sitemap with the client and returns the page URLs it lists, following sitemap indexes.
+// The child sitemaps that cannot be fetched are skipped, their errors returned along with the links of the other ones.
+func FetchSitemap(ctx context.Context, client *http.Client, link string) ([]string, error) {
+	return fetchSitemap(ctx, client, link, 0)
+}
+
+func fetchSitemap(ctx context.Context, client *http.Client, link string, depth int) ([]string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building sitemap request %s: %v", link, err)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sitemap %s: %v", link, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching sitemap %s: unexpected status %d", link, response.StatusCode)
+	}
+
+	document := sitemapDocument{}
+	if err := xml.NewDecoder(io.LimitReader(response.Body, maxSitemapSize)).Decode(&document); err != nil {
+		return nil, fmt.Errorf("error decoding sitemap %s: %v", link, err)
+	}
+
+	links := []string{}
+	for _, page := range document.URLs {
+		links = append(links, strings.TrimSpace(page.Loc))
+	}
+	if depth >= maxSitemapDepth {
+		return links, nil
+	}
+	var errs []error
+	for _, child := range document.Sitemaps {
+		childLinks, err := fetchSitemap(ctx, client, strings.TrimSpace(child.Loc), depth+1)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		links = append(links, childLinks...)
+	}
+	return links, errors.Join(errs...)
+}
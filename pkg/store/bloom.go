@@ -0,0 +1,261 @@
+package store
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+const (
+	// every new filter of a scalableBloom is bloomGrowth times bigger than the previous one
+	// and its false positive rate bloomTightening times lower
+	bloomGrowth     = 2
+	bloomTightening = 0.5
+)
+
+// bloomStore keeps the visited links in a scalable Bloom filter, using a few bits per link whatever
+// its length. A link reported as visited by the filter may not be, with the configured false positive
+// rate, unless the filter is verified against the exact set of links kept on disk. The crawl results
+// are kept in memory like in the memory store.
+type bloomStore struct {
+	recordStore
+	filter   *scalableBloom
+	verifier *diskSet
+	// capacity and falsePositiveRate size the filter
+	capacity          int
+	falsePositiveRate float64
+	// verified links were reported by the filter, falsePositives of them were not visited
+	verified       atomic.Int64
+	falsePositives atomic.Int64
+}
+
+// verifiedBloomStore is a bloomStore verified against its exact set, which can list the visited
+// links to checkpoint them.
+type verifiedBloomStore struct {
+	*bloomStore
+}
+
+// NewBloomStore creates a store with a filter sized for capacity links that grows beyond it keeping
+// the false positive rate. The links reported by the filter are verified against the exact set kept
+// in verifyDir, unless it is empty. Only a verified store implements IVisitedKeys, the filter does
+// not keep the links.
+func NewBloomStore(capacity int, falsePositiveRate float64, verifyDir string) (ICrawlerStore, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("invalid false positive rate %g, it must be between 0 and 1", falsePositiveRate)
+	}
+	s := &bloomStore{
+		recordStore:       recordStore{mu: &sync.Mutex{}, records: &records{}},
+		filter:            newScalableBloom(capacity, falsePositiveRate),
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+	}
+	if verifyDir != "" {
+		verifier, err := newDiskSet(verifyDir)
+		if err != nil {
+			return nil, err
+		}
+		s.verifier = verifier
+		return &verifiedBloomStore{bloomStore: s}, nil
+	}
+	return s, nil
+}
+
+// WasAlreadyVisited reports whether the site key was seen before and marks it as visited, it may
+// report an unvisited key as visited when there is no exact set to verify it.
+func (s *bloomStore) WasAlreadyVisited(site string) (bool, error) {
+	if s.verifier == nil {
+		return s.filter.TestAndAdd(site), nil
+	}
+	// the same key is not checked concurrently, it would be reported by the filter to the second
+	// caller before the first one added it to the set
+	lock := s.verifier.lock(site)
+	lock.Lock()
+	defer lock.Unlock()
+	// the key is added to the exact set either way, a lookup in its index
+	reported := s.filter.TestAndAdd(site)
+	added, err := s.verifier.add(site)
+	if err != nil {
+		return false, err
+	}
+	if reported {
+		s.verified.Add(1)
+		if added {
+			s.falsePositives.Add(1)
+		}
+	}
+	return !added, nil
+}
+
+// StoreData marks the site and the sites of data as visited.
+func (s *bloomStore) StoreData(site string, data models.SiteStore) error {
+	for key := range data.Sites {
+		if _, err := s.WasAlreadyVisited(key); err != nil {
+			return err
+		}
+	}
+	_, err := s.WasAlreadyVisited(site)
+	return err
+}
+
+// VisitedKeys returns the keys marked as visited, sorted, listed from the exact set.
+func (s *verifiedBloomStore) VisitedKeys() ([]string, error) {
+	return s.verifier.keys()
+}
+
+// RestoreVisited replaces the visited keys of the exact set with keys and adds them to a new filter.
+func (s *verifiedBloomStore) RestoreVisited(keys []string) error {
+	if err := s.verifier.reset(keys); err != nil {
+		return err
+	}
+	s.filter = newScalableBloom(s.capacity, s.falsePositiveRate)
+	for _, key := range keys {
+		s.filter.TestAndAdd(key)
+	}
+	return nil
+}
+
+// FilterStats returns the size and the estimated false positive rate of the filter.
+func (s *bloomStore) FilterStats() models.FilterStats {
+	keys, filters, memory, rate := s.filter.Stats()
+	return models.FilterStats{
+		Keys:              keys,
+		Filters:           filters,
+		MemoryBytes:       memory,
+		FalsePositiveRate: rate,
+		Verified:          int(s.verified.Load()),
+		FalsePositives:    int(s.falsePositives.Load()),
+	}
+}
+
+// Close releases the store, the exact set is kept on disk until the next crawl.
+func (s *bloomStore) Close() error {
+	if s.verifier == nil {
+		return nil
+	}
+	return s.verifier.close()
+}
+
+// bloomFilter is a fixed size Bloom filter for up to capacity keys at its false positive rate.
+type bloomFilter struct {
+	bits     []uint64
+	size     uint64
+	hashes   uint64
+	capacity int
+	count    int
+}
+
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(capacity)*math.Ln2)))
+	return &bloomFilter{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		hashes:   hashes,
+		capacity: capacity,
+	}
+}
+
+// add sets the bits of the key, h1 and h2 are two independent hashes of it.
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+// test reports whether every bit of the key is set.
+func (f *bloomFilter) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// falsePositiveRate estimates the current false positive rate from the number of keys added.
+func (f *bloomFilter) falsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(f.hashes)*float64(f.count)/float64(f.size)), float64(f.hashes))
+}
+
+// scalableBloom is a Bloom filter that grows as keys are added while keeping its false positive
+// rate, adding bigger and stricter filters once the last one is full.
+type scalableBloom struct {
+	mu        sync.Mutex
+	seeds     [2]maphash.Seed
+	filters   []*bloomFilter
+	nextSize  int
+	nextRate  float64
+	keysCount int
+}
+
+// newScalableBloom creates a filter sized for capacity keys, the false positive rate is kept for
+// any number of keys.
+func newScalableBloom(capacity int, falsePositiveRate float64) *scalableBloom {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	b := &scalableBloom{
+		seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		// the rates of the filters are a geometric series adding up to the requested one
+		nextSize: capacity,
+		nextRate: falsePositiveRate * (1 - bloomTightening),
+	}
+	b.grow()
+	return b
+}
+
+// TestAndAdd reports whether the key may have been added before and adds it otherwise.
+func (b *scalableBloom) TestAndAdd(key string) bool {
+	h1, h2 := b.hash(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, filter := range b.filters {
+		if filter.test(h1, h2) {
+			return true
+		}
+	}
+	b.add(h1, h2)
+	return false
+}
+
+func (b *scalableBloom) add(h1, h2 uint64) {
+	last := b.filters[len(b.filters)-1]
+	if last.count >= last.capacity {
+		last = b.grow()
+	}
+	last.add(h1, h2)
+	b.keysCount++
+}
+
+func (b *scalableBloom) grow() *bloomFilter {
+	filter := newBloomFilter(b.nextSize, b.nextRate)
+	b.filters = append(b.filters, filter)
+	b.nextSize *= bloomGrowth
+	b.nextRate *= bloomTightening
+	return filter
+}
+
+func (b *scalableBloom) hash(key string) (uint64, uint64) {
+	// an odd second hash never cycles over the same bits
+	return maphash.String(b.seeds[0], key), maphash.String(b.seeds[1], key) | 1
+}
+
+// Stats returns the number of keys and filters, their size and the estimated false positive rate.
+func (b *scalableBloom) Stats() (keys int, filters int, memoryBytes int64, falsePositiveRate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// a key is a false positive when any of the filters reports it
+	trueNegative := 1.0
+	for _, filter := range b.filters {
+		memoryBytes += int64(len(filter.bits) * 8)
+		trueNegative *= 1 - filter.falsePositiveRate()
+	}
+	return b.keysCount, len(b.filters), memoryBytes, 1 - trueNegative
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalableBloom(t *testing.T) {
+	tests := []struct {
+		name            string
+		capacity        int
+		expectedFilters int
+		maxBytesPerKey  float64
+	}{
+		{name: "within capacity", capacity: 200000, expectedFilters: 1, maxBytesPerKey: 1.5},
+		// 10000, 20000, 40000, 80000 and 160000 keys
+		{name: "grown beyond capacity", capacity: 10000, expectedFilters: 5, maxBytesPerKey: 3.5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rate := 0.01
+			filter := newScalableBloom(tc.capacity, rate)
+			added := 0
+			for i := 0; i < 100000; i++ {
+				if !filter.TestAndAdd(fmt.Sprintf("//mock-site.com/visited/%d", i)) {
+					added++
+				}
+			}
+			// there are no false negatives
+			for i := 0; i < 100000; i++ {
+				if !filter.TestAndAdd(fmt.Sprintf("//mock-site.com/visited/%d", i)) {
+					t.Fatalf("visited key %d not found", i)
+				}
+			}
+			falsePositives := 0
+			for i := 0; i < 100000; i++ {
+				if filter.TestAndAdd(fmt.Sprintf("//mock-site.com/other/%d", i)) {
+					falsePositives++
+				} else {
+					added++
+				}
+			}
+
+			keys, filters, memory, estimated := filter.Stats()
+			assert.Equal(t, added, keys)
+			assert.Equal(t, tc.expectedFilters, filters)
+			assert.Less(t, float64(falsePositives)/100000, rate)
+			assert.Less(t, estimated, rate)
+			assert.Less(t, float64(memory)/float64(keys), tc.maxBytesPerKey)
+		})
+	}
+}
+
+func TestBloomStore(t *testing.T) {
+	// a filter this small reports many links that were not visited
+	store, err := NewBloomStore(100, 0.3, "")
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/visited/%d", i))
+		assert.Nil(t, err)
+		if i == 0 {
+			assert.False(t, visited)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/visited/%d", i))
+		assert.Nil(t, err)
+		assert.True(t, visited)
+	}
+
+	stats := store.(IFilterStats).FilterStats()
+	assert.Greater(t, stats.Filters, 1)
+	assert.Greater(t, stats.MemoryBytes, int64(0))
+	assert.Greater(t, stats.FalsePositiveRate, 0.0)
+	assert.Zero(t, stats.Verified)
+
+	// the records are kept like in the memory store
+	assert.Nil(t, store.RecordNoindex("https://mock-site.com/private"))
+	noindex, err := store.NoindexPages()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://mock-site.com/private"}, noindex)
+	assert.Nil(t, store.Close())
+}
+
+func TestBloomStoreVerified(t *testing.T) {
+	dir := t.TempDir()
+	// keys of a previous crawl are removed
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "visited-0001.keys"), []byte("//mock-site.com/other/1\n"), 0644))
+
+	store, err := NewBloomStore(100, 0.3, dir)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	var firstVisits atomic.Int64
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/visited/%d", i))
+				assert.Nil(t, err)
+				if !visited {
+					firstVisits.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// with the exact set no link is skipped and none is visited twice
+	assert.Equal(t, int64(500), firstVisits.Load())
+	for i := 0; i < 500; i++ {
+		visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/other/%d", i))
+		assert.Nil(t, err)
+		assert.False(t, visited)
+	}
+
+	stats := store.(IFilterStats).FilterStats()
+	// the false positives were not added to the filter again
+	assert.Equal(t, 1000, stats.Keys+stats.FalsePositives)
+	assert.Greater(t, stats.Verified, 0)
+	assert.Greater(t, stats.FalsePositives, 0)
+}
+
+func TestNewBloomStoreErrors(t *testing.T) {
+	for _, rate := range []float64{0, -0.1, 1} {
+		store, err := NewBloomStore(100, rate, "")
+		assert.Nil(t, store)
+		assert.EqualError(t, err, fmt.Sprintf("invalid false positive rate %g, it must be between 0 and 1", rate))
+	}
+}
+
+func TestBloomStoreData(t *testing.T) {
+	store, err := NewBloomStore(100, 0.01, "")
+	assert.Nil(t, err)
+	assert.Nil(t, store.StoreData("//mock-site.com/", models.SiteStore{Sites: map[string]bool{"//mock-site.com/about": true}}))
+
+	visited, err := store.WasAlreadyVisited("//mock-site.com/about")
+	assert.Nil(t, err)
+	assert.True(t, visited)
+}
@@ -0,0 +1,291 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceConfigs returns the configuration every backend is tested with, a registered backend
+// without one fails the suite.
+var conformanceConfigs = map[string]func(t *testing.T) models.Config{
+	"memory": func(t *testing.T) models.Config {
+		return models.Config{StoreBackend: "memory"}
+	},
+	"file": func(t *testing.T) models.Config {
+		return models.Config{StoreBackend: "file", StoreDir: t.TempDir(), StoreCompactEvery: 3}
+	},
+	"bloom": func(t *testing.T) models.Config {
+		// verified so no link is reported as visited by mistake
+		return models.Config{StoreBackend: "bloom", BloomCapacity: 10, BloomFalsePositiveRate: 0.01, BloomVerifyDir: t.TempDir()}
+	},
+	"sqlite": func(t *testing.T) models.Config {
+		return models.Config{StoreBackend: "sqlite", SQLitePath: filepath.Join(t.TempDir(), "crawl.db")}
+	},
+	"redis": func(t *testing.T) models.Config {
+		server := miniredis.RunT(t)
+		return models.Config{StoreBackend: "redis", RedisURL: "redis://" + server.Addr(), RedisKeyPrefix: "crawler"}
+	},
+}
+
+// conformanceTests are the ICrawlerStore contract every backend implements.
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, store ICrawlerStore)
+}{
+	{
+		name: "visited links",
+		test: func(t *testing.T, store ICrawlerStore) {
+			for _, site := range []string{"", "//mock-site.com/a", "//mock-site.com/b"} {
+				visited, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.False(t, visited, site)
+				visited, err = store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.True(t, visited, site)
+			}
+		},
+	},
+	{
+		name: "stored sites",
+		test: func(t *testing.T, store ICrawlerStore) {
+			err := store.StoreData("//mock-site.com/", models.SiteStore{Sites: map[string]bool{"//mock-site.com/a": true}})
+			assert.Nil(t, err)
+			for _, site := range []string{"//mock-site.com/", "//mock-site.com/a"} {
+				visited, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.True(t, visited, site)
+			}
+			visited, err := store.WasAlreadyVisited("//mock-site.com/b")
+			assert.Nil(t, err)
+			assert.False(t, visited)
+		},
+	},
+	{
+		name: "concurrent visits",
+		test: func(t *testing.T, store ICrawlerStore) {
+			// every link is reported as not visited to exactly one of the workers
+			links, workers := 50, 8
+			var firstVisits atomic.Int64
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < links; i++ {
+						visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/%d", i))
+						assert.Nil(t, err)
+						if !visited {
+							firstVisits.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int64(links), firstVisits.Load())
+		},
+	},
+	{
+		name: "records",
+		test: func(t *testing.T, store ICrawlerStore) {
+			fillStore(t, store, "//mock-site.com/a")
+			assertStore(t, store, "//mock-site.com/a")
+
+			// the returned records are copies
+			pages, err := store.Pages()
+			assert.Nil(t, err)
+			pages[0].StatusCode = 500
+			assertStore(t, store)
+		},
+	},
+	{
+		name: "records in order",
+		test: func(t *testing.T, store ICrawlerStore) {
+			for i := 0; i < 5; i++ {
+				assert.Nil(t, store.RecordPage(models.PageResult{URL: fmt.Sprintf("https://mock-site.com/%d", i), ContentLength: -1}))
+				assert.Nil(t, store.RecordNoindex(fmt.Sprintf("https://mock-site.com/%d", i)))
+			}
+			pages, err := store.Pages()
+			assert.Nil(t, err)
+			noindex, err := store.NoindexPages()
+			assert.Nil(t, err)
+			for i := 0; i < 5; i++ {
+				assert.Equal(t, fmt.Sprintf("https://mock-site.com/%d", i), pages[i].URL)
+				assert.Equal(t, int64(-1), pages[i].ContentLength)
+				assert.Equal(t, fmt.Sprintf("https://mock-site.com/%d", i), noindex[i])
+			}
+		},
+	},
+	{
+		name: "empty records",
+		test: func(t *testing.T, store ICrawlerStore) {
+			assets, err := store.Assets()
+			assert.Nil(t, err)
+			assert.Empty(t, assets)
+			noindex, err := store.NoindexPages()
+			assert.Nil(t, err)
+			assert.Empty(t, noindex)
+			failed, err := store.FailedPages()
+			assert.Nil(t, err)
+			assert.Empty(t, failed)
+			pages, err := store.Pages()
+			assert.Nil(t, err)
+			assert.Empty(t, pages)
+		},
+	},
+	{
+		name: "page records",
+		test: func(t *testing.T, store ICrawlerStore) {
+			// every field of the record is kept
+			page := models.PageResult{
+				URL:      "https://mock-site.com/old",
+				Depth:    2,
+				Parent:   "https://mock-site.com/",
+				FinalURL: "https://mock-site.com/new",
+				Redirects: []models.Redirect{
+					{URL: "https://mock-site.com/old", StatusCode: 301, Location: "/new"},
+				},
+				StatusCode:    200,
+				ContentType:   "text/html; charset=iso-8859-1",
+				Charset:       "iso-8859-1",
+				Title:         "Mock page",
+				ContentLength: 2048,
+				FetchedAt:     time.Date(2026, 10, 18, 12, 30, 15, 123456789, time.UTC),
+				ResponseTime:  1234567 * time.Nanosecond,
+				Truncated:     true,
+				Error:         "mock error",
+			}
+			assert.Nil(t, store.RecordPage(page))
+			pages, err := store.Pages()
+			assert.Nil(t, err)
+			assert.Equal(t, []models.PageResult{page}, pages)
+		},
+	},
+	{
+		name: "links",
+		test: func(t *testing.T, store ICrawlerStore) {
+			recorder, ok := store.(ILinkRecorder)
+			if !ok {
+				t.Skip("the backend does not record links")
+			}
+			links := []models.LinkResult{
+				{From: "https://mock-site.com/", To: "https://mock-site.com/a", Text: "Page A", Tag: "a", Attr: "href"},
+				{From: "https://mock-site.com/", To: "https://mock-site.com/b", Tag: "link", Attr: "href"},
+			}
+			assert.Nil(t, recorder.RecordLinks(links[:1]))
+			assert.Nil(t, recorder.RecordLinks(links[1:]))
+			recorded, err := recorder.Links()
+			assert.Nil(t, err)
+			assert.Equal(t, links, recorded)
+		},
+	},
+	{
+		name: "visited keys",
+		test: func(t *testing.T, store ICrawlerStore) {
+			keys, ok := store.(IVisitedKeys)
+			if !ok {
+				t.Skip("the backend cannot list its visited links")
+			}
+			for _, site := range []string{"//mock-site.com/b", "//mock-site.com/a"} {
+				_, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+			}
+			visited, err := keys.VisitedKeys()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"//mock-site.com/a", "//mock-site.com/b"}, visited)
+
+			// the restored keys replace the visited ones
+			assert.Nil(t, keys.RestoreVisited([]string{"//mock-site.com/c", "//mock-site.com/a"}))
+			visited, err = keys.VisitedKeys()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"//mock-site.com/a", "//mock-site.com/c"}, visited)
+			for site, expected := range map[string]bool{"//mock-site.com/a": true, "//mock-site.com/b": false, "//mock-site.com/c": true} {
+				wasVisited, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.Equal(t, expected, wasVisited, site)
+			}
+		},
+	},
+}
+
+func TestConformance(t *testing.T) {
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			newConfig, ok := conformanceConfigs[backend]
+			if !ok {
+				t.Fatalf("no conformance configuration for the %s backend", backend)
+			}
+			for _, tc := range conformanceTests {
+				t.Run(tc.name, func(t *testing.T) {
+					store, err := New(newConfig(t))
+					assert.Nil(t, err)
+					tc.test(t, store)
+					assert.Nil(t, store.Close())
+				})
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           models.Config
+		expectedError string
+	}{
+		{
+			name:          "unknown backend",
+			cfg:           models.Config{StoreBackend: "mongo"},
+			expectedError: `unknown store backend "mongo", available backends: bloom, file, memory, redis, sqlite`,
+		},
+		{
+			name:          "file backend without a directory",
+			cfg:           models.Config{StoreBackend: "file"},
+			expectedError: "the file store backend needs a directory",
+		},
+		{
+			name:          "bloom backend without a false positive rate",
+			cfg:           models.Config{StoreBackend: "bloom"},
+			expectedError: "invalid false positive rate 0, it must be between 0 and 1",
+		},
+		{
+			name:          "sqlite backend without a database path",
+			cfg:           models.Config{StoreBackend: "sqlite"},
+			expectedError: "the sqlite store backend needs a database path",
+		},
+		{
+			name:          "redis backend with an invalid URL",
+			cfg:           models.Config{StoreBackend: "redis", RedisURL: "http://localhost:6379"},
+			expectedError: "invalid redis URL: redis: invalid URL scheme: http",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := New(tc.cfg)
+			assert.Nil(t, store)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("custom", newMemoryBackend)
+	defer func() {
+		backendsMu.Lock()
+		delete(backends, "custom")
+		backendsMu.Unlock()
+	}()
+	assert.Contains(t, Backends(), "custom")
+
+	store, err := New(models.Config{StoreBackend: "custom"})
+	assert.Nil(t, err)
+	assert.NotNil(t, store)
+}
@@ -0,0 +1,443 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/csrar/crawler/internal/models"
+)
+
+const (
+	snapshotFile = "store.snapshot"
+	logFile      = "store.log"
+	recordsFile  = "store.records"
+)
+
+// diskStore keeps the visited keys and the crawl records in a directory. Every change is appended
+// to a log and the call returns once it is fsynced. The changes written while the logs are being
+// synced are synced together by the next call, so concurrent callers share the fsyncs instead of
+// waiting for them one at a time. The records are appended to the records log, which is never
+// rewritten, and kept across crawls. The visited keys are appended to their own log, once it has
+// compactEvery entries it is compacted into a snapshot, written to a temporary file and atomically
+// renamed over the previous one. The visited keys of a previous crawl are cleared when the store
+// opens, a resumed crawl brings them back with RestoreVisited.
+type diskStore struct {
+	dir string
+	// mu guards the content of the store and the writes to the logs, syncMu the fsyncs of the logs.
+	// The logs are closed holding both of them
+	mu           sync.Mutex
+	syncMu       sync.Mutex
+	log          *os.File
+	logSize      int64
+	logEntries   int
+	compactEvery int
+	recordLog    *os.File
+	recordSize   int64
+	// logDirty and recordsDirty tell whether the logs were written since they were last synced
+	logDirty     atomic.Bool
+	recordsDirty atomic.Bool
+	// sequence is the number of the last change, written the one of the last change written to
+	// its log, synced the one of the last change on disk
+	sequence uint64
+	written  atomic.Uint64
+	synced   uint64
+	// syncLog fsyncs a log, replaced by the tests
+	syncLog func(log *os.File) error
+	visited map[string]struct{}
+	records *records
+}
+
+// entry is a change appended to a log, only one of its values is set.
+type entry struct {
+	Visited *string             `json:"visited,omitempty"`
+	Asset   *models.AssetResult `json:"asset,omitempty"`
+	Noindex *string             `json:"noindex,omitempty"`
+	Failed  *models.FailedPage  `json:"failed,omitempty"`
+	Page    *models.PageResult  `json:"page,omitempty"`
+}
+
+// snapshot is the visited keys of the store when the visited log was compacted.
+type snapshot struct {
+	Visited []string `json:"visited"`
+}
+
+// NewDiskStore opens the store kept in dir, creating it when it does not exist, and loads its
+// records. The visited keys left by a previous crawl are cleared. compactEvery is the number of
+// visited log entries that triggers a compaction, 0 compacts only when the store is closed.
+func NewDiskStore(dir string, compactEvery int) (ICrawlerStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+	s := &diskStore{
+		dir:          dir,
+		compactEvery: compactEvery,
+		syncLog:      (*os.File).Sync,
+		visited:      map[string]struct{}{},
+		records:      &records{},
+	}
+	if err := s.openRecords(); err != nil {
+		return nil, err
+	}
+	if err := s.clearVisited(); err != nil {
+		s.recordLog.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// WasAlreadyVisited reports whether the site key was seen before and marks it as visited.
+func (s *diskStore) WasAlreadyVisited(site string) (bool, error) {
+	s.mu.Lock()
+	if _, ok := s.visited[site]; ok {
+		s.mu.Unlock()
+		return true, nil
+	}
+	sequence, err := s.append(entry{Visited: &site})
+	s.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	return false, s.sync(sequence)
+}
+
+// StoreData marks the site and the sites of data as visited.
+func (s *diskStore) StoreData(site string, data models.SiteStore) error {
+	sites := []string{site}
+	for key := range data.Sites {
+		sites = append(sites, key)
+	}
+	entries := []entry{}
+	for i := range sites {
+		entries = append(entries, entry{Visited: &sites[i]})
+	}
+	return s.commit(entries...)
+}
+
+// RecordAsset keeps the result of checking an asset.
+func (s *diskStore) RecordAsset(asset models.AssetResult) error {
+	return s.commit(entry{Asset: &asset})
+}
+
+// Assets returns the recorded assets in the order they were checked.
+func (s *diskStore) Assets() ([]models.AssetResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.AssetResult{}, s.records.assets...), nil
+}
+
+// RecordNoindex keeps a page that asked not to be indexed.
+func (s *diskStore) RecordNoindex(page string) error {
+	return s.commit(entry{Noindex: &page})
+}
+
+// NoindexPages returns the recorded noindex pages in the order they were crawled.
+func (s *diskStore) NoindexPages() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.records.noindex...), nil
+}
+
+// RecordFailure keeps a page that could not be crawled so it can be queued again.
+func (s *diskStore) RecordFailure(page models.FailedPage) error {
+	return s.commit(entry{Failed: &page})
+}
+
+// FailedPages returns the pages that could not be crawled in the order they failed.
+func (s *diskStore) FailedPages() ([]models.FailedPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.FailedPage{}, s.records.failed...), nil
+}
+
+// RecordPage keeps the result of fetching a page.
+func (s *diskStore) RecordPage(page models.PageResult) error {
+	return s.commit(entry{Page: &page})
+}
+
+// Pages returns the results of the fetched pages in the order they were fetched.
+func (s *diskStore) Pages() ([]models.PageResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.PageResult{}, s.records.pages...), nil
+}
+
+// VisitedKeys returns the keys marked as visited, sorted.
+func (s *diskStore) VisitedKeys() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedVisited(), nil
+}
+
+// RestoreVisited replaces the visited keys with keys, compacting them into the snapshot.
+func (s *diskStore) RestoreVisited(keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return errors.New("store is closed")
+	}
+	s.visited = make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		s.visited[key] = struct{}{}
+	}
+	return s.compact()
+}
+
+// Close compacts the visited log into the snapshot, syncs the records and closes the logs.
+func (s *diskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.compact()
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if syncErr := s.syncLog(s.recordLog); err == nil && syncErr != nil {
+		err = fmt.Errorf("error syncing store records: %w", syncErr)
+	}
+	for _, log := range []*os.File{s.log, s.recordLog} {
+		if closeErr := log.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.log = nil
+	s.recordLog = nil
+	return err
+}
+
+// commit appends the changes that are not already in the store and waits for them to be on disk.
+// The visited keys already marked are skipped.
+func (s *diskStore) commit(entries ...entry) error {
+	s.mu.Lock()
+	var sequence uint64
+	for _, e := range entries {
+		if e.Visited != nil {
+			if _, ok := s.visited[*e.Visited]; ok {
+				continue
+			}
+		}
+		var err error
+		if sequence, err = s.append(e); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+	if sequence == 0 {
+		return nil
+	}
+	return s.sync(sequence)
+}
+
+// append writes the change to its log and applies it, returning its sequence to sync.
+func (s *diskStore) append(e entry) (uint64, error) {
+	if s.log == nil {
+		return 0, errors.New("store is closed")
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling store entry: %w", err)
+	}
+	line = append(line, '\n')
+	if e.Visited != nil {
+		if err := writeEntry(s.log, &s.logSize, line); err != nil {
+			return 0, fmt.Errorf("error writing store log: %w", err)
+		}
+		s.logEntries++
+		s.logDirty.Store(true)
+	} else {
+		if err := writeEntry(s.recordLog, &s.recordSize, line); err != nil {
+			return 0, fmt.Errorf("error writing store records: %w", err)
+		}
+		s.recordsDirty.Store(true)
+	}
+	s.apply(e)
+	s.sequence++
+	s.written.Store(s.sequence)
+	if s.compactEvery > 0 && s.logEntries >= s.compactEvery {
+		if err := s.compact(); err != nil {
+			return 0, err
+		}
+	}
+	return s.sequence, nil
+}
+
+// writeEntry appends the line to the log of size, a partial line is truncated as it would make the
+// next ones unreadable.
+func writeEntry(log *os.File, size *int64, line []byte) error {
+	if _, err := log.Write(line); err != nil {
+		_ = log.Truncate(*size)
+		return err
+	}
+	*size += int64(len(line))
+	return nil
+}
+
+// sync returns once the change of the sequence is on disk. The first caller fsyncs the logs
+// written so far, the callers waiting meanwhile find theirs synced by it.
+func (s *diskStore) sync(sequence uint64) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if s.synced >= sequence {
+		return nil
+	}
+	if s.log == nil {
+		return errors.New("store is closed")
+	}
+	// the logs are marked dirty before the sequence of their changes is written
+	written := s.written.Load()
+	for _, log := range []struct {
+		file  *os.File
+		dirty *atomic.Bool
+	}{{s.log, &s.logDirty}, {s.recordLog, &s.recordsDirty}} {
+		if !log.dirty.Swap(false) {
+			continue
+		}
+		if err := s.syncLog(log.file); err != nil {
+			// the changes stay in the log, the next sync tries again
+			log.dirty.Store(true)
+			return fmt.Errorf("error syncing store log: %w", err)
+		}
+	}
+	if written > s.synced {
+		s.synced = written
+	}
+	return nil
+}
+
+// apply adds the change to the content of the store.
+func (s *diskStore) apply(e entry) {
+	switch {
+	case e.Visited != nil:
+		s.visited[*e.Visited] = struct{}{}
+	case e.Asset != nil:
+		s.records.assets = append(s.records.assets, *e.Asset)
+	case e.Noindex != nil:
+		s.records.noindex = append(s.records.noindex, *e.Noindex)
+	case e.Failed != nil:
+		s.records.failed = append(s.records.failed, *e.Failed)
+	case e.Page != nil:
+		s.records.pages = append(s.records.pages, *e.Page)
+	}
+}
+
+// compact writes the visited keys to a new snapshot and empties the visited log. The records are
+// left in their log, they never change once appended.
+func (s *diskStore) compact() error {
+	payload, err := json.Marshal(snapshot{Visited: s.sortedVisited()})
+	if err != nil {
+		return fmt.Errorf("error marshaling store snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFile), payload); err != nil {
+		return err
+	}
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("error truncating store log: %w", err)
+	}
+	if err := s.syncLog(s.log); err != nil {
+		return fmt.Errorf("error syncing store log: %w", err)
+	}
+	s.logSize = 0
+	s.logEntries = 0
+	// the visited keys are in the snapshot
+	s.logDirty.Store(false)
+	return nil
+}
+
+func (s *diskStore) sortedVisited() []string {
+	visited := make([]string, 0, len(s.visited))
+	for key := range s.visited {
+		visited = append(visited, key)
+	}
+	sort.Strings(visited)
+	return visited
+}
+
+// openRecords replays the records log and opens it for appending. A last line without its newline
+// was being written when the process stopped and is dropped.
+func (s *diskStore) openRecords() error {
+	log, err := os.OpenFile(filepath.Join(s.dir, recordsFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening store records: %w", err)
+	}
+	reader := bufio.NewReader(log)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Close()
+			return fmt.Errorf("error reading store records: %w", err)
+		}
+		e := entry{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			log.Close()
+			return fmt.Errorf("error decoding store records at offset %d: %w", s.recordSize, err)
+		}
+		s.recordSize += int64(len(line))
+		s.apply(e)
+	}
+	if err := log.Truncate(s.recordSize); err != nil {
+		log.Close()
+		return fmt.Errorf("error truncating store records: %w", err)
+	}
+	s.recordLog = log
+	return nil
+}
+
+// clearVisited removes the visited keys of a previous crawl and opens the empty visited log.
+func (s *diskStore) clearVisited() error {
+	if err := os.Remove(filepath.Join(s.dir, snapshotFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing store snapshot: %w", err)
+	}
+	log, err := os.OpenFile(filepath.Join(s.dir, logFile), os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening store log: %w", err)
+	}
+	s.log = log
+	return nil
+}
+
+// writeFileAtomic replaces the file with the payload, either the old or the new content is found
+// after a crash.
+func writeFileAtomic(path string, payload []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+	// the rename itself is only durable once the directory is synced
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("error opening %s directory: %w", path, err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("error syncing %s directory: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fillStore visits the sites and records one result of each kind.
+func fillStore(t *testing.T, store ICrawlerStore, sites ...string) {
+	for _, site := range sites {
+		visited, err := store.WasAlreadyVisited(site)
+		assert.Nil(t, err)
+		assert.False(t, visited)
+	}
+	assert.Nil(t, store.RecordAsset(models.AssetResult{URL: "https://mock-site.com/logo.png", StatusCode: 200, Size: 512}))
+	assert.Nil(t, store.RecordNoindex("https://mock-site.com/private"))
+	assert.Nil(t, store.RecordFailure(models.FailedPage{Item: models.CrawlItem{URL: "https://mock-site.com/down"}, StatusCode: 503}))
+	assert.Nil(t, store.RecordPage(models.PageResult{URL: "https://mock-site.com/", StatusCode: 200, ContentLength: 1024}))
+}
+
+// assertStore checks the store has the sites visited and the records of fillStore.
+func assertStore(t *testing.T, store ICrawlerStore, sites ...string) {
+	for _, site := range sites {
+		visited, err := store.WasAlreadyVisited(site)
+		assert.Nil(t, err)
+		assert.True(t, visited, site)
+	}
+	assets, _ := store.Assets()
+	assert.Equal(t, []models.AssetResult{{URL: "https://mock-site.com/logo.png", StatusCode: 200, Size: 512}}, assets)
+	noindex, _ := store.NoindexPages()
+	assert.Equal(t, []string{"https://mock-site.com/private"}, noindex)
+	failed, _ := store.FailedPages()
+	assert.Equal(t, []models.FailedPage{{Item: models.CrawlItem{URL: "https://mock-site.com/down"}, StatusCode: 503}}, failed)
+	pages, _ := store.Pages()
+	assert.Equal(t, []models.PageResult{{URL: "https://mock-site.com/", StatusCode: 200, ContentLength: 1024}}, pages)
+}
+
+func TestDiskStoreReopen(t *testing.T) {
+	tests := []struct {
+		name         string
+		compactEvery int
+		close        bool
+	}{
+		{name: "closed", close: true},
+		{name: "not closed", close: false},
+		{name: "compacted while crawling", compactEvery: 2, close: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewDiskStore(dir, tc.compactEvery)
+			assert.Nil(t, err)
+			assert.Nil(t, store.StoreData("", models.SiteStore{Sites: map[string]bool{}}))
+			fillStore(t, store, "//mock-site.com/a", "//mock-site.com/b", "//mock-site.com/c")
+			assertStore(t, store, "", "//mock-site.com/a", "//mock-site.com/b", "//mock-site.com/c")
+			if tc.close {
+				assert.Nil(t, store.Close())
+			}
+
+			// a new crawl keeps the records of the previous one but visits its links again
+			reopened, err := NewDiskStore(dir, tc.compactEvery)
+			assert.Nil(t, err)
+			assertStore(t, reopened)
+			keys, err := reopened.(IVisitedKeys).VisitedKeys()
+			assert.Nil(t, err)
+			assert.Empty(t, keys)
+			for _, site := range []string{"//mock-site.com/a", "//mock-site.com/d"} {
+				visited, err := reopened.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.False(t, visited, site)
+			}
+			assert.Nil(t, reopened.Close())
+		})
+	}
+}
+
+func TestDiskStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 3)
+	assert.Nil(t, err)
+	fillStore(t, store, "//mock-site.com/a", "//mock-site.com/b", "//mock-site.com/c", "//mock-site.com/d")
+
+	// the first three visited keys are in the snapshot, the last one in the log
+	snapshot, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"visited":["//mock-site.com/a","//mock-site.com/b","//mock-site.com/c"]}`, string(snapshot))
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"visited":"//mock-site.com/d"}`+"\n", string(log))
+	// the records are only appended
+	records, err := os.ReadFile(filepath.Join(dir, recordsFile))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, strings.Count(string(records), "\n"))
+
+	assert.Nil(t, store.Close())
+	log, err = os.ReadFile(filepath.Join(dir, logFile))
+	assert.Nil(t, err)
+	assert.Empty(t, log)
+	closed, err := os.ReadFile(filepath.Join(dir, recordsFile))
+	assert.Nil(t, err)
+	assert.Equal(t, records, closed)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Empty(t, matches)
+}
+
+func TestDiskStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	fillStore(t, store, "//mock-site.com/a")
+
+	// the process stopped while writing a record
+	log, err := os.OpenFile(filepath.Join(dir, recordsFile), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = log.WriteString(`{"noindex":"https://mock-si`)
+	assert.Nil(t, err)
+	log.Close()
+
+	reopened, err := NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	assertStore(t, reopened)
+	assert.Nil(t, reopened.RecordNoindex("https://mock-site.com/hidden"))
+
+	reopened, err = NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	noindex, err := reopened.NoindexPages()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://mock-site.com/private", "https://mock-site.com/hidden"}, noindex)
+}
+
+func TestDiskStoreRestoreVisited(t *testing.T) {
+	dir := t.TempDir()
+	crawlStore, err := NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	fillStore(t, crawlStore, "//mock-site.com/a", "//mock-site.com/b")
+	assert.Nil(t, crawlStore.(IVisitedKeys).RestoreVisited([]string{"//mock-site.com/a"}))
+	_, err = crawlStore.WasAlreadyVisited("//mock-site.com/c")
+	assert.Nil(t, err)
+	keys, err := crawlStore.(IVisitedKeys).VisitedKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//mock-site.com/a", "//mock-site.com/c"}, keys)
+	assert.Nil(t, crawlStore.Close())
+
+	// a resumed crawl brings back the checkpointed keys, the records are kept
+	reopened, err := NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, reopened.(IVisitedKeys).RestoreVisited([]string{"//mock-site.com/a", "//mock-site.com/c"}))
+	assertStore(t, reopened, "//mock-site.com/a", "//mock-site.com/c")
+	visited, err := reopened.WasAlreadyVisited("//mock-site.com/b")
+	assert.Nil(t, err)
+	assert.False(t, visited)
+}
+
+func TestDiskStoreSyncFailure(t *testing.T) {
+	dir := t.TempDir()
+	crawlStore, err := NewDiskStore(dir, 0)
+	assert.Nil(t, err)
+	disk := crawlStore.(*diskStore)
+	disk.syncLog = func(log *os.File) error {
+		return errors.New("input/output error")
+	}
+	_, err = crawlStore.WasAlreadyVisited("//mock-site.com/a")
+	assert.EqualError(t, err, "error syncing store log: input/output error")
+	err = crawlStore.RecordNoindex("https://mock-site.com/private")
+	assert.EqualError(t, err, "error syncing store log: input/output error")
+
+	// the next change syncs the ones that failed along with it
+	var synced []string
+	disk.syncLog = func(log *os.File) error {
+		synced = append(synced, filepath.Base(log.Name()))
+		return log.Sync()
+	}
+	_, err = crawlStore.WasAlreadyVisited("//mock-site.com/b")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{logFile, recordsFile}, synced)
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"visited":"//mock-site.com/a"}`+"\n"+`{"visited":"//mock-site.com/b"}`+"\n", string(log))
+	keys, err := crawlStore.(IVisitedKeys).VisitedKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"//mock-site.com/a", "//mock-site.com/b"}, keys)
+}
+
+func TestDiskStoreGroupCommit(t *testing.T) {
+	crawlStore, err := NewDiskStore(t.TempDir(), 0)
+	assert.Nil(t, err)
+	disk := crawlStore.(*diskStore)
+	var syncs atomic.Int32
+	disk.syncLog = func(log *os.File) error {
+		syncs.Add(1)
+		// a slow disk lets the other writers queue up behind the sync
+		time.Sleep(5 * time.Millisecond)
+		return log.Sync()
+	}
+
+	writers, writes := 20, 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				_, err := crawlStore.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/%d/%d", i, j))
+				assert.Nil(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	keys, err := crawlStore.(IVisitedKeys).VisitedKeys()
+	assert.Nil(t, err)
+	assert.Len(t, keys, writers*writes)
+	// the writers waiting for a sync share the next one
+	assert.Less(t, int(syncs.Load()), writers*writes/2)
+	assert.Nil(t, crawlStore.Close())
+}
+
+func TestDiskStoreErrors(t *testing.T) {
+	t.Run("corrupted records", func(t *testing.T) {
+		dir := t.TempDir()
+		content := `{"noindex":"https://mock-site.com/a"}` + "\n" + "not json\n" + `{"noindex":"https://mock-site.com/b"}` + "\n"
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, recordsFile), []byte(content), 0644))
+
+		store, err := NewDiskStore(dir, 0)
+		assert.Nil(t, store)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "error decoding store records at offset 38"), err.Error())
+		}
+	})
+
+	t.Run("closed store", func(t *testing.T) {
+		store, err := NewDiskStore(t.TempDir(), 0)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Close())
+		assert.Nil(t, store.Close())
+
+		_, err = store.WasAlreadyVisited("//mock-site.com/a")
+		assert.EqualError(t, err, "store is closed")
+	})
+}
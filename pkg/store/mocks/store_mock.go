@@ -34,6 +34,136 @@ func (m *MockICrawlerStore) EXPECT() *MockICrawlerStoreMockRecorder {
 	return m.recorder
 }
 
+// Assets mocks base method.
+func (m *MockICrawlerStore) Assets() ([]models.AssetResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Assets")
+	ret0, _ := ret[0].([]models.AssetResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Assets indicates an expected call of Assets.
+func (mr *MockICrawlerStoreMockRecorder) Assets() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Assets", reflect.TypeOf((*MockICrawlerStore)(nil).Assets))
+}
+
+// Close mocks base method.
+func (m *MockICrawlerStore) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockICrawlerStoreMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockICrawlerStore)(nil).Close))
+}
+
+// FailedPages mocks base method.
+func (m *MockICrawlerStore) FailedPages() ([]models.FailedPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailedPages")
+	ret0, _ := ret[0].([]models.FailedPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailedPages indicates an expected call of FailedPages.
+func (mr *MockICrawlerStoreMockRecorder) FailedPages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailedPages", reflect.TypeOf((*MockICrawlerStore)(nil).FailedPages))
+}
+
+// NoindexPages mocks base method.
+func (m *MockICrawlerStore) NoindexPages() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NoindexPages")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NoindexPages indicates an expected call of NoindexPages.
+func (mr *MockICrawlerStoreMockRecorder) NoindexPages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NoindexPages", reflect.TypeOf((*MockICrawlerStore)(nil).NoindexPages))
+}
+
+// Pages mocks base method.
+func (m *MockICrawlerStore) Pages() ([]models.PageResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pages")
+	ret0, _ := ret[0].([]models.PageResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pages indicates an expected call of Pages.
+func (mr *MockICrawlerStoreMockRecorder) Pages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pages", reflect.TypeOf((*MockICrawlerStore)(nil).Pages))
+}
+
+// RecordAsset mocks base method.
+func (m *MockICrawlerStore) RecordAsset(asset models.AssetResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAsset", asset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordAsset indicates an expected call of RecordAsset.
+func (mr *MockICrawlerStoreMockRecorder) RecordAsset(asset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAsset", reflect.TypeOf((*MockICrawlerStore)(nil).RecordAsset), asset)
+}
+
+// RecordFailure mocks base method.
+func (m *MockICrawlerStore) RecordFailure(page models.FailedPage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailure", page)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordFailure indicates an expected call of RecordFailure.
+func (mr *MockICrawlerStoreMockRecorder) RecordFailure(page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailure", reflect.TypeOf((*MockICrawlerStore)(nil).RecordFailure), page)
+}
+
+// RecordNoindex mocks base method.
+func (m *MockICrawlerStore) RecordNoindex(page string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordNoindex", page)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordNoindex indicates an expected call of RecordNoindex.
+func (mr *MockICrawlerStoreMockRecorder) RecordNoindex(page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordNoindex", reflect.TypeOf((*MockICrawlerStore)(nil).RecordNoindex), page)
+}
+
+// RecordPage mocks base method.
+func (m *MockICrawlerStore) RecordPage(page models.PageResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordPage", page)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordPage indicates an expected call of RecordPage.
+func (mr *MockICrawlerStoreMockRecorder) RecordPage(page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPage", reflect.TypeOf((*MockICrawlerStore)(nil).RecordPage), page)
+}
+
 // StoreData mocks base method.
 func (m *MockICrawlerStore) StoreData(site string, data models.SiteStore) error {
 	m.ctrl.T.Helper()
@@ -62,3 +192,144 @@ func (mr *MockICrawlerStoreMockRecorder) WasAlreadyVisited(site interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WasAlreadyVisited", reflect.TypeOf((*MockICrawlerStore)(nil).WasAlreadyVisited), site)
 }
+
+// MockIFilterStats is a mock of IFilterStats interface.
+type MockIFilterStats struct {
+	ctrl     *gomock.Controller
+	recorder *MockIFilterStatsMockRecorder
+}
+
+// MockIFilterStatsMockRecorder is the mock recorder for MockIFilterStats.
+type MockIFilterStatsMockRecorder struct {
+	mock *MockIFilterStats
+}
+
+// NewMockIFilterStats creates a new mock instance.
+func NewMockIFilterStats(ctrl *gomock.Controller) *MockIFilterStats {
+	mock := &MockIFilterStats{ctrl: ctrl}
+	mock.recorder = &MockIFilterStatsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIFilterStats) EXPECT() *MockIFilterStatsMockRecorder {
+	return m.recorder
+}
+
+// FilterStats mocks base method.
+func (m *MockIFilterStats) FilterStats() models.FilterStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterStats")
+	ret0, _ := ret[0].(models.FilterStats)
+	return ret0
+}
+
+// FilterStats indicates an expected call of FilterStats.
+func (mr *MockIFilterStatsMockRecorder) FilterStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterStats", reflect.TypeOf((*MockIFilterStats)(nil).FilterStats))
+}
+
+// MockILinkRecorder is a mock of ILinkRecorder interface.
+type MockILinkRecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockILinkRecorderMockRecorder
+}
+
+// MockILinkRecorderMockRecorder is the mock recorder for MockILinkRecorder.
+type MockILinkRecorderMockRecorder struct {
+	mock *MockILinkRecorder
+}
+
+// NewMockILinkRecorder creates a new mock instance.
+func NewMockILinkRecorder(ctrl *gomock.Controller) *MockILinkRecorder {
+	mock := &MockILinkRecorder{ctrl: ctrl}
+	mock.recorder = &MockILinkRecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockILinkRecorder) EXPECT() *MockILinkRecorderMockRecorder {
+	return m.recorder
+}
+
+// Links mocks base method.
+func (m *MockILinkRecorder) Links() ([]models.LinkResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Links")
+	ret0, _ := ret[0].([]models.LinkResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Links indicates an expected call of Links.
+func (mr *MockILinkRecorderMockRecorder) Links() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Links", reflect.TypeOf((*MockILinkRecorder)(nil).Links))
+}
+
+// RecordLinks mocks base method.
+func (m *MockILinkRecorder) RecordLinks(links []models.LinkResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLinks", links)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordLinks indicates an expected call of RecordLinks.
+func (mr *MockILinkRecorderMockRecorder) RecordLinks(links interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLinks", reflect.TypeOf((*MockILinkRecorder)(nil).RecordLinks), links)
+}
+
+// MockIVisitedKeys is a mock of IVisitedKeys interface.
+type MockIVisitedKeys struct {
+	ctrl     *gomock.Controller
+	recorder *MockIVisitedKeysMockRecorder
+}
+
+// MockIVisitedKeysMockRecorder is the mock recorder for MockIVisitedKeys.
+type MockIVisitedKeysMockRecorder struct {
+	mock *MockIVisitedKeys
+}
+
+// NewMockIVisitedKeys creates a new mock instance.
+func NewMockIVisitedKeys(ctrl *gomock.Controller) *MockIVisitedKeys {
+	mock := &MockIVisitedKeys{ctrl: ctrl}
+	mock.recorder = &MockIVisitedKeysMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIVisitedKeys) EXPECT() *MockIVisitedKeysMockRecorder {
+	return m.recorder
+}
+
+// RestoreVisited mocks base method.
+func (m *MockIVisitedKeys) RestoreVisited(keys []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreVisited", keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreVisited indicates an expected call of RestoreVisited.
+func (mr *MockIVisitedKeysMockRecorder) RestoreVisited(keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreVisited", reflect.TypeOf((*MockIVisitedKeys)(nil).RestoreVisited), keys)
+}
+
+// VisitedKeys mocks base method.
+func (m *MockIVisitedKeys) VisitedKeys() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VisitedKeys")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VisitedKeys indicates an expected call of VisitedKeys.
+func (mr *MockIVisitedKeysMockRecorder) VisitedKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VisitedKeys", reflect.TypeOf((*MockIVisitedKeys)(nil).VisitedKeys))
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisRestoreBatch is the number of keys added to the visited set by a single command when it is restored.
+const redisRestoreBatch = 1000
+
+// redisStore keeps the visited keys in a Redis set and the crawl records in Redis lists, so
+// several crawler processes pointed at the same Redis and key prefix share them. Adding a key to
+// the set is atomic, so a link is reported as not visited to only one of the processes.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a store keeping its keys under prefix in the Redis of client, closing
+// the client when the store is closed. The keys are kept once the store is closed.
+func NewRedisStore(client *redis.Client, prefix string) ICrawlerStore {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+// WasAlreadyVisited reports whether the site key was seen before and marks it as visited.
+func (s *redisStore) WasAlreadyVisited(site string) (bool, error) {
+	added, err := s.client.SAdd(context.Background(), s.key("visited"), site).Result()
+	if err != nil {
+		return false, fmt.Errorf("error marking visited link: %w", err)
+	}
+	return added == 0, nil
+}
+
+// StoreData marks the site and the sites of data as visited.
+func (s *redisStore) StoreData(site string, data models.SiteStore) error {
+	sites := []interface{}{site}
+	for key := range data.Sites {
+		sites = append(sites, key)
+	}
+	if err := s.client.SAdd(context.Background(), s.key("visited"), sites...).Err(); err != nil {
+		return fmt.Errorf("error marking visited links: %w", err)
+	}
+	return nil
+}
+
+// RecordAsset keeps the result of checking an asset.
+func (s *redisStore) RecordAsset(asset models.AssetResult) error {
+	return s.record("assets", asset)
+}
+
+// Assets returns the recorded assets in the order they were checked.
+func (s *redisStore) Assets() ([]models.AssetResult, error) {
+	assets := []models.AssetResult{}
+	err := s.read("assets", func(value []byte) error {
+		asset := models.AssetResult{}
+		if err := json.Unmarshal(value, &asset); err != nil {
+			return err
+		}
+		assets = append(assets, asset)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// RecordNoindex keeps a page that asked not to be indexed.
+func (s *redisStore) RecordNoindex(page string) error {
+	return s.record("noindex", page)
+}
+
+// NoindexPages returns the recorded noindex pages in the order they were crawled.
+func (s *redisStore) NoindexPages() ([]string, error) {
+	noindex := []string{}
+	err := s.read("noindex", func(value []byte) error {
+		page := ""
+		if err := json.Unmarshal(value, &page); err != nil {
+			return err
+		}
+		noindex = append(noindex, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return noindex, nil
+}
+
+// RecordFailure keeps a page that could not be crawled so it can be queued again.
+func (s *redisStore) RecordFailure(page models.FailedPage) error {
+	return s.record("failed", page)
+}
+
+// FailedPages returns the pages that could not be crawled in the order they failed.
+func (s *redisStore) FailedPages() ([]models.FailedPage, error) {
+	failed := []models.FailedPage{}
+	err := s.read("failed", func(value []byte) error {
+		page := models.FailedPage{}
+		if err := json.Unmarshal(value, &page); err != nil {
+			return err
+		}
+		failed = append(failed, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// RecordPage keeps the result of fetching a page.
+func (s *redisStore) RecordPage(page models.PageResult) error {
+	return s.record("pages", page)
+}
+
+// Pages returns the results of the fetched pages in the order they were fetched.
+func (s *redisStore) Pages() ([]models.PageResult, error) {
+	pages := []models.PageResult{}
+	err := s.read("pages", func(value []byte) error {
+		page := models.PageResult{}
+		if err := json.Unmarshal(value, &page); err != nil {
+			return err
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// VisitedKeys returns the keys marked as visited, sorted.
+func (s *redisStore) VisitedKeys() ([]string, error) {
+	keys, err := s.client.SMembers(context.Background(), s.key("visited")).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading visited links: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// RestoreVisited replaces the visited keys with keys in a single transaction.
+func (s *redisStore) RestoreVisited(keys []string) error {
+	_, err := s.client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.Del(context.Background(), s.key("visited"))
+		for start := 0; start < len(keys); start += redisRestoreBatch {
+			end := start + redisRestoreBatch
+			if end > len(keys) {
+				end = len(keys)
+			}
+			members := make([]interface{}, 0, end-start)
+			for _, key := range keys[start:end] {
+				members = append(members, key)
+			}
+			pipe.SAdd(context.Background(), s.key("visited"), members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error restoring visited links: %w", err)
+	}
+	return nil
+}
+
+// Close closes the client, the keys are left in Redis for the other processes.
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+// key returns the Redis key of name under the prefix of the store.
+func (s *redisStore) key(name string) string {
+	return s.prefix + ":" + name
+}
+
+// record appends the JSON encoded value to the list of name.
+func (s *redisStore) record(name string, value interface{}) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s record: %w", name, err)
+	}
+	if err := s.client.RPush(context.Background(), s.key(name), payload).Err(); err != nil {
+		return fmt.Errorf("error writing %s record: %w", name, err)
+	}
+	return nil
+}
+
+// read decodes every value of the list of name in order.
+func (s *redisStore) read(name string, decode func(value []byte) error) error {
+	values, err := s.client.LRange(context.Background(), s.key(name), 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("error reading %s records: %w", name, err)
+	}
+	for _, value := range values {
+		if err := decode([]byte(value)); err != nil {
+			return fmt.Errorf("error decoding %s record: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisStoreShared(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := models.Config{StoreBackend: "redis", RedisURL: "redis://" + server.Addr(), RedisKeyPrefix: "crawler"}
+	first, err := New(cfg)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := New(cfg)
+	assert.Nil(t, err)
+	defer second.Close()
+
+	visited, err := first.WasAlreadyVisited("//mock-site.com/a")
+	assert.Nil(t, err)
+	assert.False(t, visited)
+	visited, err = second.WasAlreadyVisited("//mock-site.com/a")
+	assert.Nil(t, err)
+	assert.True(t, visited)
+
+	assert.Nil(t, first.RecordNoindex("https://mock-site.com/a"))
+	assert.Nil(t, second.RecordNoindex("https://mock-site.com/b"))
+	noindex, err := first.NoindexPages()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://mock-site.com/a", "https://mock-site.com/b"}, noindex)
+
+	// another prefix is another crawl
+	cfg.RedisKeyPrefix = "other"
+	other, err := New(cfg)
+	assert.Nil(t, err)
+	defer other.Close()
+	visited, err = other.WasAlreadyVisited("//mock-site.com/a")
+	assert.Nil(t, err)
+	assert.False(t, visited)
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := models.Config{StoreBackend: "redis", RedisURL: "redis://" + server.Addr(), RedisKeyPrefix: "crawler"}
+	store, err := New(cfg)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, server.Set("crawler:pages", "not a list"))
+	_, err = store.Pages()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error reading pages records")
+	}
+	assert.Nil(t, server.Set("crawler:visited", "not a set"))
+	_, err = store.WasAlreadyVisited("//mock-site.com/a")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error marking visited link")
+	}
+
+	server.Close()
+	_, err = New(cfg)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error connecting to redis")
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/dsnet/golib/memfile"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend creates a store from the options of the configuration it uses.
+type Backend func(cfg models.Config) (ICrawlerStore, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		"memory": newMemoryBackend,
+		"file":   newFileBackend,
+		"bloom":  newBloomBackend,
+		"redis":  newRedisBackend,
+		"sqlite": newSQLiteBackend,
+	}
+)
+
+// Register makes a store backend available under name, replacing the one registered with it if any.
+func Register(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = backend
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the store of the StoreBackend of the configuration.
+func New(cfg models.Config) (ICrawlerStore, error) {
+	backendsMu.RLock()
+	backend, ok := backends[cfg.StoreBackend]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend %q, available backends: %s", cfg.StoreBackend, strings.Join(Backends(), ", "))
+	}
+	return backend(cfg)
+}
+
+// newMemoryBackend keeps the store in memory, writing the visited set to an in-memory file every
+// StoreSnapshotInterval.
+func newMemoryBackend(cfg models.Config) (ICrawlerStore, error) {
+	return NewMemfileStore(&sync.Mutex{}, memfile.New([]byte{}), cfg.StoreSnapshotInterval), nil
+}
+
+// newFileBackend keeps the store in StoreDir, compacting its log every StoreCompactEvery entries.
+func newFileBackend(cfg models.Config) (ICrawlerStore, error) {
+	if cfg.StoreDir == "" {
+		return nil, errors.New("the file store backend needs a directory")
+	}
+	return NewDiskStore(cfg.StoreDir, cfg.StoreCompactEvery)
+}
+
+// newBloomBackend keeps the visited links in a Bloom filter sized for BloomCapacity links with a
+// BloomFalsePositiveRate, verified against the exact set in BloomVerifyDir when it is set.
+func newBloomBackend(cfg models.Config) (ICrawlerStore, error) {
+	return NewBloomStore(cfg.BloomCapacity, cfg.BloomFalsePositiveRate, cfg.BloomVerifyDir)
+}
+
+// newSQLiteBackend keeps the store in the tables of the SQLite database at SQLitePath.
+func newSQLiteBackend(cfg models.Config) (ICrawlerStore, error) {
+	if cfg.SQLitePath == "" {
+		return nil, errors.New("the sqlite store backend needs a database path")
+	}
+	return NewSQLiteStore(cfg.SQLitePath)
+}
+
+// newRedisBackend keeps the store under RedisKeyPrefix in the Redis at RedisURL, shared by the
+// crawler processes using the same ones.
+func newRedisBackend(cfg models.Config) (ICrawlerStore, error) {
+	options, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to redis: %w", err)
+	}
+	return NewRedisStore(client, cfg.RedisKeyPrefix), nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// setShards is the number of shards of a visitedSet, a power of two so the shard is picked with a mask.
+const setShards = 256
+
+// visitedSet is a concurrent set of keys split in shards with their own lock, so concurrent
+// visits rarely wait for each other and every visit takes constant time.
+type visitedSet struct {
+	seed   maphash.Seed
+	shards [setShards]setShard
+}
+
+type setShard struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newVisitedSet() *visitedSet {
+	set := &visitedSet{seed: maphash.MakeSeed()}
+	for i := range set.shards {
+		set.shards[i].keys = map[string]struct{}{}
+	}
+	return set
+}
+
+// Add adds the key to the set and reports whether it was not there yet, checking and adding
+// the key is atomic.
+func (v *visitedSet) Add(key string) bool {
+	shard := v.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, ok := shard.keys[key]; ok {
+		return false
+	}
+	shard.keys[key] = struct{}{}
+	return true
+}
+
+// Contains reports whether the key is in the set.
+func (v *visitedSet) Contains(key string) bool {
+	shard := v.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	_, ok := shard.keys[key]
+	return ok
+}
+
+// Len returns the number of keys in the set.
+func (v *visitedSet) Len() int {
+	count := 0
+	for i := range v.shards {
+		v.shards[i].mu.Lock()
+		count += len(v.shards[i].keys)
+		v.shards[i].mu.Unlock()
+	}
+	return count
+}
+
+// Range calls fn for every key in the set, one shard at a time so visits to other shards are not
+// blocked meanwhile.
+func (v *visitedSet) Range(fn func(key string)) {
+	for i := range v.shards {
+		v.shards[i].mu.Lock()
+		for key := range v.shards[i].keys {
+			fn(key)
+		}
+		v.shards[i].mu.Unlock()
+	}
+}
+
+// Clear removes every key of the set.
+func (v *visitedSet) Clear() {
+	for i := range v.shards {
+		v.shards[i].mu.Lock()
+		v.shards[i].keys = map[string]struct{}{}
+		v.shards[i].mu.Unlock()
+	}
+}
+
+func (v *visitedSet) shard(key string) *setShard {
+	return &v.shards[maphash.String(v.seed, key)&(setShards-1)]
+}
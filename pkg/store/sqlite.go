@@ -0,0 +1,386 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	// registers the pure Go sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of the store, one row per crawled page, link between pages and
+// failed page so a crawl can be queried with plain SQL.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS visited (
+	key TEXT PRIMARY KEY
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS pages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	depth INTEGER NOT NULL,
+	parent TEXT NOT NULL,
+	final_url TEXT NOT NULL,
+	redirects TEXT NOT NULL,
+	off_host_redirect INTEGER NOT NULL,
+	status INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	charset TEXT NOT NULL,
+	title TEXT NOT NULL,
+	content_length INTEGER NOT NULL,
+	fetched_at TEXT,
+	response_time_ms REAL NOT NULL,
+	truncated INTEGER NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pages_url ON pages (url);
+CREATE TABLE IF NOT EXISTS links (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	from_url TEXT NOT NULL,
+	to_url TEXT NOT NULL,
+	anchor_text TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	attr TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS links_from_url ON links (from_url);
+CREATE INDEX IF NOT EXISTS links_to_url ON links (to_url);
+CREATE TABLE IF NOT EXISTS errors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	depth INTEGER NOT NULL,
+	parent TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	attr TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS assets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	parent TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	attr TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	error TEXT NOT NULL,
+	broken INTEGER NOT NULL,
+	oversized INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS noindex (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL
+);
+`
+
+// sqliteStore keeps the visited keys and the crawl results in the tables of a SQLite database file,
+// every record is written in its own transaction so it survives a crash.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the SQLite database at path, creating it and its tables when they do not
+// exist. The records of a previous crawl kept in it are kept but its visited links are cleared, so
+// the crawl starts over unless it is resumed from a checkpoint restoring them.
+func NewSQLiteStore(path string) (ICrawlerStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %w", err)
+	}
+	// sqlite writes one transaction at a time, a single connection serializes them instead of
+	// failing them as busy
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{"PRAGMA journal_mode = WAL", "PRAGMA synchronous = NORMAL", sqliteSchema, "DELETE FROM visited"} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating sqlite tables: %w", err)
+		}
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// WasAlreadyVisited reports whether the site key was seen before and marks it as visited.
+func (s *sqliteStore) WasAlreadyVisited(site string) (bool, error) {
+	result, err := s.db.Exec("INSERT OR IGNORE INTO visited (key) VALUES (?)", site)
+	if err != nil {
+		return false, fmt.Errorf("error marking visited link: %w", err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error marking visited link: %w", err)
+	}
+	return added == 0, nil
+}
+
+// StoreData marks the site and the sites of data as visited.
+func (s *sqliteStore) StoreData(site string, data models.SiteStore) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error marking visited links: %w", err)
+	}
+	defer tx.Rollback()
+	sites := []string{site}
+	for key := range data.Sites {
+		sites = append(sites, key)
+	}
+	for _, key := range sites {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO visited (key) VALUES (?)", key); err != nil {
+			return fmt.Errorf("error marking visited links: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error marking visited links: %w", err)
+	}
+	return nil
+}
+
+// RecordAsset keeps the result of checking an asset.
+func (s *sqliteStore) RecordAsset(asset models.AssetResult) error {
+	_, err := s.db.Exec(`INSERT INTO assets (url, parent, tag, attr, status, content_type, size, error, broken, oversized)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		asset.URL, asset.Parent, asset.Tag, asset.Attr, asset.StatusCode, asset.ContentType, asset.Size, asset.Error,
+		asset.Broken, asset.Oversized)
+	if err != nil {
+		return fmt.Errorf("error writing asset record: %w", err)
+	}
+	return nil
+}
+
+// Assets returns the recorded assets in the order they were checked.
+func (s *sqliteStore) Assets() ([]models.AssetResult, error) {
+	rows, err := s.db.Query(`SELECT url, parent, tag, attr, status, content_type, size, error, broken, oversized
+		FROM assets ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading asset records: %w", err)
+	}
+	defer rows.Close()
+	assets := []models.AssetResult{}
+	for rows.Next() {
+		asset := models.AssetResult{}
+		err := rows.Scan(&asset.URL, &asset.Parent, &asset.Tag, &asset.Attr, &asset.StatusCode, &asset.ContentType,
+			&asset.Size, &asset.Error, &asset.Broken, &asset.Oversized)
+		if err != nil {
+			return nil, fmt.Errorf("error reading asset records: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading asset records: %w", err)
+	}
+	return assets, nil
+}
+
+// RecordNoindex keeps a page that asked not to be indexed.
+func (s *sqliteStore) RecordNoindex(page string) error {
+	if _, err := s.db.Exec("INSERT INTO noindex (url) VALUES (?)", page); err != nil {
+		return fmt.Errorf("error writing noindex record: %w", err)
+	}
+	return nil
+}
+
+// NoindexPages returns the recorded noindex pages in the order they were crawled.
+func (s *sqliteStore) NoindexPages() ([]string, error) {
+	rows, err := s.db.Query("SELECT url FROM noindex ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading noindex records: %w", err)
+	}
+	defer rows.Close()
+	noindex := []string{}
+	for rows.Next() {
+		page := ""
+		if err := rows.Scan(&page); err != nil {
+			return nil, fmt.Errorf("error reading noindex records: %w", err)
+		}
+		noindex = append(noindex, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading noindex records: %w", err)
+	}
+	return noindex, nil
+}
+
+// RecordFailure keeps a page that could not be crawled so it can be queued again.
+func (s *sqliteStore) RecordFailure(page models.FailedPage) error {
+	_, err := s.db.Exec("INSERT INTO errors (url, depth, parent, tag, attr, status, error) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		page.Item.URL, page.Item.Depth, page.Item.Parent, page.Item.Tag, page.Item.Attr, page.StatusCode, page.Error)
+	if err != nil {
+		return fmt.Errorf("error writing failed page record: %w", err)
+	}
+	return nil
+}
+
+// FailedPages returns the pages that could not be crawled in the order they failed.
+func (s *sqliteStore) FailedPages() ([]models.FailedPage, error) {
+	rows, err := s.db.Query("SELECT url, depth, parent, tag, attr, status, error FROM errors ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading failed page records: %w", err)
+	}
+	defer rows.Close()
+	failed := []models.FailedPage{}
+	for rows.Next() {
+		page := models.FailedPage{}
+		err := rows.Scan(&page.Item.URL, &page.Item.Depth, &page.Item.Parent, &page.Item.Tag, &page.Item.Attr,
+			&page.StatusCode, &page.Error)
+		if err != nil {
+			return nil, fmt.Errorf("error reading failed page records: %w", err)
+		}
+		failed = append(failed, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading failed page records: %w", err)
+	}
+	return failed, nil
+}
+
+// RecordPage keeps the result of fetching a page, its response time in milliseconds.
+func (s *sqliteStore) RecordPage(page models.PageResult) error {
+	redirects, err := json.Marshal(page.Redirects)
+	if err != nil {
+		return fmt.Errorf("error marshaling page redirects: %w", err)
+	}
+	var fetchedAt sql.NullString
+	if !page.FetchedAt.IsZero() {
+		fetchedAt = sql.NullString{String: page.FetchedAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	}
+	_, err = s.db.Exec(`INSERT INTO pages (url, depth, parent, final_url, redirects, off_host_redirect, status,
+		content_type, charset, title, content_length, fetched_at, response_time_ms, truncated, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		page.URL, page.Depth, page.Parent, page.FinalURL, string(redirects), page.OffHostRedirect, page.StatusCode,
+		page.ContentType, page.Charset, page.Title, page.ContentLength, fetchedAt,
+		float64(page.ResponseTime)/float64(time.Millisecond), page.Truncated, page.Error)
+	if err != nil {
+		return fmt.Errorf("error writing page record: %w", err)
+	}
+	return nil
+}
+
+// Pages returns the results of the fetched pages in the order they were fetched.
+func (s *sqliteStore) Pages() ([]models.PageResult, error) {
+	rows, err := s.db.Query(`SELECT url, depth, parent, final_url, redirects, off_host_redirect, status, content_type,
+		charset, title, content_length, fetched_at, response_time_ms, truncated, error FROM pages ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading page records: %w", err)
+	}
+	defer rows.Close()
+	pages := []models.PageResult{}
+	for rows.Next() {
+		page := models.PageResult{}
+		var redirects string
+		var fetchedAt sql.NullString
+		var responseTime float64
+		err := rows.Scan(&page.URL, &page.Depth, &page.Parent, &page.FinalURL, &redirects, &page.OffHostRedirect,
+			&page.StatusCode, &page.ContentType, &page.Charset, &page.Title, &page.ContentLength, &fetchedAt,
+			&responseTime, &page.Truncated, &page.Error)
+		if err != nil {
+			return nil, fmt.Errorf("error reading page records: %w", err)
+		}
+		if err := json.Unmarshal([]byte(redirects), &page.Redirects); err != nil {
+			return nil, fmt.Errorf("error decoding page redirects: %w", err)
+		}
+		if fetchedAt.Valid {
+			if page.FetchedAt, err = time.Parse(time.RFC3339Nano, fetchedAt.String); err != nil {
+				return nil, fmt.Errorf("error decoding page fetch time: %w", err)
+			}
+		}
+		page.ResponseTime = time.Duration(math.Round(responseTime * float64(time.Millisecond)))
+		pages = append(pages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading page records: %w", err)
+	}
+	return pages, nil
+}
+
+// RecordLinks keeps the links found on a page in a single transaction.
+func (s *sqliteStore) RecordLinks(links []models.LinkResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error writing link records: %w", err)
+	}
+	defer tx.Rollback()
+	for _, link := range links {
+		_, err := tx.Exec("INSERT INTO links (from_url, to_url, anchor_text, tag, attr) VALUES (?, ?, ?, ?, ?)",
+			link.From, link.To, link.Text, link.Tag, link.Attr)
+		if err != nil {
+			return fmt.Errorf("error writing link records: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error writing link records: %w", err)
+	}
+	return nil
+}
+
+// Links returns the recorded links in the order they were found.
+func (s *sqliteStore) Links() ([]models.LinkResult, error) {
+	rows, err := s.db.Query("SELECT from_url, to_url, anchor_text, tag, attr FROM links ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading link records: %w", err)
+	}
+	defer rows.Close()
+	links := []models.LinkResult{}
+	for rows.Next() {
+		link := models.LinkResult{}
+		if err := rows.Scan(&link.From, &link.To, &link.Text, &link.Tag, &link.Attr); err != nil {
+			return nil, fmt.Errorf("error reading link records: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading link records: %w", err)
+	}
+	return links, nil
+}
+
+// VisitedKeys returns the keys marked as visited, sorted.
+func (s *sqliteStore) VisitedKeys() ([]string, error) {
+	rows, err := s.db.Query("SELECT key FROM visited ORDER BY key")
+	if err != nil {
+		return nil, fmt.Errorf("error reading visited links: %w", err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		key := ""
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error reading visited links: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading visited links: %w", err)
+	}
+	return keys, nil
+}
+
+// RestoreVisited replaces the visited keys with keys in a single transaction.
+func (s *sqliteStore) RestoreVisited(keys []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error restoring visited links: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM visited"); err != nil {
+		return fmt.Errorf("error restoring visited links: %w", err)
+	}
+	for _, key := range keys {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO visited (key) VALUES (?)", key); err != nil {
+			return fmt.Errorf("error restoring visited links: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error restoring visited links: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *sqliteStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("error closing sqlite database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/csrar/crawler/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteStoreQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+	store, err := NewSQLiteStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.RecordPage(models.PageResult{URL: "https://mock-site.com/", StatusCode: 200, Title: "Home",
+		FetchedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), ResponseTime: 150 * time.Millisecond}))
+	assert.Nil(t, store.RecordPage(models.PageResult{URL: "https://mock-site.com/a", StatusCode: 404}))
+	assert.Nil(t, store.(ILinkRecorder).RecordLinks([]models.LinkResult{
+		{From: "https://mock-site.com/", To: "https://mock-site.com/a", Text: "Page A", Tag: "a", Attr: "href"},
+		{From: "https://mock-site.com/a", To: "https://mock-site.com/", Text: "Home", Tag: "a", Attr: "href"},
+	}))
+	assert.Nil(t, store.RecordFailure(models.FailedPage{Item: models.CrawlItem{URL: "https://mock-site.com/down"}, StatusCode: 503, Error: "503 Service Unavailable"}))
+	assert.Nil(t, store.Close())
+
+	// the crawl can be queried with plain SQL once the store is closed
+	db, err := sql.Open("sqlite", path)
+	assert.Nil(t, err)
+	defer db.Close()
+	var title, fetchedAt string
+	var responseTime float64
+	err = db.QueryRow("SELECT title, fetched_at, response_time_ms FROM pages WHERE status = 200").Scan(&title, &fetchedAt, &responseTime)
+	assert.Nil(t, err)
+	assert.Equal(t, "Home", title)
+	assert.Equal(t, "2026-10-18T12:00:00Z", fetchedAt)
+	assert.Equal(t, float64(150), responseTime)
+
+	var broken string
+	err = db.QueryRow(`SELECT links.from_url FROM links JOIN pages ON pages.url = links.to_url
+		WHERE pages.status >= 400`).Scan(&broken)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://mock-site.com/", broken)
+
+	var failures int
+	err = db.QueryRow("SELECT count(*) FROM errors WHERE status = 503").Scan(&failures)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, failures)
+}
+
+func TestSQLiteStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+	store, err := NewSQLiteStore(path)
+	assert.Nil(t, err)
+	fillStore(t, store, "//mock-site.com/a", "//mock-site.com/b")
+	assert.Nil(t, store.Close())
+
+	// the results are kept, the links are not visited in the new crawl
+	store, err = NewSQLiteStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	for _, site := range []string{"//mock-site.com/a", "//mock-site.com/b"} {
+		visited, err := store.WasAlreadyVisited(site)
+		assert.Nil(t, err)
+		assert.False(t, visited, site)
+	}
+	assertStore(t, store, "//mock-site.com/a", "//mock-site.com/b")
+}
+
+func TestSQLiteStoreInvalidPath(t *testing.T) {
+	_, err := NewSQLiteStore(filepath.Join(t.TempDir(), "missing", "crawl.db"))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "error creating sqlite tables")
+	}
+}
@@ -3,49 +3,264 @@ package store
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/csrar/crawler/internal/models"
-	"github.com/gosimple/slug"
 )
 
 //go:generate mockgen -source=store.go -destination=mocks/store_mock.go
 type ICrawlerStore interface {
 	WasAlreadyVisited(site string) (bool, error)
 	StoreData(site string, data models.SiteStore) error
+	RecordAsset(asset models.AssetResult) error
+	Assets() ([]models.AssetResult, error)
+	RecordNoindex(page string) error
+	NoindexPages() ([]string, error)
+	RecordFailure(page models.FailedPage) error
+	FailedPages() ([]models.FailedPage, error)
+	RecordPage(page models.PageResult) error
+	Pages() ([]models.PageResult, error)
+	Close() error
+}
+
+// IFilterStats is implemented by the stores keeping the visited links in a probabilistic filter.
+type IFilterStats interface {
+	FilterStats() models.FilterStats
+}
+
+// ILinkRecorder is implemented by the stores keeping the links between the crawled pages.
+type ILinkRecorder interface {
+	RecordLinks(links []models.LinkResult) error
+	Links() ([]models.LinkResult, error)
+}
+
+// IVisitedKeys is implemented by the stores able to list the keys they marked as visited and to
+// restore them, the visited set of a crawl can be checkpointed with them.
+type IVisitedKeys interface {
+	VisitedKeys() ([]string, error)
+	// RestoreVisited replaces the visited keys with keys, before the store is used by the crawl
+	RestoreVisited(keys []string) error
 }
 
 type store struct {
-	visited ImFile
+	recordStore
+	visited   ImFile
+	set       *visitedSet
+	snapshots *snapshots
+}
+
+// recordStore keeps the results collected during the crawl in memory, the stores keeping their
+// visited links in memory share it.
+type recordStore struct {
 	mu      *sync.Mutex
+	records *records
+}
+
+// records keeps the results collected during the crawl.
+type records struct {
+	assets  []models.AssetResult
+	noindex []string
+	failed  []models.FailedPage
+	pages   []models.PageResult
 }
 
-func NewMemfileStore(mu *sync.Mutex, file ImFile) ICrawlerStore {
-	return &store{
-		visited: file,
-		mu:      mu,
+// snapshots tracks the writes of the visited set to the memfile.
+type snapshots struct {
+	mu sync.Mutex
+	// dirty is set by the visits made since the last snapshot
+	dirty   atomic.Bool
+	err     error
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewMemfileStore creates a store keeping the visited set in memory, written to the file as JSON
+// in the background every interval and when the store is closed. An interval of 0 only writes it on close.
+func NewMemfileStore(mu *sync.Mutex, file ImFile, interval time.Duration) ICrawlerStore {
+	s := &store{
+		visited:     file,
+		set:         newVisitedSet(),
+		recordStore: recordStore{mu: mu, records: &records{}},
+		snapshots: &snapshots{
+			stop:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		},
+	}
+	if interval > 0 {
+		go s.snapshotEvery(interval)
+	} else {
+		close(s.snapshots.stopped)
 	}
+	return s
 }
 
+// WasAlreadyVisited reports whether the site key was seen before and marks it as visited,
+// callers pass the canonical form of the URL as the key.
 func (s store) WasAlreadyVisited(site string) (bool, error) {
+	if !s.set.Add(site) {
+		return true, nil
+	}
+	s.snapshots.markDirty()
+	return false, nil
+}
+
+// StoreData marks the site and the sites of data as visited, they are written to the file by the next snapshot.
+func (s store) StoreData(site string, data models.SiteStore) error {
+	for key := range data.Sites {
+		s.set.Add(key)
+	}
+	s.set.Add(site)
+	s.snapshots.markDirty()
+	return nil
+}
+
+// RecordAsset keeps the result of checking an asset.
+func (s recordStore) RecordAsset(asset models.AssetResult) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	slug_site := slug.Make(site)
-	visited, err := s.readData()
-	if err != nil {
-		return false, err
+	s.records.assets = append(s.records.assets, asset)
+	return nil
+}
+
+// Assets returns the recorded assets in the order they were checked.
+func (s recordStore) Assets() ([]models.AssetResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.AssetResult{}, s.records.assets...), nil
+}
+
+// RecordNoindex keeps a page that asked not to be indexed.
+func (s recordStore) RecordNoindex(page string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records.noindex = append(s.records.noindex, page)
+	return nil
+}
+
+// NoindexPages returns the recorded noindex pages in the order they were crawled.
+func (s recordStore) NoindexPages() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.records.noindex...), nil
+}
+
+// RecordFailure keeps a page that could not be crawled so it can be queued again.
+func (s recordStore) RecordFailure(page models.FailedPage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records.failed = append(s.records.failed, page)
+	return nil
+}
+
+// FailedPages returns the pages that could not be crawled in the order they failed.
+func (s recordStore) FailedPages() ([]models.FailedPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.FailedPage{}, s.records.failed...), nil
+}
+
+// RecordPage keeps the result of fetching a page.
+func (s recordStore) RecordPage(page models.PageResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records.pages = append(s.records.pages, page)
+	return nil
+}
+
+// Pages returns the results of the fetched pages in the order they were fetched.
+func (s recordStore) Pages() ([]models.PageResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.PageResult{}, s.records.pages...), nil
+}
+
+// VisitedKeys returns the keys marked as visited, sorted.
+func (s store) VisitedKeys() ([]string, error) {
+	keys := make([]string, 0, s.set.Len())
+	s.set.Range(func(key string) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// RestoreVisited replaces the visited keys with keys, they are written to the file by the next snapshot.
+func (s store) RestoreVisited(keys []string) error {
+	s.set.Clear()
+	for _, key := range keys {
+		s.set.Add(key)
 	}
-	if _, ok := visited.Sites[slug_site]; !ok {
-		if err := s.StoreData(slug_site, *visited); err != nil {
-			return false, err
+	s.snapshots.markDirty()
+	return nil
+}
+
+// Close stops the background snapshots and writes the last one, returning the first snapshot error.
+func (s store) Close() error {
+	s.snapshots.once.Do(func() {
+		close(s.snapshots.stop)
+	})
+	<-s.snapshots.stopped
+	err := s.snapshot()
+	s.snapshots.mu.Lock()
+	defer s.snapshots.mu.Unlock()
+	if s.snapshots.err != nil {
+		return s.snapshots.err
+	}
+	return err
+}
+
+// snapshotEvery writes the visited set to the file every interval until the store is closed.
+func (s store) snapshotEvery(interval time.Duration) {
+	defer close(s.snapshots.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.snapshots.stop:
+			return
+		case <-ticker.C:
+			_ = s.snapshot()
 		}
-		return false, nil
 	}
-	return true, nil
 }
 
-func (s store) StoreData(site string, data models.SiteStore) error {
-	data.Sites[site] = true
+// snapshot writes the visited set to the file when it changed since the last snapshot, visits
+// go on meanwhile and are written by the next one.
+func (s store) snapshot() error {
+	s.snapshots.mu.Lock()
+	defer s.snapshots.mu.Unlock()
+	// the flag is cleared before reading the set, a visit missed by this snapshot sets it again
+	if !s.snapshots.dirty.Swap(false) {
+		return nil
+	}
+	err := s.writeVisited()
+	if err != nil {
+		s.snapshots.dirty.Store(true)
+		if s.snapshots.err == nil {
+			s.snapshots.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// markDirty flags the set as changed, it is only written when it was not flagged yet so
+// concurrent visits do not contend on it.
+func (s *snapshots) markDirty() {
+	if !s.dirty.Load() {
+		s.dirty.Store(true)
+	}
+}
+
+func (s store) writeVisited() error {
+	data := models.SiteStore{Sites: make(map[string]bool, s.set.Len())}
+	s.set.Range(func(key string) {
+		data.Sites[key] = true
+	})
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling visited data %+v", err)
@@ -60,12 +275,3 @@ func (s store) StoreData(site string, data models.SiteStore) error {
 	}
 	return nil
 }
-
-func (s store) readData() (*models.SiteStore, error) {
-	message := &models.SiteStore{}
-	err := json.Unmarshal(s.visited.Bytes(), message)
-	if err != nil {
-		return nil, fmt.Errorf("error decoding unmarshaling data: %+v", err)
-	}
-	return message, nil
-}
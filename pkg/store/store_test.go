@@ -2,9 +2,14 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/csrar/crawler/internal/models"
 	mock_store "github.com/csrar/crawler/pkg/store/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -12,68 +17,105 @@ import (
 
 func TestWasAlreadyVisited(t *testing.T) {
 	cases := []struct {
-		name           string
-		inVisitedSite  string
-		outReadBytes   []byte
-		outTruncate    error
-		outWriteAt     error
-		ReadBytesTimes int
-		TruncateTimes  int
-		WriteAtTimes   int
-		inWriteAt      []byte
-		expectedResult bool
-		expedtedErr    error
+		name     string
+		visits   []string
+		expected []bool
 	}{
 		{
-			name:           "error due invalid data",
-			inVisitedSite:  "",
-			outReadBytes:   []byte("}"),
-			expectedResult: false,
-			expedtedErr:    errors.New("error decoding unmarshaling data: invalid character '}' looking for beginning of value"),
-			ReadBytesTimes: 1,
-			WriteAtTimes:   0,
-			TruncateTimes:  0,
+			name:     "not visited",
+			visits:   []string{"//mock-site.com/page"},
+			expected: []bool{false},
 		},
 		{
-			name:           "error truncating data",
-			inVisitedSite:  "mock-site.com",
-			outReadBytes:   []byte(`{"sites":{"mocK-site.com": true}}`),
-			expectedResult: false,
-			expedtedErr:    errors.New("error truncating visited data mock-error"),
-			outTruncate:    errors.New("mock-error"),
-			TruncateTimes:  1,
-			ReadBytesTimes: 1,
-			WriteAtTimes:   0,
+			name:     "already visited",
+			visits:   []string{"//mock-site.com/page", "//mock-site.com/page"},
+			expected: []bool{false, true},
 		},
 		{
-			name:           "error writting data",
-			inVisitedSite:  "example-site.com",
-			inWriteAt:      []byte(`{"sites":{"example-site-com":true,"mock-site-com":true}}`),
-			outReadBytes:   []byte(`{"sites":{"mock-site-com": true}}`),
-			expectedResult: false,
-			expedtedErr:    errors.New("error writing visited data mock-error"),
-			outWriteAt:     errors.New("mock-error"),
-			TruncateTimes:  1,
-			ReadBytesTimes: 1,
-			WriteAtTimes:   1,
+			name:     "similar keys",
+			visits:   []string{"//mock-site.com/a/b", "//mock-site.com/a-b", "//mock-site.com/a/b"},
+			expected: []bool{false, false, true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var mu sync.Mutex
+			store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+			for i, site := range tc.visits {
+				result, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected[i], result, site)
+			}
+		})
+	}
+}
+
+func TestWasAlreadyVisitedConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+	var wg sync.WaitGroup
+	var firstVisits atomic.Int64
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				visited, err := store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/%d", i))
+				assert.Nil(t, err)
+				if !visited {
+					firstVisits.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// every key is reported as not visited exactly once
+	assert.Equal(t, int64(1000), firstVisits.Load())
+}
+
+func TestSnapshot(t *testing.T) {
+	cases := []struct {
+		name          string
+		visits        []string
+		outTruncate   error
+		outWriteAt    error
+		inWriteAt     []byte
+		TruncateTimes int
+		WriteAtTimes  int
+		expectedErr   error
+	}{
+		{
+			name:          "visited set written",
+			visits:        []string{"//mock-site.com/page", "//example-site.com/page", "//mock-site.com/page"},
+			inWriteAt:     []byte(`{"sites":{"//example-site.com/page":true,"//mock-site.com/page":true}}`),
+			TruncateTimes: 1,
+			WriteAtTimes:  1,
+		},
+		{
+			name: "nothing visited",
 		},
 		{
-			name:           "Successful not visited",
-			inVisitedSite:  "example-site.com",
-			inWriteAt:      []byte(`{"sites":{"example-site-com":true,"mock-site-com":true}}`),
-			outReadBytes:   []byte(`{"sites":{"mock-site-com": true}}`),
-			expectedResult: false,
-			TruncateTimes:  1,
-			ReadBytesTimes: 1,
-			WriteAtTimes:   1,
+			name:          "error truncating data",
+			visits:        []string{"//mock-site.com/page"},
+			outTruncate:   errors.New("mock-error"),
+			TruncateTimes: 1,
+			expectedErr:   errors.New("error truncating visited data mock-error"),
 		},
 		{
-			name:           "Successful already visited",
-			inVisitedSite:  "mock-site.com",
-			inWriteAt:      []byte(`{"sites":{"example-site-com":true,"mock-site-com":true}}`),
-			outReadBytes:   []byte(`{"sites":{"mock-site-com": true}}`),
-			expectedResult: true,
-			ReadBytesTimes: 1,
+			name:          "error writting data",
+			visits:        []string{"//mock-site.com/page"},
+			inWriteAt:     []byte(`{"sites":{"//mock-site.com/page":true}}`),
+			outWriteAt:    errors.New("mock-error"),
+			TruncateTimes: 1,
+			WriteAtTimes:  1,
+			expectedErr:   errors.New("error writing visited data mock-error"),
 		},
 	}
 
@@ -84,18 +126,208 @@ func TestWasAlreadyVisited(t *testing.T) {
 
 			var mu sync.Mutex
 			fileMock := mock_store.NewMockImFile(ctrl)
-
-			fileMock.EXPECT().Bytes().Return(tc.outReadBytes).Times(tc.ReadBytesTimes)
 			fileMock.EXPECT().Truncate(int64(0)).Return(tc.outTruncate).Times(tc.TruncateTimes)
-			fileMock.EXPECT().WriteAt(tc.inWriteAt, int64(0)).Return(int(0), tc.outWriteAt).Times(tc.WriteAtTimes)
+			fileMock.EXPECT().WriteAt(tc.inWriteAt, int64(0)).Return(len(tc.inWriteAt), tc.outWriteAt).Times(tc.WriteAtTimes)
+
+			store := NewMemfileStore(&mu, fileMock, 0)
+			for _, site := range tc.visits {
+				_, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.expectedErr, store.Close())
+		})
+	}
+}
+
+func TestBackgroundSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	written := make(chan string, 2)
+	fileMock := mock_store.NewMockImFile(ctrl)
+	fileMock.EXPECT().Truncate(int64(0)).Return(nil).Times(2)
+	fileMock.EXPECT().WriteAt(gomock.Any(), int64(0)).DoAndReturn(func(b []byte, offset int64) (int, error) {
+		written <- string(b)
+		return len(b), nil
+	}).Times(2)
+
+	store := NewMemfileStore(&mu, fileMock, 5*time.Millisecond)
+	_, err := store.WasAlreadyVisited("//mock-site.com/page")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"sites":{"//mock-site.com/page":true}}`, <-written)
+
+	// unchanged sets are not written again, the last visit is written on close
+	time.Sleep(20 * time.Millisecond)
+	_, err = store.WasAlreadyVisited("//mock-site.com/other")
+	assert.Nil(t, err)
+	assert.Nil(t, store.Close())
+	assert.Equal(t, `{"sites":{"//mock-site.com/other":true,"//mock-site.com/page":true}}`, <-written)
+}
+
+func TestAssets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+	broken := models.AssetResult{URL: "https://mock-site.com/missing.png", StatusCode: 404, Broken: true}
+	style := models.AssetResult{URL: "https://mock-site.com/style.css", StatusCode: 200, ContentType: "text/css"}
 
-			store := NewMemfileStore(&mu, fileMock)
-			result, err := store.WasAlreadyVisited(tc.inVisitedSite)
+	assert.Nil(t, store.RecordAsset(broken))
+	assert.Nil(t, store.RecordAsset(style))
 
-			assert.Equal(t, tc.expectedResult, result)
-			assert.Equal(t, tc.expedtedErr, err)
+	assets, err := store.Assets()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.AssetResult{broken, style}, assets)
 
+	// the returned slice is a copy
+	assets[0].URL = "changed"
+	assets, _ = store.Assets()
+	assert.Equal(t, broken, assets[0])
+}
+
+func TestNoindexPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+	pages, err := store.NoindexPages()
+	assert.Nil(t, err)
+	assert.Empty(t, pages)
+
+	assert.Nil(t, store.RecordNoindex("https://mock-site.com/private"))
+	assert.Nil(t, store.RecordNoindex("https://mock-site.com/drafts"))
+
+	pages, err = store.NoindexPages()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://mock-site.com/private", "https://mock-site.com/drafts"}, pages)
+}
+
+func TestFailedPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+	failed := models.FailedPage{
+		Item:       models.CrawlItem{URL: "https://mock-site.com/page", Depth: 1, Parent: "https://mock-site.com/"},
+		StatusCode: 503,
+		Error:      "503 Service Unavailable",
+	}
+	assert.Nil(t, store.RecordFailure(failed))
+
+	pages, err := store.FailedPages()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.FailedPage{failed}, pages)
+}
+
+func TestPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	store := NewMemfileStore(&mu, mock_store.NewMockImFile(ctrl), 0)
+	page := models.PageResult{
+		URL:      "https://mock-site.com/old",
+		FinalURL: "https://mock-site.com/new",
+		Redirects: []models.Redirect{
+			{URL: "https://mock-site.com/old", StatusCode: 301, Location: "/new"},
+		},
+		StatusCode:    200,
+		ContentType:   "text/html",
+		ContentLength: 512,
+	}
+	failed := models.PageResult{URL: "https://mock-site.com/down", ContentLength: -1, Error: "connection refused"}
+	assert.Nil(t, store.RecordPage(page))
+	assert.Nil(t, store.RecordPage(failed))
+
+	pages, err := store.Pages()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.PageResult{page, failed}, pages)
+}
+
+func TestVisitedKeys(t *testing.T) {
+	newStores := map[string]func(t *testing.T) ICrawlerStore{
+		"memory": func(t *testing.T) ICrawlerStore {
+			return NewMemfileStore(&sync.Mutex{}, nil, 0)
+		},
+		"disk": func(t *testing.T) ICrawlerStore {
+			store, err := NewDiskStore(t.TempDir(), 0)
+			assert.Nil(t, err)
+			return store
+		},
+		"verified bloom": func(t *testing.T) ICrawlerStore {
+			store, err := NewBloomStore(100, 0.01, t.TempDir())
+			assert.Nil(t, err)
+			return store
+		},
+	}
+
+	for name, newStore := range newStores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			for _, site := range []string{"//mock-site.com/b", "//mock-site.com/a", "//mock-site.com/b"} {
+				_, err := store.WasAlreadyVisited(site)
+				assert.Nil(t, err)
+			}
+			assert.Nil(t, store.StoreData("//mock-site.com/c", models.SiteStore{Sites: map[string]bool{}}))
+
+			keys, err := store.(IVisitedKeys).VisitedKeys()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"//mock-site.com/a", "//mock-site.com/b", "//mock-site.com/c"}, keys)
 		})
+	}
 
+	t.Run("unverified bloom", func(t *testing.T) {
+		store, err := NewBloomStore(100, 0.01, "")
+		assert.Nil(t, err)
+		// the filter does not keep the links, they cannot be checkpointed
+		_, ok := store.(IVisitedKeys)
+		assert.False(t, ok)
+	})
+}
+
+// newVisitedStore returns a memory store with size keys visited.
+func newVisitedStore(size int) ICrawlerStore {
+	store := NewMemfileStore(&sync.Mutex{}, nil, 0)
+	for i := 0; i < size; i++ {
+		_, _ = store.WasAlreadyVisited(fmt.Sprintf("//mock-site.com/visited/%d", i))
+	}
+	return store
+}
+
+func BenchmarkWasAlreadyVisited(b *testing.B) {
+	for _, size := range []int{1000, 100000, 1000000} {
+		store := newVisitedStore(size)
+		next := 0
+		b.Run(fmt.Sprintf("%d visited", size), func(b *testing.B) {
+			keys := make([]string, b.N)
+			for i := range keys {
+				// every other key was already visited
+				if i%2 == 0 {
+					keys[i] = fmt.Sprintf("//mock-site.com/visited/%d", i%size)
+				} else {
+					keys[i] = fmt.Sprintf("//mock-site.com/new/%d", next)
+					next++
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = store.WasAlreadyVisited(keys[i])
+			}
+		})
 	}
 }
+
+func BenchmarkWasAlreadyVisitedParallel(b *testing.B) {
+	store := newVisitedStore(1000000)
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = store.WasAlreadyVisited("//mock-site.com/new/" + strconv.FormatInt(next.Add(1), 10))
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// diskSetLocks is the number of locks shared by the keys
+	diskSetLocks = 256
+	// diskSetFile is the SQLite database of the keys of a diskSet
+	diskSetFile = "visited.db"
+	// keysFilePattern matches the bucket files the keys were kept in before they moved to diskSetFile
+	keysFilePattern = "visited-*.keys"
+)
+
+// diskSet is an exact set of keys kept on disk in an indexed SQLite table, so a lookup reads a few
+// pages of its B-tree whatever the number of keys.
+type diskSet struct {
+	db    *sql.DB
+	locks [diskSetLocks]sync.Mutex
+}
+
+// newDiskSet creates an empty set in dir, removing the keys of a previous crawl.
+func newDiskSet(dir string) (*diskSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating verification directory: %w", err)
+	}
+	previous, err := filepath.Glob(filepath.Join(dir, keysFilePattern))
+	if err != nil {
+		return nil, fmt.Errorf("error listing verification files: %w", err)
+	}
+	path := filepath.Join(dir, diskSetFile)
+	previous = append(previous, path, path+"-wal", path+"-shm")
+	for _, file := range previous {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error removing verification file: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening verification database: %w", err)
+	}
+	// a single connection serializes the writes instead of failing them as busy, the set is only
+	// used by the running crawl so it does not have to survive a crash
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{"PRAGMA journal_mode = WAL", "PRAGMA synchronous = OFF",
+		"CREATE TABLE visited (key TEXT PRIMARY KEY) WITHOUT ROWID"} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error creating verification table: %w", err)
+		}
+	}
+	return &diskSet{db: db}, nil
+}
+
+// lock returns the lock of the key, callers hold it while checking and adding the key.
+func (d *diskSet) lock(key string) *sync.Mutex {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(key))
+	return &d.locks[hash.Sum64()%diskSetLocks]
+}
+
+// add adds the key to the set, reporting whether it was not in it yet. Checking and adding the key
+// is a single statement, so concurrent callers adding the same key get a single true.
+func (d *diskSet) add(key string) (bool, error) {
+	result, err := d.db.Exec("INSERT OR IGNORE INTO visited (key) VALUES (?)", key)
+	if err != nil {
+		return false, fmt.Errorf("error writing verification key: %w", err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error writing verification key: %w", err)
+	}
+	return added > 0, nil
+}
+
+// reset replaces the keys of the set with keys in a single transaction.
+func (d *diskSet) reset(keys []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error restoring verification keys: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM visited"); err != nil {
+		return fmt.Errorf("error restoring verification keys: %w", err)
+	}
+	for _, key := range keys {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO visited (key) VALUES (?)", key); err != nil {
+			return fmt.Errorf("error restoring verification keys: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error restoring verification keys: %w", err)
+	}
+	return nil
+}
+
+// keys returns every key of the set, sorted.
+func (d *diskSet) keys() ([]string, error) {
+	rows, err := d.db.Query("SELECT key FROM visited ORDER BY key")
+	if err != nil {
+		return nil, fmt.Errorf("error reading verification keys: %w", err)
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		key := ""
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error reading verification keys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading verification keys: %w", err)
+	}
+	return keys, nil
+}
+
+// close closes the database, the keys are kept on disk until the next crawl.
+func (d *diskSet) close() error {
+	return d.db.Close()
+}